@@ -0,0 +1,229 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/caarlos0/env/v11"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/subcommands"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+type checksumCmd struct {
+	// storage selects the storage to read the dump from.
+	storage storageFlags
+
+	// file is the path of the dump to check.
+	file string
+
+	// live will compute the checksums from the tables of the database.
+	live bool
+
+	// targetDialect is the dialect the dump was converted to, postgres. The dialect of the database is used if empty.
+	targetDialect string
+
+	// timeout is the maximum duration of the run. If 0, the run is not limited.
+	timeout time.Duration
+}
+
+// checksumOutput is the structured report written to stdout by the checksum command.
+type checksumOutput struct {
+	// Path is the path of the dump that was checked. It is empty if only the database was read.
+	Path string `json:"path,omitempty"`
+
+	// Expected is where the expected checksums came from, dump or database.
+	Expected string `json:"expected"`
+
+	// Actual is where the actual checksums came from, dump or database.
+	Actual string `json:"actual"`
+
+	*dumpster.ChecksumReport
+}
+
+func (c *checksumCmd) Name() string {
+	return "checksum"
+}
+
+func (c *checksumCmd) Synopsis() string {
+	return "Checks the per-table checksums of a dump"
+}
+
+func (c *checksumCmd) Usage() string {
+	return `checksum:
+  Re-computes the per-table row counts and checksums of a dump and compares them against the checksums recorded in the
+  dump, or with --live against the tables of the database.
+`
+}
+
+func (c *checksumCmd) SetFlags(f *flag.FlagSet) {
+	c.storage.setFlags(f)
+	f.StringVar(&c.file, "file", "", "The path of the dump to check.")
+	f.BoolVar(&c.live, "live", false, "Compute the checksums from the tables of the database. With --file, the dump is compared against the database.")
+	f.StringVar(&c.targetDialect, "target-dialect", "", "The dialect the dump was converted to, postgres (Requires --live).")
+	f.DurationVar(&c.timeout, "timeout", 0, "The maximum duration of the run, e.g. 30m. If 0 (or not set), the run is not limited.")
+}
+
+func (c *checksumCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := logging.Init(appName)
+	if err != nil {
+		slog.Error("error initializing logging", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if c.file == "" && !c.live {
+		slog.Error("either --file or --live must be set")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.targetDialect != "" && !c.live {
+		slog.Error("--target-dialect requires --live")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	out := &checksumOutput{
+		Path:     c.file,
+		Expected: "dump",
+		Actual:   "dump",
+	}
+
+	var expected, actual []*dumpster.TableChecksum
+	if c.file != "" {
+		if expected, actual, err = c.dumpChecksums(ctx); err != nil {
+			slog.Error("error computing checksums of dump", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+	}
+
+	if c.live {
+		// Only the tables of the dump are read, or every table without a dump.
+		names := make([]string, 0, len(actual))
+		for _, t := range actual {
+			names = append(names, tablePattern(t.Name))
+		}
+
+		if expected, err = c.liveChecksums(ctx, names); err != nil {
+			slog.Error("error computing checksums of database", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		out.Expected = "database"
+		if c.file == "" {
+			actual, out.Actual = expected, "database"
+		}
+	}
+
+	out.ChecksumReport = dumpster.CompareChecksums(expected, actual)
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		slog.Error("error writing report", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if !out.OK {
+		slog.Error("Checksums do not match", slog.String("path", c.file))
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// dumpChecksums returns the checksums recorded in the dump and the checksums computed from its rows.
+func (c *checksumCmd) dumpChecksums(ctx context.Context) ([]*dumpster.TableChecksum, []*dumpster.TableChecksum, error) {
+	storageClient, err := c.storage.open(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error initializing storage: %w", err)
+	}
+
+	defer closeStorage(storageClient)
+
+	r, err := storageClient.Reader(ctx, c.file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error downloading dump: %w", err)
+	}
+
+	defer func(r io.ReadCloser) {
+		if err := r.Close(); err != nil {
+			slog.Warn("Error closing dump", slog.String(logging.KeyError, err.Error()))
+		}
+	}(r)
+
+	// Compressed dumps are decompressed as they are downloaded.
+	var dump io.Reader = r
+	if strings.HasSuffix(c.file, dumpster.CompressionGzip.Extension()) {
+		if dump, err = gzip.NewReader(r); err != nil {
+			return nil, nil, fmt.Errorf("error opening compressed dump: %w", err)
+		}
+	}
+
+	// The rows are hashed as the dump is downloaded.
+	computed, recorded, err := dumpster.ChecksumDump(dump)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return recorded, computed, nil
+}
+
+// liveChecksums returns the checksums of the tables of the database. Every table is read if no patterns are given.
+func (c *checksumCmd) liveChecksums(ctx context.Context, tables []string) ([]*dumpster.TableChecksum, error) {
+	dbConnEnv := new(DatabaseConnection)
+	if err := env.Parse(dbConnEnv); err != nil {
+		return nil, fmt.Errorf("error parsing environment variables: %w", err)
+	}
+
+	driver, connStr, err := driverConnStr(dbConnEnv.ConnStr)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring connection string: %w", err)
+	}
+
+	db, err := sqlx.Open(driver, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	defer func(db *sqlx.DB) {
+		if err := db.Close(); err != nil {
+			slog.Warn("Error closing database connection", slog.String(logging.KeyError, err.Error()))
+		}
+	}(db)
+
+	d, err := dumpster.NewDumpster(db,
+		dumpster.WithTargetDialect(c.targetDialect),
+		dumpster.WithTables(tables...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dumpster: %w", err)
+	}
+
+	return d.GetTableChecksums(ctx)
+}
+
+// tablePattern returns a pattern that only matches the table name.
+func tablePattern(name string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+	return r.Replace(name)
+}
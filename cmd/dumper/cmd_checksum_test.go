@@ -0,0 +1,20 @@
+package main
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTablePattern(t *testing.T) {
+	for _, name := range []string{"users", "order items", `a*b?[c]\d`} {
+		ok, err := path.Match(tablePattern(name), name)
+		require.NoError(t, err)
+		require.True(t, ok, name)
+	}
+
+	ok, err := path.Match(tablePattern("a*"), "ab")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
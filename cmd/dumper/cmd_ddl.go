@@ -5,18 +5,56 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/Jacobbrewer1/dumpster/pkg/dataaccess"
 	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
 	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/schemadiff"
 	"github.com/caarlos0/env/v11"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/subcommands"
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
-type ddlCmd struct{}
+type ddlCmd struct {
+	// storage selects the storage to upload the DDL to.
+	storage storageFlags
+
+	// skipUnchanged will skip saving the DDL if the schema has not changed since the last snapshot.
+	skipUnchanged bool
+
+	// normalize will remove volatile attributes and sort the objects so the output is deterministic.
+	normalize bool
+
+	// stripDefiners will remove the DEFINER clauses from triggers and views when normalizing.
+	stripDefiners bool
+
+	// split will write one file per database object instead of a single timestamped snapshot.
+	split bool
+
+	// compat is the server to make the DDL compatible with, mariadb or mysql57.
+	compat string
+
+	// template is the path of a text/template file to render the DDL with instead of the default template.
+	template string
+
+	// targetDialect is the dialect to convert the DDL to, postgres. The dialect of the database is used if empty.
+	targetDialect string
+
+	// tables is a comma separated list of patterns of the tables to include. Every table is included if empty.
+	tables string
+
+	// excludeTables is a comma separated list of patterns of the tables to leave out of the DDL.
+	excludeTables string
+
+	// timeout is the maximum duration of the run. If 0, the run is not limited.
+	timeout time.Duration
+}
 
 func (c *ddlCmd) Name() string {
 	return "ddl"
@@ -32,16 +70,80 @@ func (c *ddlCmd) Usage() string {
 `
 }
 
-func (c *ddlCmd) SetFlags(f *flag.FlagSet) {}
+func (c *ddlCmd) SetFlags(f *flag.FlagSet) {
+	c.storage.setFlags(f)
+	f.BoolVar(&c.skipUnchanged, "skip-unchanged", false, "Skip saving the DDL if the schema has not changed since the last snapshot.")
+	f.BoolVar(&c.normalize, "normalize", false, "Create a deterministic DDL without volatile attributes, suitable for version control.")
+	f.BoolVar(&c.stripDefiners, "strip-definers", false, "Remove DEFINER clauses from triggers and views (Requires --normalize).")
+	f.BoolVar(&c.split, "split", false, "Write one file per database object to ddl/<schema>/, plus an index.sql giving the apply order. Files of dropped objects are removed, so the whole schema is always written.")
+	f.StringVar(&c.compat, "compat", "", "Make the DDL compatible with an older server, one of mariadb or mysql57.")
+	f.StringVar(&c.template, "template", "", "A text/template file to render the DDL with instead of the default template.")
+	f.StringVar(&c.targetDialect, "target-dialect", "", "Convert the DDL to another dialect, postgres (MySQL only).")
+	f.StringVar(&c.tables, "tables", "", "A comma separated list of patterns of the tables to include, e.g. users,order_*.")
+	f.StringVar(&c.excludeTables, "exclude-tables", "", "A comma separated list of patterns of the tables to leave out of the DDL.")
+	f.DurationVar(&c.timeout, "timeout", 0, "The maximum duration of the run, e.g. 30m. If 0 (or not set), the run is not limited.")
+}
+
+func (c *ddlCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if c.stripDefiners && !c.normalize {
+		slog.Error("--strip-definers requires --normalize")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.split && c.skipUnchanged {
+		slog.Error("--skip-unchanged cannot be used with --split")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	// A split DDL replaces the files of every object, so it cannot be limited to some of the tables.
+	if c.split && (c.tables != "" || c.excludeTables != "") {
+		slog.Error("--tables and --exclude-tables cannot be used with --split")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.split && c.template != "" {
+		slog.Error("--template cannot be used with --split")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.targetDialect != "" && (c.split || c.compat != "") {
+		slog.Error("--target-dialect cannot be used with --split or --compat")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
 
-func (c *ddlCmd) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	dbConnEnv := new(DatabaseConnection)
 	if err := env.Parse(dbConnEnv); err != nil {
 		slog.Error("error parsing environment variables", slog.String("error", err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	db, err := sqlx.Connect("mysql", dbConnEnv.ConnStr)
+	driver, connStr, err := driverConnStr(dbConnEnv.ConnStr)
+	if err != nil {
+		slog.Error("error configuring connection string", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if driver != dumpster.DialectMySQL && (c.split || c.compat != "") {
+		slog.Error("--split and --compat are only supported for MySQL")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	db, err := sqlx.Connect(driver, connStr)
 	if err != nil {
 		slog.Error("error connecting to database", slog.String("error", err.Error()))
 		return subcommands.ExitFailure
@@ -53,45 +155,185 @@ func (c *ddlCmd) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{}) s
 		}
 	}()
 
-	d := dumpster.NewDumpster(db)
+	compat, err := dumpster.ParseCompat(c.compat)
+	if err != nil {
+		slog.Error("error parsing compatibility mode", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitUsageError
+	}
+
+	opts := []dumpster.Option{
+		dumpster.WithCompat(compat),
+		dumpster.WithTargetDialect(c.targetDialect),
+		dumpster.WithTables(splitList(c.tables)...),
+		dumpster.WithExcludeTables(splitList(c.excludeTables)...),
+	}
+
+	if c.template != "" {
+		t, err := dumpster.ParseTemplateFile(c.template)
+		if err != nil {
+			slog.Error("error loading template", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		opts = append(opts, dumpster.WithTemplate(t))
+	}
+
+	d, err := dumpster.NewDumpster(db, opts...)
+	if err != nil {
+		slog.Error("error creating dumpster", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitUsageError
+	}
+
+	if c.split {
+		return c.executeSplit(ctx, d)
+	}
 
-	ddlStr, err := d.GetDDL()
+	var ddlStr string
+	if c.normalize {
+		ddlStr, err = d.GetNormalizedDDL(ctx, c.stripDefiners)
+	} else {
+		ddlStr, err = d.GetDDL(ctx)
+	}
 	if err != nil {
 		slog.Error("error getting DDL", slog.String("error", err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	schemaName, err := d.GetSchemaName()
+	schemaName, err := d.GetSchemaName(ctx)
 	if err != nil {
 		slog.Error("error getting schema name", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	path := fmt.Sprintf("ddl/%s.sql", schemaName)
-
-	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	storageClient, err := c.storage.open(ctx)
 	if err != nil {
-		slog.Error("error creating directory", slog.String("error", err.Error()))
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	file, err := os.Create(path)
+	defer closeStorage(storageClient)
+
+	if c.skipUnchanged {
+		changed, err := ddlChanged(ctx, storageClient, schemaName, ddlStr)
+		if err != nil {
+			slog.Error("error checking for schema changes", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		if !changed {
+			slog.Info("Schema has not changed since the last snapshot, skipping", slog.String("schema", schemaName))
+			return subcommands.ExitSuccess
+		}
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	path := fmt.Sprintf("ddl/%s/%s.sql", schemaName, timestamp)
+
+	if err := storageClient.SaveFile(ctx, path, []byte(ddlStr)); err != nil {
+		slog.Error("error saving DDL", slog.String("error", err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	slog.Info("DDL file created", slog.String("path", path))
+
+	return subcommands.ExitSuccess
+}
+
+// ddlChanged reports whether the schema in ddl differs from the latest DDL snapshot of the schema in storage.
+func ddlChanged(ctx context.Context, sc dataaccess.Storage, schemaName string, ddl string) (bool, error) {
+	files, err := sc.ListFiles(ctx, fmt.Sprintf("ddl/%s/", schemaName))
+	if err != nil {
+		return false, fmt.Errorf("error listing DDL snapshots: %w", err)
+	}
+
+	latest, err := findBackup(files, time.Now().UTC())
+	if err != nil {
+		// There is no previous snapshot.
+		return true, nil
+	}
+
+	prev, err := sc.DownloadFile(ctx, latest.path)
 	if err != nil {
-		slog.Error("error creating file", slog.String("error", err.Error()))
+		return false, fmt.Errorf("error downloading DDL snapshot: %w", err)
+	}
+
+	prevSchema, err := schemadiff.Parse(string(prev))
+	if err != nil {
+		return false, fmt.Errorf("error parsing DDL snapshot %s: %w", latest.path, err)
+	}
+
+	curSchema, err := schemadiff.Parse(ddl)
+	if err != nil {
+		return false, fmt.Errorf("error parsing DDL: %w", err)
+	}
+
+	return !schemadiff.Diff(prevSchema, curSchema).Empty(), nil
+}
+
+// executeSplit writes the DDL as one file per database object.
+func (c *ddlCmd) executeSplit(ctx context.Context, d *dumpster.Dumpster) subcommands.ExitStatus {
+	files, err := d.GetSplitDDL(ctx, c.normalize, c.stripDefiners)
+	if err != nil {
+		slog.Error("error getting DDL", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	defer func() {
-		if err := file.Close(); err != nil {
-			slog.Warn("error closing file: %v", slog.String("error", err.Error()))
-		}
-	}()
+	schemaName, err := d.GetSchemaName(ctx)
+	if err != nil {
+		slog.Error("error getting schema name", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
 
-	_, err = file.WriteString(ddlStr)
+	storageClient, err := c.storage.open(ctx)
 	if err != nil {
-		slog.Error("error writing DDL to file", slog.String("error", err.Error()))
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
 
+	defer closeStorage(storageClient)
+
+	if err := saveSplitDDL(ctx, storageClient, schemaName, files); err != nil {
+		slog.Error("error saving DDL", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	slog.Info("DDL files created", slog.String("path", fmt.Sprintf("ddl/%s/", schemaName)), slog.Int("files", len(files)))
+
 	return subcommands.ExitSuccess
 }
+
+// saveSplitDDL saves the split DDL files under ddl/<schema>/ and removes the files of objects that no longer exist.
+func saveSplitDDL(ctx context.Context, sc dataaccess.Storage, schemaName string, files []*dumpster.DDLFile) error {
+	root := fmt.Sprintf("ddl/%s/", schemaName)
+
+	existing, err := sc.ListFiles(ctx, root)
+	if err != nil {
+		return fmt.Errorf("error listing existing DDL files: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(files))
+	for _, f := range files {
+		p := root + f.Path
+		wanted[p] = true
+
+		if err := sc.SaveFile(ctx, p, []byte(f.SQL)); err != nil {
+			return fmt.Errorf("error saving %s: %w", p, err)
+		}
+	}
+
+	for _, p := range existing {
+		// Only remove object files, timestamped snapshots in the same directory are kept.
+		rel := strings.TrimPrefix(p, root)
+		if !strings.Contains(rel, "/") || wanted[p] {
+			continue
+		}
+
+		if err := sc.DeleteFile(ctx, p); err != nil {
+			return fmt.Errorf("error deleting %s: %w", p, err)
+		}
+
+		slog.Info("Removed DDL file for dropped object", slog.String("path", p))
+	}
+
+	return nil
+}
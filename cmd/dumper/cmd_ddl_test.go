@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dataaccess"
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/google/subcommands"
+	"github.com/stretchr/testify/require"
+)
+
+const testDDL = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=10;\n" +
+	"-- Dump completed at 2026-10-14T03:00:00Z\n"
+
+func TestDDLChanged(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		prev  string
+		ddl   string
+		want  bool
+	}{
+		{
+			name:  "no previous snapshot",
+			files: []string{},
+			ddl:   testDDL,
+			want:  true,
+		},
+		{
+			name:  "only volatile attributes changed",
+			files: []string{"ddl/test/2026-10-13T03:00:00Z.sql", "ddl/test/2026-10-14T03:00:00Z.sql"},
+			prev:  testDDL,
+			ddl: "CREATE TABLE `users` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  PRIMARY KEY (`id`)\n" +
+				") ENGINE=InnoDB AUTO_INCREMENT=99;\n" +
+				"-- Dump completed at 2026-10-15T03:00:00Z\n",
+			want: false,
+		},
+		{
+			name:  "column added",
+			files: []string{"ddl/test/2026-10-14T03:00:00Z.sql"},
+			prev:  testDDL,
+			ddl: "CREATE TABLE `users` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(255) NOT NULL,\n" +
+				"  PRIMARY KEY (`id`)\n" +
+				") ENGINE=InnoDB AUTO_INCREMENT=10;\n",
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			sc := dataaccess.NewMockStorage(t)
+			sc.On("ListFiles", ctx, "ddl/test/").Return(tt.files, nil)
+			if tt.prev != "" {
+				sc.On("DownloadFile", ctx, "ddl/test/2026-10-14T03:00:00Z.sql").Return([]byte(tt.prev), nil)
+			}
+
+			got, err := ddlChanged(ctx, sc, "test", tt.ddl)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSaveSplitDDL(t *testing.T) {
+	ctx := context.Background()
+
+	files := []*dumpster.DDLFile{
+		{Path: "tables/users.sql", SQL: "CREATE TABLE `users` (`id` int);\n"},
+		{Path: dumpster.SplitIndexFile, SQL: "SOURCE tables/users.sql;\n"},
+	}
+
+	sc := dataaccess.NewMockStorage(t)
+	sc.On("ListFiles", ctx, "ddl/test/").Return([]string{
+		"ddl/test/2026-10-14T03:00:00Z.sql",
+		"ddl/test/index.sql",
+		"ddl/test/tables/users.sql",
+		"ddl/test/tables/dropped.sql",
+	}, nil)
+	sc.On("SaveFile", ctx, "ddl/test/tables/users.sql", []byte(files[0].SQL)).Return(nil)
+	sc.On("SaveFile", ctx, "ddl/test/index.sql", []byte(files[1].SQL)).Return(nil)
+	sc.On("DeleteFile", ctx, "ddl/test/tables/dropped.sql").Return(nil)
+
+	require.NoError(t, saveSplitDDL(ctx, sc, "test", files))
+}
+
+func TestDDLCmd_SplitFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  ddlCmd
+	}{
+		{
+			name: "tables",
+			cmd:  ddlCmd{split: true, tables: "users"},
+		},
+		{
+			name: "exclude tables",
+			cmd:  ddlCmd{split: true, excludeTables: "audit_*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The split DDL would remove the files of the tables left out, so the flags are rejected before connecting.
+			f := flag.NewFlagSet("ddl", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+
+			require.Equal(t, subcommands.ExitUsageError, tt.cmd.Execute(context.Background(), f))
+		})
+	}
+}
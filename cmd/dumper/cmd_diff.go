@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/schemadiff"
+	"github.com/caarlos0/env/v11"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/subcommands"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+const (
+	// sourceDB is the prefix for a live database source.
+	sourceDB = "db:"
+
+	// sourceGCS is the prefix for a GCS object source.
+	sourceGCS = "gcs:"
+
+	// sourceStorage is the prefix for a file in the storage selected by the storage flags.
+	sourceStorage = "storage:"
+
+	// sourceFile is the prefix for a local file source.
+	sourceFile = "file:"
+)
+
+type diffCmd struct {
+	// format is the output format of the diff.
+	format string
+
+	// storage selects the storage of storage: sources.
+	storage storageFlags
+}
+
+func (c *diffCmd) Name() string {
+	return "diff"
+}
+
+func (c *diffCmd) Synopsis() string {
+	return "Shows the schema differences between two DDL snapshots or databases"
+}
+
+func (c *diffCmd) Usage() string {
+	return `diff [--format text|json|sql] [storage flags] <from> <to>:
+  Shows the schema differences between two sources. A source can be:
+    db:<dsn>                A live database. If the DSN is empty, DUMPSTER_DB_CONN_STR is used.
+    file:<path>             A DDL or dump file on the local file system.
+    gcs:<bucket>/<object>   A DDL or dump object in GCS (Requires GCS_CREDENTIALS environment variable to be set).
+    storage:<path>          A DDL or dump file in the storage selected by --dest or the per-backend flags.
+  A source without a prefix is treated as a local file.
+`
+}
+
+func (c *diffCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.format, "format", "text", "The output format: text, json or sql (an ALTER migration script).")
+	c.storage.setFlags(f)
+}
+
+func (c *diffCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		slog.Error("diff requires exactly two sources")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	switch c.format {
+	case "text", "json", "sql":
+	default:
+		slog.Error("invalid format", slog.String("format", c.format))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	from, err := c.loadSchema(ctx, f.Arg(0))
+	if err != nil {
+		slog.Error("error loading source schema", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	to, err := c.loadSchema(ctx, f.Arg(1))
+	if err != nil {
+		slog.Error("error loading target schema", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	res := schemadiff.Diff(from, to)
+
+	switch c.format {
+	case "json":
+		if err := json.NewEncoder(os.Stdout).Encode(res); err != nil {
+			slog.Error("error writing diff", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+	case "sql":
+		fmt.Print(res.SQL())
+	default:
+		fmt.Print(res.Text())
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// loadSchema loads and parses the DDL from the given source.
+func (c *diffCmd) loadSchema(ctx context.Context, source string) (*schemadiff.Schema, error) {
+	ddl, err := c.loadDDL(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := schemadiff.Parse(ddl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", source, err)
+	}
+
+	return s, nil
+}
+
+// loadDDL returns the DDL for the given source.
+func (c *diffCmd) loadDDL(ctx context.Context, source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, sourceDB):
+		connStr := strings.TrimPrefix(source, sourceDB)
+		if connStr == "" {
+			dbConnEnv := new(DatabaseConnection)
+			if err := env.Parse(dbConnEnv); err != nil {
+				return "", fmt.Errorf("error parsing environment variables: %w", err)
+			}
+			connStr = dbConnEnv.ConnStr
+		}
+
+		driver, connStr, err := driverConnStr(connStr)
+		if err != nil {
+			return "", fmt.Errorf("error configuring connection string: %w", err)
+		}
+
+		db, err := sqlx.Connect(driver, connStr)
+		if err != nil {
+			return "", fmt.Errorf("error connecting to database: %w", err)
+		}
+
+		defer func() {
+			if err := db.Close(); err != nil {
+				slog.Warn("error closing database", slog.String(logging.KeyError, err.Error()))
+			}
+		}()
+
+		d, err := dumpster.NewDumpster(db)
+		if err != nil {
+			return "", fmt.Errorf("error creating dumpster: %w", err)
+		}
+
+		ddl, err := d.GetDDL(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error getting DDL: %w", err)
+		}
+
+		return ddl, nil
+	case strings.HasPrefix(source, sourceGCS):
+		bucket, object, ok := strings.Cut(strings.TrimPrefix(source, sourceGCS), "/")
+		if !ok || bucket == "" || object == "" {
+			return "", fmt.Errorf("invalid GCS source %q, expected gcs:<bucket>/<object>", source)
+		}
+
+		return downloadDDL(ctx, &storageFlags{gcs: bucket}, object)
+	case strings.HasPrefix(source, sourceStorage):
+		object := strings.TrimPrefix(source, sourceStorage)
+		if object == "" {
+			return "", fmt.Errorf("invalid storage source %q, expected storage:<path>", source)
+		}
+
+		return downloadDDL(ctx, &c.storage, object)
+	default:
+		fc, err := os.ReadFile(strings.TrimPrefix(source, sourceFile))
+		if err != nil {
+			return "", fmt.Errorf("error reading file: %w", err)
+		}
+
+		return string(fc), nil
+	}
+}
+
+// downloadDDL returns the DDL in the file of the storage selected by the flags.
+func downloadDDL(ctx context.Context, flags *storageFlags, filePath string) (string, error) {
+	sc, err := flags.open(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error initializing storage: %w", err)
+	}
+
+	defer closeStorage(sc)
+
+	fc, err := sc.DownloadFile(ctx, filePath)
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %w", filePath, err)
+	}
+
+	return string(fc), nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCmd_LoadDDL(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ddl", "app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ddl", "app", "schema.sql"), []byte("CREATE TABLE t (id INT);\n"), 0o600))
+
+	c := &diffCmd{storage: storageFlags{dests: destFlag{"file://" + filepath.ToSlash(dir)}}}
+
+	ddl, err := c.loadDDL(context.Background(), "storage:ddl/app/schema.sql")
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t (id INT);\n", ddl)
+
+	_, err = c.loadDDL(context.Background(), "storage:ddl/app/missing.sql")
+	require.ErrorContains(t, err, "error downloading ddl/app/missing.sql")
+
+	_, err = c.loadDDL(context.Background(), "storage:")
+	require.ErrorContains(t, err, "expected storage:<path>")
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/Jacobbrewer1/dumpster/pkg/dataaccess"
 	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
 	"github.com/Jacobbrewer1/dumpster/pkg/logging"
@@ -16,15 +17,77 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/subcommands"
 	"github.com/jmoiron/sqlx"
-	"google.golang.org/api/option"
+	_ "github.com/lib/pq"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
 type dumpCmd struct {
-	// gcs is the bucket to upload the dump to. Setting this will enable GCS.
-	gcs string
+	// storage selects the storage to upload the dump to.
+	storage storageFlags
 
 	// purge is the number of days to keep data for. If 0 (or not set), data will not be purged.
 	purge int
+
+	// grants will include the users, roles and grants for the schema in the dump.
+	grants bool
+
+	// compat is the server to make the dump compatible with, mariadb or mysql57.
+	compat string
+
+	// template is the path of a text/template file to render the dump with instead of the default template.
+	template string
+
+	// backup will save a consistent copy of a SQLite database file instead of an SQL dump.
+	backup bool
+
+	// targetDialect is the dialect to convert the dump to, postgres. The dialect of the database is used if empty.
+	targetDialect string
+
+	// tables is a comma separated list of patterns of the tables to dump. Every table is dumped if empty.
+	tables string
+
+	// excludeTables is a comma separated list of patterns of the tables to leave out of the dump.
+	excludeTables string
+
+	// excludeData is a comma separated list of patterns of the tables to dump without their rows.
+	excludeData string
+
+	// batchSize is the maximum number of rows per INSERT statement. If 0, each table is a single statement.
+	batchSize int
+
+	// chunkSize is the number of rows read from a table with a primary key in each query. If 0, the default is used.
+	chunkSize int
+
+	// snapshot will read every table in a single transaction for a consistent dump.
+	snapshot bool
+
+	// maxRowsPerSecond is the maximum number of rows read per second. If 0, the rows are not limited.
+	maxRowsPerSecond int
+
+	// maxBytesPerSecond is the maximum number of bytes read per second. If 0, the bytes are not limited.
+	maxBytesPerSecond int
+
+	// maxThreadsRunning pauses reading while Threads_running is above it. If 0, Threads_running is not checked.
+	maxThreadsRunning int
+
+	// loadProbe is a query returning a single number that pauses reading while it is above loadThreshold.
+	loadProbe string
+
+	// loadThreshold is the value of loadProbe above which reading pauses.
+	loadThreshold float64
+
+	// maxReplicaLag pauses reading while the replication lag is above it. If 0, the lag is not checked.
+	maxReplicaLag time.Duration
+
+	// failReplicaLag fails the dump if the replication lag is above it. If 0, the dump does not fail on lag.
+	failReplicaLag time.Duration
+
+	// progressInterval is how often the progress of the dump is logged. If 0, the progress is not logged.
+	progressInterval time.Duration
+
+	// timeout is the maximum duration of the run. If 0, the run is not limited.
+	timeout time.Duration
 }
 
 func (c *dumpCmd) Name() string {
@@ -32,21 +95,44 @@ func (c *dumpCmd) Name() string {
 }
 
 func (c *dumpCmd) Synopsis() string {
-	return "Creates a MySQL dump of the database"
+	return "Creates a dump of the database"
 }
 
 func (c *dumpCmd) Usage() string {
 	return `dump:
-  Creates a MySQL dump of the database.
+  Creates a dump of a MySQL, PostgreSQL or SQLite database.
 `
 }
 
 func (c *dumpCmd) SetFlags(f *flag.FlagSet) {
-	f.StringVar(&c.gcs, "gcs", "", "The GCS bucket to upload the dump to (Requires GCS_CREDENTIALS environment variable to be set)")
+	c.storage.setFlags(f)
 	f.IntVar(&c.purge, "purge", 0, "The number of days to keep data for. If 0 (or not set), data will not be purged.")
+	f.BoolVar(&c.grants, "grants", false, "Include the users, roles and grants with privileges on the schema in the dump.")
+	f.StringVar(&c.compat, "compat", "", "Make the dump compatible with an older server, one of mariadb or mysql57.")
+	f.StringVar(&c.template, "template", "", "A text/template file to render the dump with instead of the default template.")
+	f.BoolVar(&c.backup, "backup", false, "Save a consistent copy of the SQLite database file instead of an SQL dump (SQLite only).")
+	f.StringVar(&c.targetDialect, "target-dialect", "", "Convert the dump to another dialect, postgres (MySQL only).")
+	f.StringVar(&c.tables, "tables", "", "A comma separated list of patterns of the tables to dump, e.g. users,order_*.")
+	f.StringVar(&c.excludeTables, "exclude-tables", "", "A comma separated list of patterns of the tables to leave out of the dump.")
+	f.StringVar(&c.excludeData, "exclude-data", "", "A comma separated list of patterns of the tables to dump without their rows.")
+	f.IntVar(&c.batchSize, "batch-size", 0, "The maximum number of rows per INSERT statement. If 0 (or not set), each table is a single statement.")
+	f.IntVar(&c.chunkSize, "chunk-size", 0, "The number of rows read from a table with a primary key in each query. Throttling waits between queries. If 0 (or not set), 10000 rows are read at a time.")
+	f.BoolVar(&c.snapshot, "snapshot", false, "Read every table in a single transaction for a consistent dump.")
+	f.IntVar(&c.maxRowsPerSecond, "max-rows-per-second", 0, "The maximum number of rows read per second. If 0 (or not set), the rows are not limited.")
+	f.IntVar(&c.maxBytesPerSecond, "max-bytes-per-second", 0, "The maximum number of bytes read per second. If 0 (or not set), the bytes are not limited.")
+	f.IntVar(&c.maxThreadsRunning, "max-threads-running", 0, "Pause reading while the Threads_running of the server is above this (MySQL only). If 0 (or not set), it is not checked.")
+	f.StringVar(&c.loadProbe, "load-probe", "", "A query returning a single number. Reading pauses while the result is above --load-threshold.")
+	f.Float64Var(&c.loadThreshold, "load-threshold", 0, "The result of --load-probe above which reading pauses.")
+	f.DurationVar(&c.maxReplicaLag, "max-replica-lag", 0, "Pause reading while the replication lag of the replica is above this, e.g. 30s (MySQL only). If 0 (or not set), the lag is not checked.")
+	f.DurationVar(&c.failReplicaLag, "fail-replica-lag", 0, "Fail the dump if the replication lag is above this (Requires --max-replica-lag). If 0 (or not set), the dump does not fail on lag.")
+	f.DurationVar(&c.progressInterval, "progress-interval", 10*time.Second, "How often the progress of the dump is logged, with an estimate of the time remaining. If 0, the progress is not logged.")
+	f.DurationVar(&c.timeout, "timeout", 0, "The maximum duration of the run, e.g. 30m. If 0 (or not set), the run is not limited.")
 }
 
 func (c *dumpCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+
 	err := logging.Init(appName)
 	if err != nil {
 		slog.Error("error initializing logging", slog.String(logging.KeyError, err.Error()))
@@ -59,8 +145,50 @@ func (c *dumpCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}
 		return subcommands.ExitFailure
 	}
 
+	driver, connStr, err := driverConnStr(dbConnEnv.ConnStr)
+	if err != nil {
+		slog.Error("error configuring connection string", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if driver != dumpster.DialectMySQL && (c.grants || c.compat != "") {
+		slog.Error("--grants and --compat are only supported for MySQL")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.backup && driver != dumpster.DialectSQLite {
+		slog.Error("--backup is only supported for SQLite")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.targetDialect != "" && (c.grants || c.compat != "") {
+		slog.Error("--target-dialect cannot be used with --grants or --compat")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.maxThreadsRunning > 0 && (driver != dumpster.DialectMySQL || c.loadProbe != "") {
+		slog.Error("--max-threads-running is only supported for MySQL and cannot be used with --load-probe")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if c.failReplicaLag > 0 && c.maxReplicaLag == 0 {
+		slog.Error("--fail-replica-lag requires --max-replica-lag")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
 	// Open database connection
-	db, err := sqlx.Open("mysql", dbConnEnv.ConnStr)
+	db, err := sqlx.Open(driver, connStr)
 	if err != nil {
 		slog.Error("error opening database", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
@@ -73,59 +201,132 @@ func (c *dumpCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}
 		}
 	}(db)
 
-	// Create a new dumpster
-	d := dumpster.NewDumpster(db)
+	compat, err := dumpster.ParseCompat(c.compat)
+	if err != nil {
+		slog.Error("error parsing compatibility mode", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitUsageError
+	}
 
-	// Create the dump
-	fc, err := d.Dump()
+	opts := []dumpster.Option{
+		dumpster.WithGrants(c.grants),
+		dumpster.WithCompat(compat),
+		dumpster.WithTargetDialect(c.targetDialect),
+		dumpster.WithTables(splitList(c.tables)...),
+		dumpster.WithExcludeTables(splitList(c.excludeTables)...),
+		dumpster.WithExcludeData(splitList(c.excludeData)...),
+		dumpster.WithBatchSize(c.batchSize),
+		dumpster.WithChunkSize(c.chunkSize),
+		dumpster.WithSnapshot(c.snapshot),
+	}
+
+	throttle := dumpster.Throttle{
+		RowsPerSecond:  c.maxRowsPerSecond,
+		BytesPerSecond: c.maxBytesPerSecond,
+	}
+
+	switch {
+	case c.maxThreadsRunning > 0:
+		throttle.Probe = dumpster.ThreadsRunningProbe(db)
+		throttle.Threshold = float64(c.maxThreadsRunning)
+	case c.loadProbe != "":
+		throttle.Probe = dumpster.SQLProbe(db, c.loadProbe)
+		throttle.Threshold = c.loadThreshold
+	}
+
+	opts = append(opts, dumpster.WithThrottle(throttle))
+
+	if c.maxReplicaLag > 0 {
+		opts = append(opts, dumpster.WithReplicaLag(dumpster.ReplicaLag{
+			Pause: c.maxReplicaLag,
+			Fail:  c.failReplicaLag,
+		}))
+	}
+
+	var progress *progressLogger
+	if c.progressInterval > 0 {
+		progress = newProgressLogger()
+		opts = append(opts, dumpster.WithProgress(progress.handle))
+	}
+
+	if c.template != "" {
+		t, err := dumpster.ParseTemplateFile(c.template)
+		if err != nil {
+			slog.Error("error loading template", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		opts = append(opts, dumpster.WithTemplate(t))
+	}
+
+	// Create a new dumpster
+	d, err := dumpster.NewDumpster(db, opts...)
 	if err != nil {
-		slog.Error("error creating dump", slog.String(logging.KeyError, err.Error()))
-		return subcommands.ExitFailure
+		slog.Error("error creating dumpster", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitUsageError
 	}
 
-	schemaName, err := d.GetSchemaName()
+	schemaName, err := d.GetSchemaName(ctx)
 	if err != nil {
 		slog.Error("error getting schema name", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
 
-	timestamp := time.Now().UTC().Format(time.RFC3339)
-	path := fmt.Sprintf("dumps/%s/%s.sql", schemaName, timestamp)
-
-	var storageClient dataaccess.Storage
+	storageClient, err := c.storage.open(ctx)
+	if err != nil {
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
 
-	switch {
-	case c.gcs != "":
-		// Get the service account credentials from the environment variable.
-		gcsCredentials := os.Getenv(dataaccess.EnvGCSCredentials)
-		if gcsCredentials == "" {
-			slog.Error("GCS_CREDENTIALS environment variable not set")
-			return subcommands.ExitUsageError
-		}
+	defer closeStorage(storageClient)
 
-		client, err := storage.NewClient(ctx, option.WithCredentialsJSON([]byte(gcsCredentials)))
+	// Create the dump
+	var fc io.Reader
+	ext := ".sql"
+	if c.backup {
+		var dir string
+		dir, err = os.MkdirTemp("", "dumpster-backup-")
 		if err != nil {
-			slog.Error("error creating GCS client", slog.String(logging.KeyError, err.Error()))
+			slog.Error("error creating temporary directory", slog.String(logging.KeyError, err.Error()))
 			return subcommands.ExitFailure
 		}
-		cs := client
 
-		_, err = cs.Bucket(c.gcs).Attrs(ctx)
-		if err != nil {
-			slog.Error("error checking bucket", slog.String(logging.KeyError, err.Error()))
-			return subcommands.ExitFailure
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				slog.Warn("Error removing temporary directory", slog.String(logging.KeyError, err.Error()))
+			}
+		}()
+
+		var f *os.File
+		f, err = backupFile(ctx, d, dir)
+		if f != nil {
+			defer func(f *os.File) {
+				if err := f.Close(); err != nil {
+					slog.Warn("Error closing backup", slog.String(logging.KeyError, err.Error()))
+				}
+			}(f)
 		}
 
-		storageClient = dataaccess.NewGCS(cs, c.gcs)
 		if err != nil {
-			slog.Error("error initializing GCS", slog.String(logging.KeyError, err.Error()))
+			slog.Error("error creating dump", slog.String(logging.KeyError, err.Error()))
 			return subcommands.ExitFailure
 		}
-	default:
-		// Locally store the dump
-		storageClient = dataaccess.NewLocal()
+
+		fc, ext = f, ".sqlite"
+	} else {
+		// The dump is rendered into the upload as it is written, and the upload fails if the dump fails.
+		r := c.streamDump(ctx, d, progress)
+		defer func(r io.ReadCloser) {
+			if err := r.Close(); err != nil {
+				slog.Warn("Error closing dump", slog.String(logging.KeyError, err.Error()))
+			}
+		}(r)
+
+		fc = r
 	}
 
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	path := fmt.Sprintf("dumps/%s/%s%s", schemaName, timestamp, ext)
+
 	if err := c.saveDump(ctx, storageClient, fc, path); err != nil {
 		slog.Error("error saving dump", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
@@ -134,7 +335,7 @@ func (c *dumpCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}
 	slog.Info("Dump file created", slog.String("path", path))
 
 	// Purge the data
-	if err := purgeData(ctx, storageClient, c.purge); err != nil {
+	if err := purgeData(ctx, storageClient, c.purge, c.storage.retained()); err != nil {
 		slog.Error("error purging data", slog.String(logging.KeyError, err.Error()))
 		return subcommands.ExitFailure
 	}
@@ -142,12 +343,86 @@ func (c *dumpCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}
 	return subcommands.ExitSuccess
 }
 
-func (c *dumpCmd) saveDump(ctx context.Context, sc dataaccess.Storage, fileContents string, path string) error {
-	// Upload the dump
-	err := sc.SaveFile(ctx, path, []byte(fileContents))
+// saveDump streams the dump to the storage. Nothing is saved if reading the dump or uploading it fails.
+func (c *dumpCmd) saveDump(ctx context.Context, sc dataaccess.Storage, fc io.Reader, path string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := sc.Writer(ctx, path)
 	if err != nil {
 		return fmt.Errorf("error uploading dump: %w", err)
 	}
 
+	if _, err := io.Copy(w, fc); err != nil {
+		// Cancelling the context abandons the upload.
+		cancel()
+		_ = w.Close()
+		return fmt.Errorf("error uploading dump: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error uploading dump: %w", err)
+	}
+
 	return nil
 }
+
+// streamDump creates the dump in the background, logging its progress at the progress interval if progress is set,
+// and returns a reader of its content. The reader fails with the error of the dump if it fails. Closing the reader
+// stops the dump and waits for it to return.
+func (c *dumpCmd) streamDump(ctx context.Context, d *dumpster.Dumpster, progress *progressLogger) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if progress != nil {
+			progressCtx, stopProgress := context.WithCancel(ctx)
+			defer stopProgress()
+
+			go progress.run(progressCtx, c.progressInterval)
+		}
+
+		pw.CloseWithError(d.DumpTo(ctx, pw))
+	}()
+
+	return &dumpReader{
+		PipeReader: pr,
+		stop: func() {
+			cancel()
+			<-done
+		},
+	}
+}
+
+// dumpReader is the reader of a dump created in the background.
+type dumpReader struct {
+	*io.PipeReader
+
+	// stop stops the dump and waits for it to return.
+	stop func()
+}
+
+// Close stops the dump, failing its writes, and waits for it to return.
+func (r *dumpReader) Close() error {
+	err := r.PipeReader.Close()
+	r.stop()
+	return err
+}
+
+// backupFile writes a consistent copy of the SQLite database to the directory, and returns it opened for reading.
+func backupFile(ctx context.Context, d *dumpster.Dumpster, dir string) (*os.File, error) {
+	p := filepath.Join(dir, "backup.sqlite")
+	if err := d.Backup(ctx, p); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("error opening backup: %w", err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDump(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "app.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO users VALUES (1, 'alice');`)
+	require.NoError(t, err)
+
+	d, err := dumpster.NewDumpster(db)
+	require.NoError(t, err)
+
+	c := &dumpCmd{progressInterval: time.Minute}
+
+	t.Run("read", func(t *testing.T) {
+		r := c.streamDump(context.Background(), d, nil)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Contains(t, string(got), "-- Data dump for table users\nINSERT INTO \"users\" VALUES")
+	})
+
+	t.Run("closed before reading", func(t *testing.T) {
+		r := c.streamDump(context.Background(), d, newProgressLogger())
+		require.NoError(t, r.Close())
+	})
+
+	t.Run("failing dump", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		r := c.streamDump(ctx, d, nil)
+		_, err := io.ReadAll(r)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NoError(t, r.Close())
+	})
+}
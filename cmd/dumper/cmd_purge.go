@@ -8,19 +8,17 @@ import (
 	"os"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/Jacobbrewer1/dumpster/pkg/dataaccess"
 	"github.com/Jacobbrewer1/dumpster/pkg/logging"
 	"github.com/google/subcommands"
-	"google.golang.org/api/option"
 )
 
 type purgeCmd struct {
 	// days is the number of days to keep data for. If 0 (or not set), data will not be purged.
 	days int
 
-	// gcs is the name of the Google Cloud Storage bucket to use. Setting this will enable GCS.
-	gcs string
+	// storage selects the storage to purge.
+	storage storageFlags
 }
 
 func (p *purgeCmd) Name() string {
@@ -39,57 +37,26 @@ func (p *purgeCmd) Usage() string {
 
 func (p *purgeCmd) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&p.days, "days", 0, "The number of days to keep data for. If 0 (or not set), data will not be purged.")
-	f.StringVar(&p.gcs, "gcs", "", "The name of the Google Cloud Storage bucket to use. (Setting this will enable GCS)")
+	p.storage.setFlags(f)
 }
 
 func (p *purgeCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	// Check if the GCS environment variable is set
-	if p.gcs != "" {
-		got := os.Getenv(dataaccess.EnvGCSCredentials)
-		if got == "" {
-			slog.Error("GCS_CREDENTIALS environment variable not set")
-			f.Usage()
-			return subcommands.ExitUsageError
-		}
+	if _, err := p.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
 	}
 
-	// Initialize the GCS client
-	var storageClient dataaccess.Storage
-
-	switch {
-	case p.gcs != "":
-		// Get the service account credentials from the environment variable.
-		gcsCredentials := os.Getenv(dataaccess.EnvGCSCredentials)
-		if gcsCredentials == "" {
-			slog.Error("GCS_CREDENTIALS environment variable not set")
-			return subcommands.ExitUsageError
-		}
-
-		client, err := storage.NewClient(ctx, option.WithCredentialsJSON([]byte(gcsCredentials)))
-		if err != nil {
-			slog.Error("error creating GCS client", slog.String(logging.KeyError, err.Error()))
-			return subcommands.ExitFailure
-		}
-		cs := client
-
-		_, err = cs.Bucket(p.gcs).Attrs(ctx)
-		if err != nil {
-			slog.Error("error checking bucket", slog.String(logging.KeyError, err.Error()))
-			return subcommands.ExitFailure
-		}
-
-		storageClient = dataaccess.NewGCS(cs, p.gcs)
-		if err != nil {
-			slog.Error("error initializing GCS", slog.String(logging.KeyError, err.Error()))
-			return subcommands.ExitFailure
-		}
-	default:
-		// Locally store the dump
-		storageClient = dataaccess.NewLocal()
+	storageClient, err := p.storage.open(ctx)
+	if err != nil {
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
 	}
 
+	defer closeStorage(storageClient)
+
 	// Purge the data
-	err := purgeData(ctx, storageClient, p.days)
+	err = purgeData(ctx, storageClient, p.days, p.storage.retained())
 	if err != nil {
 		slog.Error("error purging data", slog.String("error", err.Error()))
 		return subcommands.ExitFailure
@@ -98,18 +65,39 @@ func (p *purgeCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{
 	return subcommands.ExitSuccess
 }
 
-func purgeData(ctx context.Context, r dataaccess.Storage, days int) error {
-	if days == 0 {
+// purgeData purges the dumps older than the number of days from the storage. If days is 0, only the destinations with
+// their own retention are purged, and only if retained is set.
+func purgeData(ctx context.Context, r dataaccess.Storage, days int, retained bool) error {
+	if days == 0 && !retained {
 		slog.Debug("Days to purge is 0, data will not be purged")
 		return nil
 	}
 
-	// Calculate the date to purge from
-	from := time.Now().UTC().AddDate(0, 0, -days)
+	var from time.Time
+	if days > 0 {
+		// Calculate the date to purge from
+		from = time.Now().UTC().AddDate(0, 0, -days)
 
-	// Set the purge date to midnight
-	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+		// Set the purge date to midnight
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
 
+		if err := purgeLocal(from); err != nil {
+			return err
+		}
+	}
+
+	// Purge the data
+	num, err := r.Purge(ctx, from)
+	if err != nil {
+		return fmt.Errorf("error purging data from storage: %w", err)
+	}
+	slog.Info(fmt.Sprintf("Purged %d files from storage", num))
+
+	return nil
+}
+
+// purgeLocal purges the dumps taken before the time from the local dumps directory.
+func purgeLocal(from time.Time) error {
 	// Check to see if the dumps directory exists
 	_, err := os.Stat("dumps")
 	if os.IsNotExist(err) {
@@ -167,12 +155,5 @@ func purgeData(ctx context.Context, r dataaccess.Storage, days int) error {
 		}
 	}
 
-	// Purge the data
-	num, err := r.Purge(ctx, from)
-	if err != nil {
-		return fmt.Errorf("error purging data from GCS: %w", err)
-	}
-	slog.Info(fmt.Sprintf("Purged %d files from GCS", num))
-
 	return nil
 }
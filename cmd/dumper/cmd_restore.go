@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/caarlos0/env/v11"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/subcommands"
+	"github.com/jmoiron/sqlx"
+)
+
+type restoreCmd struct {
+	// storage selects the storage to restore the dump from.
+	storage storageFlags
+
+	// asOf is the point in time to restore to. The newest dump taken at or before this time will be restored, without
+	// replaying the changes made after it.
+	asOf string
+
+	// file is the path of the dump to restore. This takes precedence over asOf.
+	file string
+
+	// schema is the name of the schema to restore. If not set, the schema from the connection string is used.
+	schema string
+
+	// dryRun will only show the dump that would be restored.
+	dryRun bool
+
+	// timeout is the maximum duration of the run. If 0, the run is not limited.
+	timeout time.Duration
+}
+
+func (c *restoreCmd) Name() string {
+	return "restore"
+}
+
+func (c *restoreCmd) Synopsis() string {
+	return "Restores a MySQL dump into the database"
+}
+
+func (c *restoreCmd) Usage() string {
+	return `restore:
+  Restores a MySQL dump into the database.
+`
+}
+
+func (c *restoreCmd) SetFlags(f *flag.FlagSet) {
+	c.storage.setFlags(f)
+	f.StringVar(&c.asOf, "as-of", "", "The point in time to restore to in RFC3339 format. The newest dump taken at or before this time is restored. Binary logs are not replayed, so changes made after the dump are not restored.")
+	f.StringVar(&c.file, "file", "", "The path of the dump to restore. This takes precedence over --as-of.")
+	f.StringVar(&c.schema, "schema", "", "The schema to restore. If not set, the schema from the connection string is used.")
+	f.BoolVar(&c.dryRun, "dry-run", false, "Only show the dump that would be restored.")
+	f.DurationVar(&c.timeout, "timeout", 0, "The maximum duration of the run, e.g. 30m. If 0 (or not set), the run is not limited.")
+}
+
+func (c *restoreCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := logging.Init(appName)
+	if err != nil {
+		slog.Error("error initializing logging", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if c.file == "" && c.asOf == "" {
+		slog.Error("either --file or --as-of must be set")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	asOf := time.Now().UTC()
+	if c.asOf != "" {
+		asOf, err = time.Parse(time.RFC3339, c.asOf)
+		if err != nil {
+			slog.Error("error parsing --as-of", slog.String(logging.KeyError, err.Error()))
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+	}
+
+	dbConnEnv := new(DatabaseConnection)
+	if err := env.Parse(dbConnEnv); err != nil {
+		slog.Error("error parsing environment variables", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	connStr, err := multiStatementConnStr(dbConnEnv.ConnStr)
+	if err != nil {
+		slog.Error("error configuring connection string", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	// Open database connection
+	db, err := sqlx.Open("mysql", connStr)
+	if err != nil {
+		slog.Error("error opening database", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	// Close the database connection
+	defer func(db *sqlx.DB) {
+		if err := db.Close(); err != nil {
+			slog.Warn("Error closing database connection", slog.String(logging.KeyError, err.Error()))
+		}
+	}(db)
+
+	d, err := dumpster.NewDumpster(db)
+	if err != nil {
+		slog.Error("error creating dumpster", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	schemaName := c.schema
+	if schemaName == "" {
+		schemaName, err = d.GetSchemaName(ctx)
+		if err != nil {
+			slog.Error("error getting schema name", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+	}
+
+	storageClient, err := c.storage.open(ctx)
+	if err != nil {
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	defer closeStorage(storageClient)
+
+	filePath := c.file
+	if filePath == "" {
+		files, err := storageClient.ListFiles(ctx, fmt.Sprintf("dumps/%s/", schemaName))
+		if err != nil {
+			slog.Error("error listing dumps", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		b, err := findBackup(files, asOf)
+		if err != nil {
+			slog.Error("error finding dump to restore", slog.String(logging.KeyError, err.Error()),
+				slog.String("schema", schemaName), slog.String("as_of", asOf.Format(time.RFC3339)))
+			return subcommands.ExitFailure
+		}
+
+		filePath = b.path
+
+		// Binary logs are not archived, so the changes between the dump and the requested time cannot be replayed.
+		if b.takenAt.Before(asOf) {
+			slog.Warn("Point-in-time replay is not supported, changes made after the dump are not restored",
+				slog.String("dump_time", b.takenAt.Format(time.RFC3339)),
+				slog.String("as_of", asOf.Format(time.RFC3339)),
+				slog.String("not_restored", asOf.Sub(b.takenAt).String()),
+			)
+		}
+	}
+
+	slog.Info("Selected dump to restore",
+		slog.String("schema", schemaName),
+		slog.String("as_of", asOf.Format(time.RFC3339)),
+		slog.String("path", filePath),
+	)
+
+	if c.dryRun {
+		return subcommands.ExitSuccess
+	}
+
+	fc, err := storageClient.DownloadFile(ctx, filePath)
+	if err != nil {
+		slog.Error("error downloading dump", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if err := d.Restore(ctx, string(fc)); err != nil {
+		slog.Error("error restoring dump", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	slog.Info("Dump restored", slog.String("path", filePath))
+
+	return subcommands.ExitSuccess
+}
+
+// backup is a dump file in storage along with the time it was taken.
+type backup struct {
+	// path is the path of the dump in storage.
+	path string
+
+	// takenAt is the time the dump was taken, parsed from the file name.
+	takenAt time.Time
+}
+
+// findBackup returns the newest dump in files that was taken at or before asOf. The dump time is read from the
+// dumps/<schema>/<RFC3339>.sql naming.
+func findBackup(files []string, asOf time.Time) (*backup, error) {
+	var found *backup
+	for _, f := range files {
+		name := path.Base(f)
+		if !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		takenAt, err := time.Parse(time.RFC3339, strings.TrimSuffix(name, ".sql"))
+		if err != nil {
+			slog.Debug(fmt.Sprintf("Error parsing file date from file name: %s", name))
+			continue
+		}
+
+		if takenAt.After(asOf) {
+			continue
+		}
+
+		if found == nil || takenAt.After(found.takenAt) {
+			found = &backup{
+				path:    f,
+				takenAt: takenAt,
+			}
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("no dump found at or before the given time")
+	}
+
+	return found, nil
+}
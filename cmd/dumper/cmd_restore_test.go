@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBackup(t *testing.T) {
+	files := []string{
+		"dumps/test/2026-10-12T03:00:00Z.sql",
+		"dumps/test/2026-10-13T03:00:00Z.sql",
+		"dumps/test/2026-10-14T03:00:00Z.sql",
+		"dumps/test/2026-10-15T03:00:00Z.sql",
+		"dumps/test/notes.txt",
+		"dumps/test/not-a-date.sql",
+	}
+
+	tests := []struct {
+		name    string
+		asOf    time.Time
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "exact match",
+			asOf: time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC),
+			want: "dumps/test/2026-10-14T03:00:00Z.sql",
+		},
+		{
+			name: "between dumps",
+			asOf: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+			want: "dumps/test/2026-10-14T03:00:00Z.sql",
+		},
+		{
+			name: "after all dumps",
+			asOf: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			want: "dumps/test/2026-10-15T03:00:00Z.sql",
+		},
+		{
+			name:    "before all dumps",
+			asOf:    time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findBackup(files, tt.asOf)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.path)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/caarlos0/env/v11"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/subcommands"
+	"github.com/jmoiron/sqlx"
+)
+
+type verifyCmd struct {
+	// storage selects the storage to verify the dump from.
+	storage storageFlags
+
+	// file is the path of the dump to verify. If not set, the newest dump for the schema is verified.
+	file string
+
+	// schema is the name of the schema to verify. If not set, the schema from the connection string is used.
+	schema string
+
+	// timeout is the maximum duration of the run. If 0, the run is not limited.
+	timeout time.Duration
+}
+
+// verifyOutput is the structured report written to stdout by the verify command.
+type verifyOutput struct {
+	// Path is the path of the dump that was verified.
+	Path string `json:"path"`
+
+	// Schema is the source schema the dump was compared against.
+	Schema string `json:"schema"`
+
+	// ScratchSchema is the temporary schema the dump was restored into.
+	ScratchSchema string `json:"scratch_schema"`
+
+	*dumpster.VerifyReport
+}
+
+func (c *verifyCmd) Name() string {
+	return "verify"
+}
+
+func (c *verifyCmd) Synopsis() string {
+	return "Verifies a MySQL dump by restoring it into a scratch schema"
+}
+
+func (c *verifyCmd) Usage() string {
+	return `verify:
+  Verifies a MySQL dump by restoring it into a scratch schema and comparing it against the source database.
+`
+}
+
+func (c *verifyCmd) SetFlags(f *flag.FlagSet) {
+	c.storage.setFlags(f)
+	f.StringVar(&c.file, "file", "", "The path of the dump to verify. If not set, the newest dump for the schema is verified.")
+	f.StringVar(&c.schema, "schema", "", "The schema to verify. If not set, the schema from the connection string is used.")
+	f.DurationVar(&c.timeout, "timeout", 0, "The maximum duration of the run, e.g. 30m. If 0 (or not set), the run is not limited.")
+}
+
+func (c *verifyCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err := logging.Init(appName)
+	if err != nil {
+		slog.Error("error initializing logging", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if _, err := c.storage.destinations(); err != nil {
+		slog.Error("error validating storage flags", slog.String(logging.KeyError, err.Error()))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	dbConnEnv := new(DatabaseConnection)
+	if err := env.Parse(dbConnEnv); err != nil {
+		slog.Error("error parsing environment variables", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if dbConnEnv.ScratchConnStr == "" {
+		slog.Error("DUMPSTER_SCRATCH_DB_CONN_STR environment variable not set")
+		return subcommands.ExitUsageError
+	}
+
+	connStr, err := dumpster.SessionConnStr(dbConnEnv.ConnStr)
+	if err != nil {
+		slog.Error("error configuring connection string", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	// Open source database connection
+	db, err := sqlx.Open("mysql", connStr)
+	if err != nil {
+		slog.Error("error opening database", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	defer func(db *sqlx.DB) {
+		if err := db.Close(); err != nil {
+			slog.Warn("Error closing database connection", slog.String(logging.KeyError, err.Error()))
+		}
+	}(db)
+
+	scratchConnStr, err := multiStatementConnStr(dbConnEnv.ScratchConnStr)
+	if err != nil {
+		slog.Error("error configuring scratch connection string", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	// Open scratch database connection
+	scratchDB, err := sqlx.Open("mysql", scratchConnStr)
+	if err != nil {
+		slog.Error("error opening scratch database", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	defer func(db *sqlx.DB) {
+		if err := db.Close(); err != nil {
+			slog.Warn("Error closing scratch database connection", slog.String(logging.KeyError, err.Error()))
+		}
+	}(scratchDB)
+
+	source, err := dumpster.NewDumpster(db)
+	if err != nil {
+		slog.Error("error creating dumpster", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	scratch, err := dumpster.NewDumpster(scratchDB)
+	if err != nil {
+		slog.Error("error creating scratch dumpster", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	schemaName := c.schema
+	if schemaName == "" {
+		schemaName, err = source.GetSchemaName(ctx)
+		if err != nil {
+			slog.Error("error getting schema name", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+	}
+
+	storageClient, err := c.storage.open(ctx)
+	if err != nil {
+		slog.Error("error initializing storage", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	defer closeStorage(storageClient)
+
+	filePath := c.file
+	if filePath == "" {
+		files, err := storageClient.ListFiles(ctx, fmt.Sprintf("dumps/%s/", schemaName))
+		if err != nil {
+			slog.Error("error listing dumps", slog.String(logging.KeyError, err.Error()))
+			return subcommands.ExitFailure
+		}
+
+		b, err := findBackup(files, time.Now().UTC())
+		if err != nil {
+			slog.Error("error finding dump to verify", slog.String(logging.KeyError, err.Error()),
+				slog.String("schema", schemaName))
+			return subcommands.ExitFailure
+		}
+
+		filePath = b.path
+	}
+
+	fc, err := storageClient.DownloadFile(ctx, filePath)
+	if err != nil {
+		slog.Error("error downloading dump", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	scratchSchema := fmt.Sprintf("dumpster_verify_%d", time.Now().UTC().Unix())
+
+	slog.Info("Verifying dump",
+		slog.String("path", filePath),
+		slog.String("schema", schemaName),
+		slog.String("scratch_schema", scratchSchema),
+	)
+
+	// Always drop the scratch schema, even if the restore fails part way through or the run is cancelled.
+	defer func() {
+		if err := scratch.DropSchema(context.WithoutCancel(ctx), scratchSchema); err != nil {
+			slog.Warn("Error dropping scratch schema", slog.String(logging.KeyError, err.Error()))
+		}
+	}()
+
+	if err := scratch.RestoreInto(ctx, string(fc), scratchSchema); err != nil {
+		slog.Error("error restoring dump into scratch schema", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	sourceStats, err := source.GetTableStats(ctx, schemaName)
+	if err != nil {
+		slog.Error("error getting source table stats", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	restoredStats, err := scratch.GetTableStats(ctx, scratchSchema)
+	if err != nil {
+		slog.Error("error getting restored table stats", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	out := &verifyOutput{
+		Path:          filePath,
+		Schema:        schemaName,
+		ScratchSchema: scratchSchema,
+		VerifyReport:  dumpster.CompareTableStats(sourceStats, restoredStats),
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		slog.Error("error writing report", slog.String(logging.KeyError, err.Error()))
+		return subcommands.ExitFailure
+	}
+
+	if !out.OK {
+		slog.Error("Dump verification failed", slog.String("path", filePath))
+		return subcommands.ExitFailure
+	}
+
+	slog.Info("Dump verified", slog.String("path", filePath))
+
+	return subcommands.ExitSuccess
+}
@@ -1,5 +1,15 @@
 package main
 
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+	"github.com/go-sql-driver/mysql"
+)
+
 const (
 	appName = `dumpster`
 )
@@ -7,4 +17,68 @@ const (
 type DatabaseConnection struct {
 	// ConnStr is the connection string to the database.
 	ConnStr string `env:"DUMPSTER_DB_CONN_STR"`
+
+	// ScratchConnStr is the connection string to the scratch database used to verify dumps.
+	ScratchConnStr string `env:"DUMPSTER_SCRATCH_DB_CONN_STR"`
+}
+
+// driverConnStr returns the database/sql driver for the connection string and the connection string configured for a
+// dump session. PostgreSQL is used for postgres:// and postgresql:// URLs, SQLite for sqlite://<path> and file:<path>,
+// and MySQL otherwise.
+func driverConnStr(connStr string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(connStr, "postgres://"), strings.HasPrefix(connStr, "postgresql://"):
+		return dumpster.DialectPostgres, connStr, nil
+	case strings.HasPrefix(connStr, "sqlite://"):
+		return dumpster.DialectSQLite, "file:" + strings.TrimPrefix(connStr, "sqlite://"), nil
+	case strings.HasPrefix(connStr, "file:"):
+		return dumpster.DialectSQLite, connStr, nil
+	}
+
+	connStr, err := dumpster.SessionConnStr(connStr)
+	if err != nil {
+		return "", "", err
+	}
+
+	return dumpster.DialectMySQL, connStr, nil
+}
+
+// multiStatementConnStr returns the connection string configured for a dump session with multiple statements
+// enabled. This is required to restore a dump as a single query.
+func multiStatementConnStr(connStr string) (string, error) {
+	connStr, err := dumpster.SessionConnStr(connStr)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := mysql.ParseDSN(connStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing connection string: %w", err)
+	}
+
+	cfg.MultiStatements = true
+
+	return cfg.FormatDSN(), nil
+}
+
+// splitList returns the items of a comma separated flag value, with surrounding spaces and empty items removed.
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// withTimeout returns a context that is cancelled after the timeout. The context is only cancelled with the parent if
+// the timeout is 0.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
 }
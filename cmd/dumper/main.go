@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/google/subcommands"
 )
@@ -23,8 +25,17 @@ func main() {
 	subcommands.Register(new(ddlCmd), "")
 	subcommands.Register(new(dumpCmd), "")
 	subcommands.Register(new(purgeCmd), "")
+	subcommands.Register(new(restoreCmd), "")
+	subcommands.Register(new(verifyCmd), "")
+	subcommands.Register(new(diffCmd), "")
+	subcommands.Register(new(checksumCmd), "")
 
 	flag.Parse()
-	ctx := context.Background()
-	os.Exit(int(subcommands.Execute(ctx)))
+
+	// Cancel the context on an interrupt so that a running command can stop and clean up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	status := subcommands.Execute(ctx)
+	stop()
+
+	os.Exit(int(status))
 }
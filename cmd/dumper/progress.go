@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dumpster"
+)
+
+// progressLogger logs the progress of a dump at an interval, so a stuck dump can be told apart from a slow one.
+type progressLogger struct {
+	mu sync.Mutex
+
+	// start is when the dump started.
+	start time.Time
+
+	// event is the latest progress of the dump.
+	event dumpster.ProgressEvent
+}
+
+// newProgressLogger returns a progress logger for a dump starting now.
+func newProgressLogger() *progressLogger {
+	return &progressLogger{
+		start: time.Now(),
+	}
+}
+
+// handle records the progress of the dump. It is passed to dumpster.WithProgress.
+func (l *progressLogger) handle(e dumpster.ProgressEvent) {
+	l.mu.Lock()
+	l.event = e
+	l.mu.Unlock()
+
+	switch e.Type {
+	case dumpster.ProgressStarted:
+		slog.Info("Dump started",
+			slog.Int("tables", e.Tables),
+			slog.Int64("estimated_rows", e.EstimatedRows),
+			slog.Int64("estimated_bytes", e.EstimatedBytes),
+		)
+	case dumpster.ProgressTableFinished:
+		slog.Debug("Table dumped",
+			slog.String("table", e.Table),
+			slog.Int64("rows", e.TableRows),
+			slog.Int64("bytes", e.TableBytes),
+		)
+	}
+}
+
+// run logs the progress every interval until the context is done.
+func (l *progressLogger) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.log()
+		}
+	}
+}
+
+// log logs the latest progress of the dump with the estimated time remaining.
+func (l *progressLogger) log() {
+	l.mu.Lock()
+	e := l.event
+	l.mu.Unlock()
+
+	elapsed := time.Since(l.start)
+	attrs := []any{
+		slog.String("table", e.Table),
+		slog.Int("tables_done", e.TablesDone),
+		slog.Int("tables", e.Tables),
+		slog.Int64("rows", e.Rows),
+		slog.Int64("estimated_rows", e.EstimatedRows),
+		slog.Int64("bytes", e.Bytes),
+		slog.String("elapsed", elapsed.Round(time.Second).String()),
+	}
+
+	if eta, ok := estimateRemaining(elapsed, e.Rows, e.EstimatedRows); ok {
+		attrs = append(attrs, slog.String("eta", eta.Round(time.Second).String()))
+	}
+
+	slog.Info("Dump progress", attrs...)
+}
+
+// estimateRemaining returns the time left to process the total from the time it took to process done, assuming the
+// rate stays the same. It returns false if the remaining time cannot be estimated. Estimates of the total can be too
+// low, so the remaining time is never negative.
+func estimateRemaining(elapsed time.Duration, done, total int64) (time.Duration, bool) {
+	if done <= 0 || total <= 0 {
+		return 0, false
+	}
+
+	if done >= total {
+		return 0, true
+	}
+
+	return time.Duration(float64(elapsed) * float64(total-done) / float64(done)), true
+}
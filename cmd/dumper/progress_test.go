@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		done    int64
+		total   int64
+		want    time.Duration
+		wantOK  bool
+	}{
+		{
+			name:    "quarter done",
+			elapsed: time.Minute,
+			done:    250,
+			total:   1000,
+			want:    3 * time.Minute,
+			wantOK:  true,
+		},
+		{
+			name:    "total underestimated",
+			elapsed: time.Minute,
+			done:    1200,
+			total:   1000,
+			want:    0,
+			wantOK:  true,
+		},
+		{
+			name:    "nothing done",
+			elapsed: time.Minute,
+			total:   1000,
+		},
+		{
+			name:    "unknown total",
+			elapsed: time.Minute,
+			done:    250,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := estimateRemaining(tt.elapsed, tt.done, tt.total)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
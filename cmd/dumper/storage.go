@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/dataaccess"
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+)
+
+// storageFlags are the flags that select the storage of a command, as destination URLs or with the flags of each
+// backend. Files are saved to every destination given, and the working directory is used if none are.
+type storageFlags struct {
+	// dests are the URLs of the destinations, e.g. gs://bucket/prefix.
+	dests destFlag
+
+	// policy decides whether saving to several destinations fails when some of them fail, all or best-effort.
+	policy string
+
+	// gcs is the GCS bucket to use.
+	gcs string
+
+	// s3 configures the S3 bucket to use.
+	s3 s3Flags
+
+	// azure configures the Azure Blob Storage container to use.
+	azure azureFlags
+
+	// sftp configures the SFTP server to use.
+	sftp sftpFlags
+}
+
+func (s *storageFlags) setFlags(f *flag.FlagSet) {
+	f.Var(&s.dests, "dest", "The URL of a storage to use, one of gs://<bucket>/<prefix>, s3://<bucket>/<prefix>, azblob://<container>/<prefix>, sftp://[user@]<host>[:<port>]/<dir> or file:///<dir>. Repeat to save to several destinations at once, and add ?retention=<days> to keep dumps in a destination for a different number of days. Defaults to the working directory.")
+	f.StringVar(&s.policy, "dest-policy", string(dataaccess.FanOutAll), "Whether saving to several destinations fails if any of them fails (all), or only logs a warning unless all of them fail (best-effort).")
+	f.StringVar(&s.gcs, "gcs", "", "The GCS bucket to use (Requires GCS_CREDENTIALS environment variable to be set). Same as --dest=gs://<bucket>.")
+	s.s3.setFlags(f)
+	s.azure.setFlags(f)
+	s.sftp.setFlags(f)
+}
+
+// destFlag is a flag that can be repeated to give several destination URLs.
+type destFlag []string
+
+func (d *destFlag) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *destFlag) Set(value string) error {
+	*d = append(*d, value)
+	return nil
+}
+
+// destination is a destination URL selected by the flags.
+type destination struct {
+	// url is the URL of the destination, without the retention.
+	url string
+
+	// retentionDays is the number of days to keep dumps in the destination for. The days of the command are used if 0.
+	retentionDays int
+}
+
+// destinations returns the destinations selected by the flags, which are empty for the working directory.
+func (s *storageFlags) destinations() ([]destination, error) {
+	if _, err := dataaccess.ParseFanOutPolicy(s.policy); err != nil {
+		return nil, err
+	}
+
+	urls := append([]string{}, s.dests...)
+	if s.gcs != "" {
+		urls = append(urls, (&url.URL{Scheme: "gs", Host: s.gcs}).String())
+	}
+
+	if s.s3.bucket != "" {
+		urls = append(urls, s.s3.url().String())
+	}
+
+	if s.azure.container != "" {
+		urls = append(urls, s.azure.url().String())
+	}
+
+	if s.sftp.addr != "" {
+		urls = append(urls, s.sftp.url().String())
+	}
+
+	dests := make([]destination, 0, len(urls))
+	for _, raw := range urls {
+		d, err := parseDestination(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		dests = append(dests, d)
+	}
+
+	return dests, nil
+}
+
+// parseDestination splits the retention option from a destination URL.
+func parseDestination(raw string) (destination, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return destination{}, fmt.Errorf("error parsing destination: %w", err)
+	}
+
+	q := u.Query()
+	if !q.Has("retention") {
+		return destination{url: raw}, nil
+	}
+
+	days, err := strconv.Atoi(q.Get("retention"))
+	if err != nil || days <= 0 {
+		return destination{}, fmt.Errorf("invalid retention %q for destination %s, must be a number of days", q.Get("retention"), raw)
+	}
+
+	q.Del("retention")
+	u.RawQuery = q.Encode()
+
+	return destination{url: u.String(), retentionDays: days}, nil
+}
+
+// retained reports whether any destination has its own retention, so that it is purged even if the command does not
+// purge.
+func (s *storageFlags) retained() bool {
+	dests, err := s.destinations()
+	if err != nil {
+		return false
+	}
+
+	for _, d := range dests {
+		if d.retentionDays > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// open returns the storage selected by the flags. Several destinations, or a destination with its own retention, are
+// combined into a fan-out storage.
+func (s *storageFlags) open(ctx context.Context) (dataaccess.Storage, error) {
+	dests, err := s.destinations()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(dests) == 0:
+		return dataaccess.NewLocal(), nil
+	case len(dests) == 1 && dests[0].retentionDays == 0:
+		return dataaccess.Open(ctx, dests[0].url)
+	}
+
+	policy, err := dataaccess.ParseFanOutPolicy(s.policy)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := make([]dataaccess.Destination, 0, len(dests))
+	errs := make([]error, 0)
+	for _, d := range dests {
+		name := destName(d.url)
+
+		sc, err := dataaccess.Open(ctx, d.url)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		opened = append(opened, dataaccess.Destination{
+			Name:      name,
+			Storage:   sc,
+			Retention: time.Duration(d.retentionDays) * 24 * time.Hour,
+		})
+	}
+
+	if len(errs) > 0 {
+		if policy != dataaccess.FanOutBestEffort || len(opened) == 0 {
+			// Close the destinations that did open, such as SFTP connections.
+			closeStorage(dataaccess.NewFanOut(policy, opened...))
+			return nil, errors.Join(errs...)
+		}
+
+		for _, err := range errs {
+			slog.Warn("Error opening destination, continuing with the other destinations", slog.String(logging.KeyError, err.Error()))
+		}
+	}
+
+	return dataaccess.NewFanOut(policy, opened...), nil
+}
+
+// closeStorage closes the connections of the storage, logging any error as a warning.
+func closeStorage(sc dataaccess.Storage) {
+	if err := dataaccess.Close(sc); err != nil {
+		slog.Warn("Error closing storage", slog.String(logging.KeyError, err.Error()))
+	}
+}
+
+// destName returns the destination URL without its options, to identify it in logs.
+func destName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	u.RawQuery = ""
+	return u.String()
+}
+
+// s3Flags are the flags that select and configure S3 storage.
+type s3Flags struct {
+	// bucket is the bucket to use. Setting this will enable S3.
+	bucket string
+
+	// region is the region of the bucket. The region of the AWS configuration is used if empty.
+	region string
+
+	// endpoint is the URL of an S3 compatible store, such as MinIO. AWS is used if empty.
+	endpoint string
+
+	// profile is the shared configuration profile to take credentials from.
+	profile string
+
+	// sse is the server-side encryption of uploaded dumps, AES256 or aws:kms.
+	sse string
+
+	// sseKMSKeyID is the KMS key to encrypt with aws:kms.
+	sseKMSKeyID string
+}
+
+func (s *s3Flags) setFlags(f *flag.FlagSet) {
+	f.StringVar(&s.bucket, "s3", "", "The S3 bucket to use. Credentials are read from the environment, the shared configuration or the instance metadata. Same as --dest=s3://<bucket>.")
+	f.StringVar(&s.region, "s3-region", "", "The region of the S3 bucket. Defaults to the region of the AWS configuration.")
+	f.StringVar(&s.endpoint, "s3-endpoint", "", "The URL of an S3 compatible store, such as MinIO.")
+	f.StringVar(&s.profile, "s3-profile", "", "The shared configuration profile to read AWS credentials from.")
+	f.StringVar(&s.sse, "s3-sse", "", "The server-side encryption of uploaded dumps, AES256 or aws:kms. Defaults to the encryption of the bucket.")
+	f.StringVar(&s.sseKMSKeyID, "s3-sse-kms-key-id", "", "The KMS key to encrypt dumps with (Requires --s3-sse=aws:kms).")
+}
+
+// url returns the destination URL of the S3 flags.
+func (s *s3Flags) url() *url.URL {
+	q := make(url.Values)
+	setQuery(q, "region", s.region)
+	setQuery(q, "endpoint", s.endpoint)
+	setQuery(q, "profile", s.profile)
+	setQuery(q, "sse", s.sse)
+	setQuery(q, "sse-kms-key-id", s.sseKMSKeyID)
+
+	return &url.URL{Scheme: "s3", Host: s.bucket, RawQuery: q.Encode()}
+}
+
+// azureFlags are the flags that select and configure Azure Blob storage.
+type azureFlags struct {
+	// container is the container to use. Setting this will enable Azure.
+	container string
+
+	// accountURL is the blob endpoint of the storage account, e.g. https://<account>.blob.core.windows.net.
+	accountURL string
+}
+
+func (a *azureFlags) setFlags(f *flag.FlagSet) {
+	f.StringVar(&a.container, "azure", "", "The Azure Blob Storage container to use (Requires AZURE_STORAGE_CONNECTION_STRING or --azure-account-url to be set). Same as --dest=azblob://<container>.")
+	f.StringVar(&a.accountURL, "azure-account-url", "", "The blob endpoint of the storage account, e.g. https://<account>.blob.core.windows.net. Authenticates with AZURE_STORAGE_SAS_TOKEN if set, otherwise with the Azure environment, workload or managed identity.")
+}
+
+// url returns the destination URL of the Azure flags.
+func (a *azureFlags) url() *url.URL {
+	q := make(url.Values)
+	setQuery(q, "account-url", a.accountURL)
+
+	return &url.URL{Scheme: "azblob", Host: a.container, RawQuery: q.Encode()}
+}
+
+// sftpFlags are the flags that select and configure SFTP storage.
+type sftpFlags struct {
+	// addr is the host and port of the server. Setting this will enable SFTP.
+	addr string
+
+	// user is the user to log in as.
+	user string
+
+	// dir is the directory on the server to save dumps in.
+	dir string
+
+	// keyFile is the private key to log in with.
+	keyFile string
+
+	// knownHosts is the known_hosts file the key of the server is verified against.
+	knownHosts string
+}
+
+func (s *sftpFlags) setFlags(f *flag.FlagSet) {
+	f.StringVar(&s.addr, "sftp", "", "The SFTP server to use, as host or host:port. Same as --dest=sftp://<host>.")
+	f.StringVar(&s.user, "sftp-user", "", "The user to log in to the SFTP server as. Defaults to the current user.")
+	f.StringVar(&s.dir, "sftp-dir", "", "The directory on the SFTP server to save dumps in. Defaults to the login directory.")
+	f.StringVar(&s.keyFile, "sftp-key", "", "The private key to log in with. The passphrase is read from SFTP_KEY_PASSPHRASE. Without a key, the password is read from SFTP_PASSWORD.")
+	f.StringVar(&s.knownHosts, "sftp-known-hosts", "", "The known_hosts file the key of the SFTP server is verified against. Defaults to ~/.ssh/known_hosts.")
+}
+
+// url returns the destination URL of the SFTP flags. Relative directories are relative to the login directory, which
+// the URL marks with /~/.
+func (s *sftpFlags) url() *url.URL {
+	q := make(url.Values)
+	setQuery(q, "key", s.keyFile)
+	setQuery(q, "known-hosts", s.knownHosts)
+
+	u := &url.URL{Scheme: "sftp", Host: s.addr, RawQuery: q.Encode()}
+	if s.user != "" {
+		u.User = url.User(s.user)
+	}
+
+	switch {
+	case s.dir == "":
+	case path.IsAbs(s.dir):
+		u.Path = s.dir
+	default:
+		u.Path = "/~/" + s.dir
+	}
+
+	return u
+}
+
+// setQuery sets the query parameter if the value is not empty.
+func setQuery(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageFlags_Destinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   storageFlags
+		want    []destination
+		wantErr bool
+	}{
+		{
+			name: "working directory",
+			want: []destination{},
+		},
+		{
+			name:  "dest",
+			flags: storageFlags{dests: destFlag{"s3://backups/app?region=eu-west-1"}},
+			want:  []destination{{url: "s3://backups/app?region=eu-west-1"}},
+		},
+		{
+			name:  "gcs",
+			flags: storageFlags{gcs: "backups"},
+			want:  []destination{{url: "gs://backups"}},
+		},
+		{
+			name:  "s3",
+			flags: storageFlags{s3: s3Flags{bucket: "backups", endpoint: "http://localhost:9000", sse: "aws:kms", sseKMSKeyID: "key"}},
+			want:  []destination{{url: "s3://backups?endpoint=http%3A%2F%2Flocalhost%3A9000&sse=aws%3Akms&sse-kms-key-id=key"}},
+		},
+		{
+			name:  "azure",
+			flags: storageFlags{azure: azureFlags{container: "backups", accountURL: "https://account.blob.core.windows.net"}},
+			want:  []destination{{url: "azblob://backups?account-url=https%3A%2F%2Faccount.blob.core.windows.net"}},
+		},
+		{
+			name:  "sftp absolute",
+			flags: storageFlags{sftp: sftpFlags{addr: "host:2222", user: "backup", dir: "/var/backups", knownHosts: "/etc/known_hosts"}},
+			want:  []destination{{url: "sftp://backup@host:2222/var/backups?known-hosts=%2Fetc%2Fknown_hosts"}},
+		},
+		{
+			name:  "sftp relative",
+			flags: storageFlags{sftp: sftpFlags{addr: "host", dir: "backups"}},
+			want:  []destination{{url: "sftp://host/~/backups"}},
+		},
+		{
+			name:  "several",
+			flags: storageFlags{dests: destFlag{"file:///var/backups", "s3://offsite/app?retention=90&region=us-east-1"}, gcs: "backups"},
+			want: []destination{
+				{url: "file:///var/backups"},
+				{url: "s3://offsite/app?region=us-east-1", retentionDays: 90},
+				{url: "gs://backups"},
+			},
+		},
+		{
+			name:    "invalid retention",
+			flags:   storageFlags{dests: destFlag{"file:///var/backups?retention=1w"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid policy",
+			flags:   storageFlags{dests: destFlag{"file:///var/backups"}, policy: "some"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.flags.destinations()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package dataaccess
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// EnvAzureConnectionString is the connection string of the Azure storage account, including its key or a SAS.
+	EnvAzureConnectionString = "AZURE_STORAGE_CONNECTION_STRING"
+
+	// EnvAzureSASToken is a shared access signature for the container, used with the account URL.
+	EnvAzureSASToken = "AZURE_STORAGE_SAS_TOKEN"
+
+	// DefaultAzureBlockSize is the size of the blocks files are uploaded to Azure in. Each upload buffers a block in
+	// memory. A blob has at most 50,000 blocks, so this limits files to about 781 GiB.
+	DefaultAzureBlockSize = 16 << 20
+)
+
+type azureImpl struct {
+	// container is the client of the container to use.
+	container *container.Client
+
+	// blockSize is the size of the blocks files are uploaded in.
+	blockSize int
+}
+
+func NewAzure(client *container.Client) Storage {
+	return NewAzureWithBlockSize(client, DefaultAzureBlockSize)
+}
+
+// NewAzureWithBlockSize returns the Azure Blob storage of the container, uploading files in blocks of the given size.
+// Files smaller than a block are uploaded in a single request.
+func NewAzureWithBlockSize(client *container.Client, blockSize int) Storage {
+	return &azureImpl{
+		container: client,
+		blockSize: blockSize,
+	}
+}
+
+func (s *azureImpl) SaveFile(ctx context.Context, filePath string, file []byte) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
+	defer t.ObserveDuration()
+
+	// Create a new file in the container.
+	w, err := s.Writer(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Write the file to the container.
+	_, err = w.Write(file)
+	if err != nil {
+		_ = w.Close()
+		return fmt.Errorf("error writing file to container: %w", err)
+	}
+
+	// Close the file.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *azureImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
+	defer t.ObserveDuration()
+
+	// Open the file.
+	r, err := s.Reader(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the file.
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// Close the file.
+	err = r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Writer stages the file as blocks of a block blob as it is written, and commits the blocks when the writer is closed.
+// Blocks that are never committed are discarded by Azure.
+func (s *azureImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	return &azureWriter{
+		ctx:     ctx,
+		s:       s,
+		name:    filePath,
+		buf:     make([]byte, 0, s.blockSize),
+		blockID: fmt.Sprintf("%016x", time.Now().UnixNano()),
+	}, nil
+}
+
+func (s *azureImpl) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	// Open the file.
+	resp, err := s.container.NewBlobClient(filePath).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", azureError(err))
+	}
+
+	return resp.Body, nil
+}
+
+func (s *azureImpl) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	files := make([]string, 0)
+	err := s.walk(ctx, prefix, func(name string) error {
+		files = append(files, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (s *azureImpl) DeleteFile(ctx context.Context, filePath string) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
+	defer t.ObserveDuration()
+
+	// Delete the file.
+	_, err := s.container.NewBlobClient(filePath).Delete(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting file: %w", azureError(err))
+	}
+
+	return nil
+}
+
+// Purge deletes the dumps under the dumps/ prefix of the container that were taken before the time. Other files, such
+// as DDL snapshots, are kept.
+func (s *azureImpl) Purge(ctx context.Context, from time.Time) (int, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "purge"}))
+	defer t.ObserveDuration()
+
+	count := 0
+	err := s.walk(ctx, "dumps/", func(name string) error {
+		// Ignore all files that are not dumps.
+		if !isDumpFile(name) {
+			return nil
+		}
+
+		// Parse the file date from the file name.
+		fileDate, err := dumpFileTime(name)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", name))
+			return nil
+		}
+
+		// Check if the file date is before the purge date.
+		if fileDate.After(from) {
+			return nil
+		}
+
+		// Delete the file.
+		_, err = s.container.NewBlobClient(name).Delete(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error deleting file: %w", err)
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// walk calls fn with the name of each file in the container that starts with the prefix.
+func (s *azureImpl) walk(ctx context.Context, prefix string, fn func(name string) error) error {
+	p := s.container.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	for p.More() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing files: %w", err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+
+			if err := fn(*item.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// azureWriter uploads a file to Azure a block at a time.
+type azureWriter struct {
+	// ctx is the context of the upload. The file is not saved if it is cancelled before the writer is closed.
+	ctx context.Context
+
+	s    *azureImpl
+	name string
+
+	// buf holds the data of the block being written.
+	buf []byte
+
+	// blockID is the prefix of the IDs of the blocks of this upload, so that concurrent uploads of the same file do not
+	// replace each other's blocks.
+	blockID string
+
+	// blocks are the IDs of the blocks that have been staged.
+	blocks []string
+
+	// err is the first error uploading the file.
+	err error
+}
+
+func (w *azureWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		c := min(len(p), w.s.blockSize-len(w.buf))
+		w.buf = append(w.buf, p[:c]...)
+		p = p[c:]
+		n += c
+
+		if len(w.buf) < w.s.blockSize {
+			break
+		}
+
+		if err := w.stageBlock(); err != nil {
+			w.err = err
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Close commits the staged blocks, unless a write failed or the context has been cancelled.
+func (w *azureWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.ctx.Err(); err != nil {
+		w.err = err
+		return fmt.Errorf("error saving file: %w", err)
+	}
+
+	client := w.s.container.NewBlockBlobClient(w.name)
+
+	// Files smaller than a block are uploaded in a single request.
+	if len(w.blocks) == 0 {
+		_, err := client.Upload(w.ctx, streaming.NopCloser(bytes.NewReader(w.buf)), nil)
+		if err != nil {
+			return fmt.Errorf("error uploading file: %w", err)
+		}
+
+		return nil
+	}
+
+	if len(w.buf) > 0 {
+		if err := w.stageBlock(); err != nil {
+			w.err = err
+			return err
+		}
+	}
+
+	_, err := client.CommitBlockList(w.ctx, w.blocks, nil)
+	if err != nil {
+		return fmt.Errorf("error committing blocks: %w", err)
+	}
+
+	return nil
+}
+
+// stageBlock uploads the buffered data as the next block.
+func (w *azureWriter) stageBlock() error {
+	// The IDs of the blocks of a blob must all be the same length.
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%06d", w.blockID, len(w.blocks))))
+
+	_, err := w.s.container.NewBlockBlobClient(w.name).StageBlock(w.ctx, id, streaming.NopCloser(bytes.NewReader(w.buf)), nil)
+	if err != nil {
+		return fmt.Errorf("error uploading block %d: %w", len(w.blocks), err)
+	}
+
+	w.blocks = append(w.blocks, id)
+	w.buf = w.buf[:0]
+
+	return nil
+}
+
+// azureError marks the error for a missing blob as fs.ErrNotExist.
+func azureError(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return notExist(err)
+	}
+
+	return err
+}
+
+// openAzure opens an azblob://<container>/<prefix> destination, after checking the container can be reached. A
+// connection string is used if set, then the account-url option with a SAS token, then with the default Azure
+// credentials, which include managed identities.
+func openAzure(ctx context.Context, u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, errors.New("a container is required, e.g. azblob://<container>/<prefix>")
+	}
+
+	q, err := destQuery(u, "account-url")
+	if err != nil {
+		return nil, err
+	}
+
+	connStr := os.Getenv(EnvAzureConnectionString)
+	sas := strings.TrimPrefix(os.Getenv(EnvAzureSASToken), "?")
+	accountURL := q.Get("account-url")
+	containerURL := strings.TrimSuffix(accountURL, "/") + "/" + u.Host
+
+	var client *container.Client
+
+	switch {
+	case connStr != "":
+		client, err = container.NewClientFromConnectionString(connStr, u.Host, nil)
+	case accountURL == "":
+		return nil, fmt.Errorf("%s or the account-url option must be set", EnvAzureConnectionString)
+	case sas != "":
+		client, err = container.NewClientWithNoCredential(containerURL+"?"+sas, nil)
+	default:
+		cred, credErr := azidentity.NewDefaultAzureCredential(nil)
+		if credErr != nil {
+			return nil, fmt.Errorf("error loading Azure credentials: %w", credErr)
+		}
+
+		client, err = container.NewClient(containerURL, cred, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating Azure client: %w", err)
+	}
+
+	_, err = client.GetProperties(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error checking container: %w", err)
+	}
+
+	return WithPrefix(NewAzure(client), bucketPrefix(u)), nil
+}
@@ -0,0 +1,214 @@
+package dataaccess
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBlobs is an in-memory Azure Blob Storage server with the requests used by the Azure storage, for a single
+// container addressed by path.
+type fakeBlobs struct {
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	staged map[string][]byte
+}
+
+// newAzureContainer returns a client of an empty container. The container is created in the Azurite emulator if
+// DUMPSTER_TEST_AZURITE is set to its connection string, otherwise an in-memory fake is used.
+func newAzureContainer(t *testing.T) *container.Client {
+	if connStr := os.Getenv("DUMPSTER_TEST_AZURITE"); connStr != "" {
+		client, err := container.NewClientFromConnectionString(connStr, fmt.Sprintf("dumpster-%d", time.Now().UnixNano()), nil)
+		require.NoError(t, err)
+
+		_, err = client.Create(context.Background(), nil)
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			_, _ = client.Delete(context.Background(), nil)
+		})
+
+		return client
+	}
+
+	f := &fakeBlobs{
+		blobs:  make(map[string][]byte),
+		staged: make(map[string][]byte),
+	}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client, err := container.NewClientWithNoCredential(srv.URL+"/devstoreaccount1/backups", nil)
+	require.NoError(t, err)
+
+	return client
+}
+
+func (f *fakeBlobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The path is /account/container/blob.
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	name := ""
+	if len(parts) == 3 {
+		name = parts[2]
+	}
+
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodGet && name == "" && q.Get("comp") == "list":
+		type blob struct {
+			Name string
+		}
+
+		res := struct {
+			XMLName xml.Name `xml:"EnumerationResults"`
+			Blobs   []blob   `xml:"Blobs>Blob"`
+		}{}
+
+		for n := range f.blobs {
+			if strings.HasPrefix(n, q.Get("prefix")) {
+				res.Blobs = append(res.Blobs, blob{Name: n})
+			}
+		}
+
+		sort.Slice(res.Blobs, func(i, j int) bool {
+			return res.Blobs[i].Name < res.Blobs[j].Name
+		})
+
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodGet:
+		b, ok := f.blobs[name]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		_, _ = w.Write(b)
+	case r.Method == http.MethodPut && q.Get("comp") == "block":
+		f.staged[name+"/"+q.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		_ = xml.Unmarshal(body, &list)
+
+		b := make([]byte, 0)
+		for _, id := range list.Latest {
+			b = append(b, f.staged[name+"/"+id]...)
+		}
+
+		f.blobs[name] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		f.blobs[name] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestAzure_Writer(t *testing.T) {
+	s := NewAzureWithBlockSize(newAzureContainer(t), 8)
+
+	// Files smaller than a block are uploaded in a single request.
+	require.NoError(t, s.SaveFile(context.Background(), "dumps/app/small.sql", []byte("CREATE TABLE t;\n")))
+
+	got, err := s.DownloadFile(context.Background(), "dumps/app/small.sql")
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	// Larger files are staged in blocks of 8 bytes.
+	w, err := s.Writer(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "0123456789")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "abcdefghij")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	got, err = s.DownloadFile(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+	require.Equal(t, "0123456789abcdefghij", string(got))
+
+	// The blocks of a cancelled upload are never committed.
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = s.Writer(ctx, "dumps/app/cancelled.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "0123456789")
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, w.Close(), context.Canceled)
+
+	_, err = s.DownloadFile(context.Background(), "dumps/app/cancelled.sql")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestAzure_Purge(t *testing.T) {
+	s := NewAzure(newAzureContainer(t))
+
+	now := time.Now().UTC()
+	for _, name := range []string{
+		"dumps/app/2020-01-01T00:00:00Z.sql",
+		"dumps/app/2020-01-02T00:00:00Z.sqlite",
+		"dumps/app/" + now.Format(time.RFC3339) + ".sql",
+		"dumps/app/notes.txt",
+		"ddl/app/2020-01-01T00:00:00Z.sql",
+	} {
+		require.NoError(t, s.SaveFile(context.Background(), name, []byte("--")))
+	}
+
+	files, err := s.ListFiles(context.Background(), "dumps/app/2020")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/2020-01-01T00:00:00Z.sql", "dumps/app/2020-01-02T00:00:00Z.sqlite"}, files)
+
+	count, err := s.Purge(context.Background(), now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.NoError(t, s.DeleteFile(context.Background(), "dumps/app/notes.txt"))
+	require.ErrorIs(t, s.DeleteFile(context.Background(), "dumps/app/notes.txt"), fs.ErrNotExist)
+
+	files, err = s.ListFiles(context.Background(), "dumps/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/" + now.Format(time.RFC3339) + ".sql"}, files)
+
+	// DDL snapshots are not dumps, whatever their age.
+	files, err = s.ListFiles(context.Background(), "ddl/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ddl/app/2020-01-01T00:00:00Z.sql"}, files)
+}
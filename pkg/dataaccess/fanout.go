@@ -0,0 +1,579 @@
+package dataaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FanOutPolicy decides whether a save to several destinations succeeds when some of them fail.
+type FanOutPolicy string
+
+const (
+	// FanOutAll fails the save if any destination fails. The file is only committed to the destinations once every
+	// destination has received all of it.
+	FanOutAll FanOutPolicy = "all"
+
+	// FanOutBestEffort logs a warning for each destination that fails, and only fails the save if every destination
+	// fails.
+	FanOutBestEffort FanOutPolicy = "best-effort"
+)
+
+// ParseFanOutPolicy returns the FanOutPolicy for the name, which is all or best-effort. The default is all.
+func ParseFanOutPolicy(name string) (FanOutPolicy, error) {
+	switch p := FanOutPolicy(strings.ToLower(name)); p {
+	case "":
+		return FanOutAll, nil
+	case FanOutAll, FanOutBestEffort:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown fan-out policy %q, must be one of all, best-effort", name)
+	}
+}
+
+// Destination is one of the storages of a fan-out.
+type Destination struct {
+	// Name identifies the destination in logs and errors, e.g. its URL.
+	Name string
+
+	// Storage is the storage of the destination.
+	Storage Storage
+
+	// Retention is how long dumps are kept in the destination, replacing the time given to Purge. The time given to
+	// Purge is used if zero.
+	Retention time.Duration
+}
+
+type fanOutImpl struct {
+	// policy decides whether an operation fails when some destinations fail.
+	policy FanOutPolicy
+
+	// dests are the destinations, in the order files are read from.
+	dests []Destination
+}
+
+// NewFanOut returns a storage that saves each file to all the destinations in parallel, streaming it to them as it is
+// written. Files are read from the first destination that has them, and listed from all of them.
+func NewFanOut(policy FanOutPolicy, dests ...Destination) Storage {
+	return &fanOutImpl{
+		policy: policy,
+		dests:  dests,
+	}
+}
+
+// check returns the error of an operation on all the destinations from the errors of each destination, which are
+// logged as warnings with the best effort policy unless every destination failed.
+func (s *fanOutImpl) check(op string, errs []error) error {
+	failed := make([]error, 0)
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", s.dests[i].Name, err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if s.policy == FanOutBestEffort && len(failed) < len(errs) {
+		for _, err := range failed {
+			slog.Warn(fmt.Sprintf("Error %s, continuing with the other destinations", op), slog.String(logging.KeyError, err.Error()))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("error %s: %w", op, errors.Join(failed...))
+}
+
+func (s *fanOutImpl) SaveFile(ctx context.Context, filePath string, file []byte) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
+	defer t.ObserveDuration()
+
+	w, err := s.Writer(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Write the file.
+	_, err = w.Write(file)
+	if err != nil {
+		_ = w.Close()
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	// Close the file.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *fanOutImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
+	defer t.ObserveDuration()
+
+	// Open the file.
+	r, err := s.Reader(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the file.
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// Close the file.
+	err = r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Writer streams the file to a writer of each destination. The writer fails as soon as a destination fails with the
+// all policy, and once every destination has failed with the best effort policy.
+func (s *fanOutImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	w := &fanOutWriter{
+		ctx:     ctx,
+		s:       s,
+		path:    filePath,
+		targets: make([]*fanOutTarget, 0, len(s.dests)),
+		buf:     make([]byte, 0, fanOutChunkSize),
+	}
+
+	errs := make([]error, len(s.dests))
+	for i, d := range s.dests {
+		tctx, cancel := context.WithCancel(ctx)
+
+		dw, err := d.Storage.Writer(tctx, filePath)
+		if err != nil {
+			cancel()
+			errs[i] = err
+			continue
+		}
+
+		t := &fanOutTarget{
+			dest:   d,
+			w:      dw,
+			queue:  make(chan []byte, fanOutQueueLen),
+			cancel: cancel,
+			done:   make(chan struct{}),
+		}
+
+		go t.copy()
+		w.targets = append(w.targets, t)
+	}
+
+	if err := s.check("creating file", errs); err != nil {
+		w.abort()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Reader opens the file in the first destination that has it.
+func (s *fanOutImpl) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	errs := make([]error, 0, len(s.dests))
+	for _, d := range s.dests {
+		r, err := d.Storage.Reader(ctx, filePath)
+		if err == nil {
+			return r, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", d.Name, err))
+	}
+
+	return nil, fmt.Errorf("error opening file: %w", errors.Join(errs...))
+}
+
+// ListFiles returns the files in any of the destinations, sorted.
+func (s *fanOutImpl) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	seen := make(map[string]bool)
+	errs := make([]error, len(s.dests))
+	for i, d := range s.dests {
+		files, err := d.Storage.ListFiles(ctx, prefix)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		for _, f := range files {
+			seen[f] = true
+		}
+	}
+
+	if err := s.check("listing files", errs); err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(seen))
+	for f := range seen {
+		files = append(files, f)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// DeleteFile deletes the file from every destination. Destinations that do not have the file are ignored.
+func (s *fanOutImpl) DeleteFile(ctx context.Context, filePath string) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
+	defer t.ObserveDuration()
+
+	errs := make([]error, len(s.dests))
+	for i, d := range s.dests {
+		if err := d.Storage.DeleteFile(ctx, filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs[i] = err
+		}
+	}
+
+	return s.check("deleting file", errs)
+}
+
+// Purge purges each destination of the dumps taken before the time, or before its own retention if it has one. A
+// destination is not purged if the time is zero and it has no retention. It returns the number of files deleted from
+// all destinations.
+func (s *fanOutImpl) Purge(ctx context.Context, from time.Time) (int, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "purge"}))
+	defer t.ObserveDuration()
+
+	count := 0
+	errs := make([]error, len(s.dests))
+	for i, d := range s.dests {
+		cutoff := from
+		if d.Retention > 0 {
+			// Purge from midnight, as for the time given to Purge.
+			cutoff = time.Now().UTC().Add(-d.Retention).Truncate(24 * time.Hour)
+		}
+
+		if cutoff.IsZero() {
+			continue
+		}
+
+		n, err := d.Storage.Purge(ctx, cutoff)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		slog.Debug(fmt.Sprintf("Purged %d files from %s", n, d.Name))
+		count += n
+	}
+
+	if err := s.check("purging files", errs); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Close closes each destination.
+func (s *fanOutImpl) Close() error {
+	errs := make([]error, 0)
+	for _, d := range s.dests {
+		if err := Close(d.Storage); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+const (
+	// fanOutChunkSize is the size of the chunks a fan-out writer queues the file to its destinations in.
+	fanOutChunkSize = 64 << 10
+
+	// fanOutQueueLen is the number of chunks queued for each destination. A destination can fall this far behind the
+	// others before it slows down the writer, so each destination holds up to 4 MiB of the file in memory.
+	fanOutQueueLen = 64
+)
+
+// fanOutWriter streams a file to the writers of several destinations. The file is written to a queue of each
+// destination, which its writer uploads from concurrently with the other destinations.
+type fanOutWriter struct {
+	// ctx is the context of the save. The file is not saved if it is cancelled before the writer is closed.
+	ctx context.Context
+
+	s *fanOutImpl
+
+	// path is the path of the file.
+	path string
+
+	// targets are the destinations the file is being written to.
+	targets []*fanOutTarget
+
+	// buf holds the data written since the last chunk was queued.
+	buf []byte
+
+	// err is the error that failed the save.
+	err error
+}
+
+// fanOutTarget is a destination of a fan-out writer. The file is copied to the writer of the destination from a
+// bounded queue, so that each destination uploads at its own pace.
+type fanOutTarget struct {
+	dest Destination
+
+	// w is the writer of the destination.
+	w io.WriteCloser
+
+	// queue holds the chunks of the file that have not been written to the writer yet. It is closed once the whole file
+	// has been queued.
+	queue chan []byte
+
+	// closed is whether the queue has been closed.
+	closed bool
+
+	// cancel cancels the context of the writer of the destination, so that it is not saved.
+	cancel context.CancelFunc
+
+	// done is closed once the copy has finished, with its result in copyErr.
+	done    chan struct{}
+	copyErr error
+
+	// err is the error of the destination.
+	err error
+}
+
+// copy writes the chunks of the queue to the writer of the destination. A destination that fails is closed with its
+// context cancelled, so that it is not saved, and stops taking chunks from the queue.
+func (t *fanOutTarget) copy() {
+	defer close(t.done)
+
+	for chunk := range t.queue {
+		if _, err := t.w.Write(chunk); err != nil {
+			t.cancel()
+			_ = t.w.Close()
+			t.copyErr = err
+			return
+		}
+	}
+}
+
+// send queues a chunk for the destination, waiting while its queue is full. It fails if the copy to the destination
+// has failed or the context is cancelled.
+func (t *fanOutTarget) send(ctx context.Context, chunk []byte) error {
+	select {
+	case t.queue <- chunk:
+		return nil
+	case <-t.done:
+		return t.copyErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait closes the queue and returns the result of copying the file to the writer, waiting for the copy to finish.
+func (t *fanOutTarget) wait() error {
+	if !t.closed {
+		close(t.queue)
+		t.closed = true
+	}
+
+	<-t.done
+	return t.copyErr
+}
+
+// active returns the targets that have not failed.
+func (w *fanOutWriter) active() []*fanOutTarget {
+	targets := make([]*fanOutTarget, 0, len(w.targets))
+	for _, t := range w.targets {
+		if t.err == nil {
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+// fail records the errors of the targets, and returns the error of the save if they fail it.
+func (w *fanOutWriter) fail(op string) error {
+	errs := make([]error, 0, len(w.targets))
+	for _, t := range w.targets {
+		if t.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.dest.Name, t.err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if w.s.policy == FanOutBestEffort && len(errs) < len(w.targets) {
+		for _, err := range errs {
+			slog.Warn(fmt.Sprintf("Error %s, continuing with the other destinations", op), slog.String(logging.KeyError, err.Error()))
+		}
+
+		return nil
+	}
+
+	w.err = fmt.Errorf("error %s: %w", op, errors.Join(errs...))
+	return w.err
+}
+
+// flush queues the buffered data as a chunk for every destination that has not failed.
+func (w *fanOutWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	// The chunk is shared by the destinations, so the buffer is replaced rather than reused.
+	chunk := w.buf
+	w.buf = make([]byte, 0, fanOutChunkSize)
+
+	failed := false
+	for _, t := range w.active() {
+		if err := t.send(w.ctx, chunk); err != nil {
+			t.err = err
+			failed = true
+		}
+	}
+
+	if failed {
+		if err := w.fail("writing file"); err != nil {
+			w.abort()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *fanOutWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		c := min(len(p), fanOutChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:c]...)
+		p = p[c:]
+		n += c
+
+		if len(w.buf) < fanOutChunkSize {
+			break
+		}
+
+		if err := w.flush(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Close saves the file to the destinations once every destination has received all of it, unless the save has failed
+// or the context has been cancelled.
+//
+// The destinations are then committed in parallel, which is not atomic: with the all policy, a destination can fail
+// to commit after others have. The file is deleted again from the destinations that committed it in that case, which
+// is logged as a warning if it fails, so the file may be left in some destinations.
+func (w *fanOutWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	for _, t := range w.active() {
+		if err := t.wait(); err != nil {
+			t.err = err
+		}
+	}
+
+	if err := w.fail("writing file"); err != nil {
+		w.abort()
+		return err
+	}
+
+	if err := w.ctx.Err(); err != nil {
+		w.abort()
+		return fmt.Errorf("error saving file: %w", err)
+	}
+
+	// Commit the file to the destinations in parallel.
+	active := w.active()
+	var wg sync.WaitGroup
+	for _, t := range active {
+		wg.Add(1)
+		go func(t *fanOutTarget) {
+			defer wg.Done()
+			defer t.cancel()
+
+			t.err = t.w.Close()
+		}(t)
+	}
+
+	wg.Wait()
+
+	err := w.fail("saving file")
+	if err != nil && w.s.policy == FanOutAll {
+		w.rollback(active)
+	}
+
+	return err
+}
+
+// rollback deletes the file from the targets that committed it, after another target failed to.
+func (w *fanOutWriter) rollback(targets []*fanOutTarget) {
+	for _, t := range targets {
+		if t.err != nil {
+			continue
+		}
+
+		// The file is deleted even if the context has been cancelled.
+		err := t.dest.Storage.DeleteFile(context.WithoutCancel(w.ctx), w.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Error deleting file from destination after the save failed",
+				slog.String("destination", t.dest.Name),
+				slog.String("file", w.path),
+				slog.String(logging.KeyError, err.Error()),
+			)
+		}
+	}
+}
+
+// abort abandons the save to every destination. A destination that failed to take a chunk is still writing, so it is
+// closed too.
+func (w *fanOutWriter) abort() {
+	for _, t := range w.targets {
+		t.cancel()
+
+		// A failed copy has already closed the writer.
+		if err := t.wait(); err == nil {
+			_ = t.w.Close()
+		}
+
+		if t.err == nil {
+			t.err = context.Canceled
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package dataaccess
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// errUploadFailed is returned by the writers of failingStorage.
+var errUploadFailed = errors.New("upload failed")
+
+// failingStorage is a storage whose writers fail once they have been written to.
+type failingStorage struct {
+	Storage
+}
+
+func (failingStorage) Writer(context.Context, string) (io.WriteCloser, error) {
+	return failingWriter{}, nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errUploadFailed
+}
+
+func (failingWriter) Close() error {
+	return nil
+}
+
+// errCommitFailed is returned by the writers of commitFailingStorage.
+var errCommitFailed = errors.New("commit failed")
+
+// commitFailingStorage is a storage whose writers take the whole file but fail to save it when closed.
+type commitFailingStorage struct {
+	Storage
+}
+
+func (commitFailingStorage) Writer(context.Context, string) (io.WriteCloser, error) {
+	return commitFailingWriter{}, nil
+}
+
+type commitFailingWriter struct{}
+
+func (commitFailingWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (commitFailingWriter) Close() error {
+	return errCommitFailed
+}
+
+// blockingStorage is a storage whose writers block until release is closed.
+type blockingStorage struct {
+	Storage
+	release chan struct{}
+}
+
+func (s blockingStorage) Writer(context.Context, string) (io.WriteCloser, error) {
+	return blockingWriter{release: s.release}, nil
+}
+
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func (blockingWriter) Close() error {
+	return nil
+}
+
+// recordingStorage is a storage that records the data written to its writers.
+type recordingStorage struct {
+	Storage
+
+	mu        sync.Mutex
+	data      []byte
+	committed bool
+}
+
+func (s *recordingStorage) Writer(context.Context, string) (io.WriteCloser, error) {
+	return &recordingWriter{s: s}, nil
+}
+
+// written returns the number of bytes written.
+func (s *recordingStorage) written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data)
+}
+
+type recordingWriter struct {
+	s *recordingStorage
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.data = append(w.s.data, p...)
+	return len(p), nil
+}
+
+func (w *recordingWriter) Close() error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.committed = true
+	return nil
+}
+
+// localDest returns a destination in a new temporary directory.
+func localDest(t *testing.T, name string) (Destination, string) {
+	dir := filepath.ToSlash(t.TempDir())
+	return Destination{Name: name, Storage: WithPrefix(NewLocal(), dir)}, dir
+}
+
+func TestFanOut_Writer(t *testing.T) {
+	a, aDir := localDest(t, "a")
+	b, bDir := localDest(t, "b")
+	s := NewFanOut(FanOutAll, a, b)
+
+	w, err := s.Writer(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "CREATE TABLE t;\n")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "INSERT INTO t VALUES (1);\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Every destination has the whole file.
+	for _, dir := range []string{aDir, bDir} {
+		got, err := os.ReadFile(filepath.Join(dir, "dumps", "app", "dump.sql"))
+		require.NoError(t, err)
+		require.Equal(t, "CREATE TABLE t;\nINSERT INTO t VALUES (1);\n", string(got))
+	}
+
+	// Files only in one destination are listed and read from it.
+	require.NoError(t, b.Storage.SaveFile(context.Background(), "dumps/app/other.sql", []byte("--")))
+
+	files, err := s.ListFiles(context.Background(), "dumps/app/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/dump.sql", "dumps/app/other.sql"}, files)
+
+	got, err := s.DownloadFile(context.Background(), "dumps/app/other.sql")
+	require.NoError(t, err)
+	require.Equal(t, "--", string(got))
+
+	require.NoError(t, s.DeleteFile(context.Background(), "dumps/app/other.sql"))
+
+	_, err = s.DownloadFile(context.Background(), "dumps/app/other.sql")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// A cancelled save is not committed to any destination.
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = s.Writer(ctx, "dumps/app/cancelled.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "partial")
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, w.Close(), context.Canceled)
+
+	files, err = s.ListFiles(context.Background(), "dumps/app/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/dump.sql"}, files)
+}
+
+func TestFanOut_SlowDestination(t *testing.T) {
+	slow := blockingStorage{release: make(chan struct{})}
+	fast := &recordingStorage{}
+	s := NewFanOut(FanOutAll, Destination{Name: "slow", Storage: slow}, Destination{Name: "fast", Storage: fast})
+
+	w, err := s.Writer(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	// The writes are queued for the slow destination, and do not wait for it.
+	data := bytes.Repeat([]byte("x"), 4*fanOutChunkSize)
+	written := make(chan error, 1)
+	go func() {
+		_, err := w.Write(data)
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("write waited for the slow destination")
+	}
+
+	// The fast destination receives the file while the slow one is blocked.
+	require.Eventually(t, func() bool {
+		return fast.written() == len(data)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(slow.release)
+	require.NoError(t, w.Close())
+	require.True(t, fast.committed)
+}
+
+func TestFanOut_Policy(t *testing.T) {
+	t.Run("all", func(t *testing.T) {
+		ok, dir := localDest(t, "ok")
+		s := NewFanOut(FanOutAll, ok, Destination{Name: "failing", Storage: failingStorage{}})
+
+		err := s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n"))
+		require.ErrorIs(t, err, errUploadFailed)
+		require.ErrorContains(t, err, "failing")
+
+		// The file is not committed to the destinations that succeeded.
+		_, err = os.Stat(filepath.Join(dir, "dumps", "app", "dump.sql"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("all failing commit", func(t *testing.T) {
+		ok, dir := localDest(t, "ok")
+		s := NewFanOut(FanOutAll, ok, Destination{Name: "failing", Storage: commitFailingStorage{}})
+
+		err := s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n"))
+		require.ErrorIs(t, err, errCommitFailed)
+
+		// The file is deleted again from the destinations that committed it.
+		_, err = os.Stat(filepath.Join(dir, "dumps", "app", "dump.sql"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		ok, dir := localDest(t, "ok")
+		s := NewFanOut(FanOutBestEffort, Destination{Name: "failing", Storage: failingStorage{}}, ok)
+
+		require.NoError(t, s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n")))
+
+		got, err := os.ReadFile(filepath.Join(dir, "dumps", "app", "dump.sql"))
+		require.NoError(t, err)
+		require.Equal(t, "CREATE TABLE t;\n", string(got))
+	})
+
+	t.Run("best effort all failing", func(t *testing.T) {
+		s := NewFanOut(FanOutBestEffort,
+			Destination{Name: "a", Storage: failingStorage{}},
+			Destination{Name: "b", Storage: failingStorage{}},
+		)
+
+		err := s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n"))
+		require.ErrorIs(t, err, errUploadFailed)
+	})
+}
+
+func TestFanOut_Purge(t *testing.T) {
+	a, _ := localDest(t, "a")
+	b, _ := localDest(t, "b")
+	b.Retention = 30 * 24 * time.Hour
+	s := NewFanOut(FanOutAll, a, b)
+
+	now := time.Now().UTC()
+	for _, name := range []string{
+		"dumps/app/" + now.AddDate(0, 0, -60).Format(time.RFC3339) + ".sql",
+		"dumps/app/" + now.AddDate(0, 0, -7).Format(time.RFC3339) + ".sql",
+		"dumps/app/" + now.Format(time.RFC3339) + ".sql",
+	} {
+		require.NoError(t, s.SaveFile(context.Background(), name, []byte("--")))
+	}
+
+	// Without a time, only the destinations with their own retention are purged.
+	count, err := s.Purge(context.Background(), time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// The retention of a destination replaces the time.
+	count, err = s.Purge(context.Background(), now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	files, err := a.Storage.ListFiles(context.Background(), "dumps/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/" + now.Format(time.RFC3339) + ".sql"}, files)
+
+	files, err = b.Storage.ListFiles(context.Background(), "dumps/")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
+
+func TestParseFanOutPolicy(t *testing.T) {
+	p, err := ParseFanOutPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, FanOutAll, p)
+
+	p, err = ParseFanOutPolicy("Best-Effort")
+	require.NoError(t, err)
+	require.Equal(t, FanOutBestEffort, p)
+
+	_, err = ParseFanOutPolicy("some")
+	require.ErrorContains(t, err, `unknown fan-out policy "some"`)
+}
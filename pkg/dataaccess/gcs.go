@@ -6,16 +6,22 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"strings"
+	"net/url"
+	"os"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 const (
 	EnvGCSCredentials = "GCS_CREDENTIALS"
+
+	// DefaultGCSChunkSize is the size of the chunks files are uploaded to GCS in. Each upload buffers a chunk in memory,
+	// and retries a failed chunk from that buffer.
+	DefaultGCSChunkSize = 16 << 20
 )
 
 type gcsImpl struct {
@@ -24,12 +30,22 @@ type gcsImpl struct {
 
 	// bucket is the name of the bucket to use.
 	bucket string
+
+	// chunkSize is the size of the chunks files are uploaded in.
+	chunkSize int
 }
 
 func NewGCS(gcs *storage.Client, bucket string) Storage {
+	return NewGCSWithChunkSize(gcs, bucket, DefaultGCSChunkSize)
+}
+
+// NewGCSWithChunkSize returns the GCS storage, uploading files in chunks of the given size. Larger chunks need fewer
+// requests but more memory. A chunk size of zero uploads each file in a single request, without retries.
+func NewGCSWithChunkSize(gcs *storage.Client, bucket string, chunkSize int) Storage {
 	return &gcsImpl{
-		gcs:    gcs,
-		bucket: bucket,
+		gcs:       gcs,
+		bucket:    bucket,
+		chunkSize: chunkSize,
 	}
 }
 
@@ -38,14 +54,14 @@ func (s *gcsImpl) SaveFile(ctx context.Context, filePath string, file []byte) er
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
 	defer t.ObserveDuration()
 
-	// Connect to the bucket.
-	bkt := s.gcs.Bucket(s.bucket)
-
 	// Create a new file in the bucket.
-	w := bkt.Object(filePath).NewWriter(ctx)
+	w, err := s.Writer(ctx, filePath)
+	if err != nil {
+		return err
+	}
 
 	// Write the file to the bucket.
-	_, err := w.Write(file)
+	_, err = w.Write(file)
 	if err != nil {
 		return fmt.Errorf("error writing file to bucket: %w", err)
 	}
@@ -64,13 +80,10 @@ func (s *gcsImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, er
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
 	defer t.ObserveDuration()
 
-	// Connect to the bucket.
-	bkt := s.gcs.Bucket(s.bucket)
-
 	// Open the file.
-	r, err := bkt.Object(filePath).NewReader(ctx)
+	r, err := s.Reader(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
 
 	// Read the file.
@@ -88,6 +101,58 @@ func (s *gcsImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, er
 	return file, nil
 }
 
+// Writer uploads the file in chunks of the chunk size. The object is only created once the writer is closed, and the
+// upload is abandoned if the context is cancelled first.
+func (s *gcsImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	// Connect to the bucket.
+	bkt := s.gcs.Bucket(s.bucket)
+
+	// Create a new file in the bucket.
+	w := bkt.Object(filePath).NewWriter(ctx)
+	w.ChunkSize = s.chunkSize
+
+	return w, nil
+}
+
+func (s *gcsImpl) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	// Connect to the bucket.
+	bkt := s.gcs.Bucket(s.bucket)
+
+	// Open the file.
+	r, err := bkt.Object(filePath).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", gcsError(err))
+	}
+
+	return r, nil
+}
+
+func (s *gcsImpl) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	// Connect to the bucket.
+	bkt := s.gcs.Bucket(s.bucket)
+
+	// Get a list of all the files in the bucket with the prefix.
+	it := bkt.Objects(ctx, &storage.Query{Prefix: prefix})
+
+	files := make([]string, 0)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error getting file next: %w", err)
+		}
+
+		files = append(files, attrs.Name)
+	}
+
+	return files, nil
+}
+
 func (s *gcsImpl) DeleteFile(ctx context.Context, filePath string) error {
 	// Start the prometheus timer.
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
@@ -99,12 +164,14 @@ func (s *gcsImpl) DeleteFile(ctx context.Context, filePath string) error {
 	// Delete the file.
 	err := bkt.Object(filePath).Delete(ctx)
 	if err != nil {
-		return fmt.Errorf("error deleting file: %w", err)
+		return fmt.Errorf("error deleting file: %w", gcsError(err))
 	}
 
 	return nil
 }
 
+// Purge deletes the dumps under the dumps/ prefix of the bucket that were taken before the time. Other files, such as
+// DDL snapshots, are kept.
 func (s *gcsImpl) Purge(ctx context.Context, from time.Time) (int, error) {
 	// Start the prometheus timer.
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "purge"}))
@@ -113,8 +180,8 @@ func (s *gcsImpl) Purge(ctx context.Context, from time.Time) (int, error) {
 	// Connect to the bucket.
 	bkt := s.gcs.Bucket(s.bucket)
 
-	// Get a list of all the files in the bucket.
-	it := bkt.Objects(ctx, nil)
+	// Get a list of all the dumps in the bucket.
+	it := bkt.Objects(ctx, &storage.Query{Prefix: "dumps/"})
 
 	count := 0
 
@@ -129,24 +196,15 @@ func (s *gcsImpl) Purge(ctx context.Context, from time.Time) (int, error) {
 			return 0, fmt.Errorf("error getting file next: %w", err)
 		}
 
-		// Get the file name.
-		fileName := attrs.Name
-
-		// Ignore all non-SQL files.
-		if !strings.HasSuffix(fileName, ".sql") {
+		// Ignore all files that are not dumps.
+		if !isDumpFile(attrs.Name) {
 			continue
 		}
 
-		// Remove the path (report/environment/fqdn) from the file name.
-		fileName = fileName[strings.LastIndex(fileName, "/")+1:]
-
-		// Remove the file extension.
-		fileName = fileName[:len(fileName)-len(".sql")]
-
 		// Parse the file date from the file name.
-		fileDate, err := time.Parse(time.RFC3339, fileName)
+		fileDate, err := dumpFileTime(attrs.Name)
 		if err != nil {
-			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", fileName))
+			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", attrs.Name))
 			continue
 		}
 
@@ -166,3 +224,41 @@ func (s *gcsImpl) Purge(ctx context.Context, from time.Time) (int, error) {
 
 	return count, nil
 }
+
+// gcsError marks the error for a missing object as fs.ErrNotExist.
+func gcsError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return notExist(err)
+	}
+
+	return err
+}
+
+// openGCS opens a gs://<bucket>/<prefix> destination, with the service account in GCS_CREDENTIALS.
+func openGCS(ctx context.Context, u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, errors.New("a bucket is required, e.g. gs://<bucket>/<prefix>")
+	}
+
+	if _, err := destQuery(u); err != nil {
+		return nil, err
+	}
+
+	// Get the service account credentials from the environment variable.
+	gcsCredentials := os.Getenv(EnvGCSCredentials)
+	if gcsCredentials == "" {
+		return nil, errors.New("GCS_CREDENTIALS environment variable not set")
+	}
+
+	client, err := storage.NewClient(ctx, option.WithCredentialsJSON([]byte(gcsCredentials)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating GCS client: %w", err)
+	}
+
+	_, err = client.Bucket(u.Host).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error checking bucket: %w", err)
+	}
+
+	return WithPrefix(NewGCS(client, u.Host), bucketPrefix(u)), nil
+}
@@ -0,0 +1,112 @@
+package dataaccess
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS is an in-memory GCS JSON API server with the requests used to list and delete objects, for a single bucket.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeGCS(t *testing.T) (*fakeGCS, *storage.Client) {
+	f := &fakeGCS{
+		objects: make(map[string][]byte),
+	}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(srv.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return f, client
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The path is /storage/v1/b/<bucket>/o[/<object>].
+	_, name, _ := strings.Cut(r.URL.EscapedPath(), "/o")
+	name, _ = url.PathUnescape(strings.TrimPrefix(name, "/"))
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		type object struct {
+			Name string `json:"name"`
+		}
+
+		names := make([]string, 0)
+		for n := range f.objects {
+			if strings.HasPrefix(n, r.URL.Query().Get("prefix")) {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+
+		res := struct {
+			Items []object `json:"items"`
+		}{}
+		for _, n := range names {
+			res.Items = append(res.Items, object{Name: n})
+		}
+
+		_ = json.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestGCS_Purge(t *testing.T) {
+	f, client := newFakeGCS(t)
+	s := NewGCS(client, "backups")
+
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -60).Format(time.RFC3339)
+	for _, name := range []string{
+		"dumps/app/" + old + ".sql",
+		"dumps/app/" + now.Format(time.RFC3339) + ".sql",
+		"ddl/app/" + old + ".sql",
+	} {
+		f.objects[name] = []byte("--")
+	}
+
+	count, err := s.Purge(context.Background(), now.AddDate(0, 0, -30))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// DDL snapshots are not dumps, whatever their age.
+	require.Contains(t, f.objects, "ddl/app/"+old+".sql")
+	require.NotContains(t, f.objects, "dumps/app/"+old+".sql")
+	require.Len(t, f.objects, 2)
+
+	require.ErrorIs(t, s.DeleteFile(context.Background(), "dumps/app/"+old+".sql"), fs.ErrNotExist)
+}
@@ -2,19 +2,74 @@ package dataaccess
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
 	"time"
 )
 
+// dumpExtensions are the extensions of the files that are purged: SQL dumps and SQLite backups.
+var dumpExtensions = map[string]bool{
+	".sql":    true,
+	".sqlite": true,
+}
+
+// isDumpFile reports whether the file is a dump that can be purged.
+func isDumpFile(name string) bool {
+	return dumpExtensions[path.Ext(name)]
+}
+
+// dumpFileTime returns the time a dump was taken from its file name, e.g. dumps/app/2024-01-02T03:04:05Z.sql.
+func dumpFileTime(name string) (time.Time, error) {
+	// Remove the path (report/environment/fqdn) and the file extension from the file name.
+	name = path.Base(name)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	return time.Parse(time.RFC3339, name)
+}
+
+// notExist marks the error of a backend for a missing file as fs.ErrNotExist, so that missing files can be checked for
+// with errors.Is whatever the backend.
+func notExist(err error) error {
+	return fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+}
+
 type Storage interface {
 	// SaveFile uploads a file to the storage bucket. This will replace any existing file with the same name.
 	SaveFile(ctx context.Context, filePath string, file []byte) error
 
-	// DownloadFile downloads a file from the storage bucket.
+	// DownloadFile downloads a file from the storage bucket. The error matches fs.ErrNotExist if the file does not exist.
 	DownloadFile(ctx context.Context, filePath string) ([]byte, error)
 
-	// DeleteFile deletes a file from the storage bucket.
+	// Writer returns a writer that uploads a file to the storage bucket as it is written, so that large files do not
+	// have to be held in memory. The file is only saved once the writer is closed, replacing any existing file with the
+	// same name. Nothing is saved if a write fails or the context is cancelled before the writer is closed.
+	Writer(ctx context.Context, filePath string) (io.WriteCloser, error)
+
+	// Reader returns a reader that downloads a file from the storage bucket as it is read. The reader must be closed.
+	// The error matches fs.ErrNotExist if the file does not exist.
+	Reader(ctx context.Context, filePath string) (io.ReadCloser, error)
+
+	// ListFiles lists the files in the storage bucket that start with the given prefix.
+	ListFiles(ctx context.Context, prefix string) ([]string, error)
+
+	// DeleteFile deletes a file from the storage bucket. The error matches fs.ErrNotExist if the file does not exist, on
+	// the backends that report it.
 	DeleteFile(ctx context.Context, filePath string) error
 
 	// Purge purges the data from the storage bucket out of the given range.
 	Purge(ctx context.Context, from time.Time) (int, error)
 }
+
+// Close releases the connections held by the storage, such as the SSH connection of an SFTP destination. Storages that
+// hold none are left as they are.
+func Close(s Storage) error {
+	c, ok := s.(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	return c.Close()
+}
@@ -2,13 +2,18 @@ package dataaccess
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -18,26 +23,21 @@ func NewLocal() Storage {
 	return &localImpl{}
 }
 
-func (s *localImpl) SaveFile(_ context.Context, filePath string, file []byte) error {
+func (s *localImpl) SaveFile(ctx context.Context, filePath string, file []byte) error {
 	// Start the prometheus timer.
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
 	defer t.ObserveDuration()
 
-	// Create a new file in the working directory with all directories.
-	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("error creating directories: %w", err)
-	}
-
-	w, err := os.Create(filePath)
+	w, err := s.Writer(ctx, filePath)
 	if err != nil {
-		return fmt.Errorf("error creating file: %w", err)
+		return err
 	}
 
-	// Write the file to the bucket.
+	// Write the file.
 	_, err = w.Write(file)
 	if err != nil {
-		return fmt.Errorf("error writing file to bucket: %w", err)
+		_ = w.Close()
+		return fmt.Errorf("error writing file: %w", err)
 	}
 
 	// Close the file.
@@ -49,15 +49,15 @@ func (s *localImpl) SaveFile(_ context.Context, filePath string, file []byte) er
 	return nil
 }
 
-func (s *localImpl) DownloadFile(_ context.Context, filePath string) ([]byte, error) {
+func (s *localImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
 	// Start the prometheus timer.
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
 	defer t.ObserveDuration()
 
 	// Open the file.
-	r, err := os.Open(filePath)
+	r, err := s.Reader(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
 
 	// Read the file.
@@ -75,6 +75,137 @@ func (s *localImpl) DownloadFile(_ context.Context, filePath string) ([]byte, er
 	return file, nil
 }
 
+// Writer writes to a temporary file next to the file, which is renamed into place once the writer is closed, so that
+// a failed or cancelled save never leaves a partial file behind.
+func (s *localImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	// Create a new file in the working directory with all directories.
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directories: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %w", err)
+	}
+
+	// Temporary files are only readable by the owner, unlike files created with os.Create.
+	if err := f.Chmod(0o644); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("error setting file mode: %w", err)
+	}
+
+	return &localWriter{
+		ctx:  ctx,
+		f:    f,
+		path: filePath,
+	}, nil
+}
+
+func (s *localImpl) Reader(_ context.Context, filePath string) (io.ReadCloser, error) {
+	// Open the file.
+	r, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	return r, nil
+}
+
+// localWriter writes a file through a temporary file.
+type localWriter struct {
+	// ctx is the context of the save. The file is not saved if it is cancelled before the writer is closed.
+	ctx context.Context
+
+	// f is the temporary file.
+	f *os.File
+
+	// path is the path the file is saved to.
+	path string
+
+	// err is the first error writing the temporary file.
+	err error
+}
+
+func (w *localWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.f.Write(p)
+	if err != nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+// Close saves the file, unless a write failed or the context has been cancelled. The temporary file is always removed.
+func (w *localWriter) Close() error {
+	defer func() {
+		if err := os.Remove(w.f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Error removing temporary file", slog.String(logging.KeyError, err.Error()))
+		}
+	}()
+
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	if w.err != nil {
+		return fmt.Errorf("error writing file: %w", w.err)
+	}
+
+	if err := w.ctx.Err(); err != nil {
+		return fmt.Errorf("error saving file: %w", err)
+	}
+
+	// Move the complete file into place.
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		return fmt.Errorf("error renaming file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *localImpl) ListFiles(_ context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	// Walk the directory that the prefix lives in.
+	root := filepath.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		root = filepath.Clean(prefix)
+	}
+
+	files := make([]string, 0)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		p = filepath.ToSlash(p)
+		if strings.HasPrefix(p, prefix) {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	return files, nil
+}
+
 func (s *localImpl) DeleteFile(_ context.Context, filePath string) error {
 	// Start the prometheus timer.
 	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
@@ -106,8 +237,8 @@ func (s *localImpl) Purge(_ context.Context, from time.Time) (int, error) {
 			continue
 		}
 
-		// Ignore all non-SQL files.
-		if !strings.HasSuffix(file.Name(), ".sql") {
+		// Ignore all files that are not dumps.
+		if !isDumpFile(file.Name()) {
 			continue
 		}
 
@@ -127,3 +258,31 @@ func (s *localImpl) Purge(_ context.Context, from time.Time) (int, error) {
 
 	return count, nil
 }
+
+// openLocal opens a file:///<dir> destination, or file:<dir> for a directory relative to the working directory.
+func openLocal(_ context.Context, u *url.URL) (Storage, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("remote host %q is not supported, e.g. file:///<dir>", u.Host)
+	}
+
+	if _, err := destQuery(u); err != nil {
+		return nil, err
+	}
+
+	dir := u.Path
+	if u.Opaque != "" {
+		dir = u.Opaque
+	}
+
+	if dir == "" {
+		return nil, errors.New("a directory is required, e.g. file:///<dir>")
+	}
+
+	// The paths listed by the storage are clean, so the prefix must be too.
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if dir == "." {
+		return NewLocal(), nil
+	}
+
+	return WithPrefix(NewLocal(), dir), nil
+}
@@ -0,0 +1,56 @@
+package dataaccess
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalWriter(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "dumps", "app", "dump.sql")
+	s := NewLocal()
+
+	w, err := s.Writer(context.Background(), p)
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "CREATE TABLE t;\n")
+	require.NoError(t, err)
+
+	// Nothing is saved until the writer is closed.
+	_, err = os.Stat(p)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, w.Close())
+
+	r, err := s.Reader(context.Background(), p)
+	require.NoError(t, err)
+
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	// A cancelled save leaves the existing file in place, and no temporary file behind.
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = s.Writer(ctx, p)
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "partial")
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, w.Close(), context.Canceled)
+
+	got, err = os.ReadFile(p)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	entries, err := os.ReadDir(filepath.Dir(p))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
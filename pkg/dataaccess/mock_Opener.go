@@ -0,0 +1,59 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package dataaccess
+
+import (
+	context "context"
+	url "net/url"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockOpener is an autogenerated mock type for the Opener type
+type MockOpener struct {
+	mock.Mock
+}
+
+// Execute provides a mock function with given fields: ctx, u
+func (_m *MockOpener) Execute(ctx context.Context, u *url.URL) (Storage, error) {
+	ret := _m.Called(ctx, u)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 Storage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *url.URL) (Storage, error)); ok {
+		return rf(ctx, u)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *url.URL) Storage); ok {
+		r0 = rf(ctx, u)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(Storage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *url.URL) error); ok {
+		r1 = rf(ctx, u)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewMockOpener creates a new instance of MockOpener. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockOpener(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockOpener {
+	mock := &MockOpener{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,9 +4,11 @@ package dataaccess
 
 import (
 	context "context"
-	time "time"
+	io "io"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // MockStorage is an autogenerated mock type for the Storage type
@@ -62,6 +64,36 @@ func (_m *MockStorage) DownloadFile(ctx context.Context, filePath string) ([]byt
 	return r0, r1
 }
 
+// ListFiles provides a mock function with given fields: ctx, prefix
+func (_m *MockStorage) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	ret := _m.Called(ctx, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListFiles")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]string, error)); ok {
+		return rf(ctx, prefix)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Purge provides a mock function with given fields: ctx, from
 func (_m *MockStorage) Purge(ctx context.Context, from time.Time) (int, error) {
 	ret := _m.Called(ctx, from)
@@ -90,6 +122,36 @@ func (_m *MockStorage) Purge(ctx context.Context, from time.Time) (int, error) {
 	return r0, r1
 }
 
+// Reader provides a mock function with given fields: ctx, filePath
+func (_m *MockStorage) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, filePath)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reader")
+	}
+
+	var r0 io.ReadCloser
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (io.ReadCloser, error)); ok {
+		return rf(ctx, filePath)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.ReadCloser); ok {
+		r0 = rf(ctx, filePath)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, filePath)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SaveFile provides a mock function with given fields: ctx, filePath, file
 func (_m *MockStorage) SaveFile(ctx context.Context, filePath string, file []byte) error {
 	ret := _m.Called(ctx, filePath, file)
@@ -108,6 +170,36 @@ func (_m *MockStorage) SaveFile(ctx context.Context, filePath string, file []byt
 	return r0
 }
 
+// Writer provides a mock function with given fields: ctx, filePath
+func (_m *MockStorage) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	ret := _m.Called(ctx, filePath)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Writer")
+	}
+
+	var r0 io.WriteCloser
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (io.WriteCloser, error)); ok {
+		return rf(ctx, filePath)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.WriteCloser); ok {
+		r0 = rf(ctx, filePath)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, filePath)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewMockStorage creates a new instance of MockStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockStorage(t interface {
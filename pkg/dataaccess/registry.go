@@ -0,0 +1,200 @@
+package dataaccess
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opener returns the storage of a destination URL, such as gs://bucket/prefix. The storage keeps its files under the
+// path of the URL.
+type Opener func(ctx context.Context, u *url.URL) (Storage, error)
+
+var (
+	// openersMtx guards openers.
+	openersMtx sync.RWMutex
+
+	// openers are the openers of the destination URLs by scheme.
+	openers = map[string]Opener{
+		"gs":     openGCS,
+		"s3":     openS3,
+		"azblob": openAzure,
+		"sftp":   openSFTP,
+		"file":   openLocal,
+	}
+)
+
+// Register sets the opener of the destination URLs with the scheme, replacing any existing opener.
+func Register(scheme string, o Opener) {
+	openersMtx.Lock()
+	defer openersMtx.Unlock()
+
+	openers[strings.ToLower(scheme)] = o
+}
+
+// Schemes returns the schemes of the destination URLs that can be opened, sorted.
+func Schemes() []string {
+	openersMtx.RLock()
+	defer openersMtx.RUnlock()
+
+	schemes := make([]string, 0, len(openers))
+	for s := range openers {
+		schemes = append(schemes, s)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open returns the storage of a destination URL. The built-in destinations are:
+//
+//	gs://<bucket>/<prefix>               Google Cloud Storage.
+//	s3://<bucket>/<prefix>               Amazon S3, or an S3 compatible store.
+//	azblob://<container>/<prefix>        Azure Blob Storage.
+//	sftp://[user@]<host>[:<port>]/<dir>  A directory on an SFTP server.
+//	file:///<dir>                        A local directory.
+//
+// Options are given as query parameters, e.g. s3://backups/app?region=eu-west-1. The files of the storage are kept
+// under the prefix, so that several destinations can share a bucket.
+func Open(ctx context.Context, dest string) (Storage, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing destination: %w", err)
+	}
+
+	openersMtx.RLock()
+	o, ok := openers[strings.ToLower(u.Scheme)]
+	openersMtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported destination %q, the scheme must be one of %s", dest, strings.Join(Schemes(), ", "))
+	}
+
+	s, err := o(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s destination: %w", u.Scheme, err)
+	}
+
+	return s, nil
+}
+
+// bucketPrefix returns the prefix of the files in a bucket from the path of a destination URL.
+func bucketPrefix(u *url.URL) string {
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// destQuery returns the query parameters of a destination URL, failing on any parameter that is not one of the keys so
+// that a misspelt option is not silently ignored.
+func destQuery(u *url.URL, keys ...string) (url.Values, error) {
+	q := u.Query()
+	for k := range q {
+		if !slices.Contains(keys, k) {
+			return nil, fmt.Errorf("unsupported option %q", k)
+		}
+	}
+
+	return q, nil
+}
+
+type prefixImpl struct {
+	// s is the storage the files are kept in.
+	s Storage
+
+	// prefix is prepended to the path of each file. It ends with a slash.
+	prefix string
+}
+
+// WithPrefix returns the storage with the prefix prepended to the path of each file, so that the files are kept in a
+// directory of the storage. The storage is returned as is if the prefix is empty.
+func WithPrefix(s Storage, prefix string) Storage {
+	if prefix == "" {
+		return s
+	}
+
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &prefixImpl{
+		s:      s,
+		prefix: prefix,
+	}
+}
+
+func (p *prefixImpl) SaveFile(ctx context.Context, filePath string, file []byte) error {
+	return p.s.SaveFile(ctx, p.prefix+filePath, file)
+}
+
+func (p *prefixImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	return p.s.DownloadFile(ctx, p.prefix+filePath)
+}
+
+func (p *prefixImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	return p.s.Writer(ctx, p.prefix+filePath)
+}
+
+func (p *prefixImpl) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	return p.s.Reader(ctx, p.prefix+filePath)
+}
+
+func (p *prefixImpl) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	files, err := p.s.ListFiles(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range files {
+		files[i] = strings.TrimPrefix(f, p.prefix)
+	}
+
+	return files, nil
+}
+
+func (p *prefixImpl) DeleteFile(ctx context.Context, filePath string) error {
+	return p.s.DeleteFile(ctx, p.prefix+filePath)
+}
+
+// Purge deletes the dumps under the dumps directory of the prefix that were taken before the time, by the timestamp
+// in their names. Files outside of the prefix are left alone.
+func (p *prefixImpl) Purge(ctx context.Context, from time.Time) (int, error) {
+	files, err := p.ListFiles(ctx, "dumps/")
+	if err != nil {
+		return 0, fmt.Errorf("error listing files: %w", err)
+	}
+
+	count := 0
+	for _, name := range files {
+		// Ignore all files that are not dumps.
+		if !isDumpFile(name) {
+			continue
+		}
+
+		// Parse the file date from the file name.
+		fileDate, err := dumpFileTime(name)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", name))
+			continue
+		}
+
+		// Check if the file date is before the purge date.
+		if fileDate.After(from) {
+			continue
+		}
+
+		// Delete the file.
+		if err := p.DeleteFile(ctx, name); err != nil {
+			return 0, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,498 @@
+package dataaccess
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultS3PartSize is the size of the parts files are uploaded to S3 in. Each upload buffers a part in memory. S3
+	// allows at most 10,000 parts in a file, so this limits files to about 156 GiB.
+	DefaultS3PartSize = 16 << 20
+
+	// MinS3PartSize is the smallest part S3 accepts, other than the last part of a file.
+	MinS3PartSize = 5 << 20
+)
+
+// S3Encryption is the server-side encryption of the files uploaded to S3.
+type S3Encryption struct {
+	// Algorithm is the encryption algorithm, AES256 or aws:kms. The default encryption of the bucket is used if empty.
+	Algorithm types.ServerSideEncryption
+
+	// KMSKeyID is the ID or ARN of the KMS key used with aws:kms. The AWS managed key is used if empty.
+	KMSKeyID string
+}
+
+type s3Impl struct {
+	// s3 is the S3 client.
+	s3 *s3.Client
+
+	// bucket is the name of the bucket to use.
+	bucket string
+
+	// partSize is the size of the parts files are uploaded in.
+	partSize int
+
+	// encryption is the server-side encryption of uploaded files.
+	encryption S3Encryption
+}
+
+func NewS3(client *s3.Client, bucket string, encryption S3Encryption) Storage {
+	return NewS3WithPartSize(client, bucket, encryption, DefaultS3PartSize)
+}
+
+// NewS3WithPartSize returns the S3 storage, uploading files in parts of the given size, which must be at least
+// MinS3PartSize. Files smaller than a part are uploaded in a single request.
+func NewS3WithPartSize(client *s3.Client, bucket string, encryption S3Encryption, partSize int) Storage {
+	return &s3Impl{
+		s3:         client,
+		bucket:     bucket,
+		partSize:   partSize,
+		encryption: encryption,
+	}
+}
+
+func (s *s3Impl) SaveFile(ctx context.Context, filePath string, file []byte) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
+	defer t.ObserveDuration()
+
+	// Create a new file in the bucket.
+	w, err := s.Writer(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Write the file to the bucket.
+	_, err = w.Write(file)
+	if err != nil {
+		_ = w.Close()
+		return fmt.Errorf("error writing file to bucket: %w", err)
+	}
+
+	// Close the file.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *s3Impl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
+	defer t.ObserveDuration()
+
+	// Open the file.
+	r, err := s.Reader(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the file.
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// Close the file.
+	err = r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Writer uploads the file with a multipart upload, a part at a time as it is written. Files smaller than a part are
+// uploaded in a single request when the writer is closed. The multipart upload is aborted if the save fails.
+func (s *s3Impl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	return &s3Writer{
+		ctx: ctx,
+		s:   s,
+		key: filePath,
+		buf: make([]byte, 0, s.partSize),
+	}, nil
+}
+
+func (s *s3Impl) Reader(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	// Open the file.
+	out, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(filePath),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", s3Error(err))
+	}
+
+	return out.Body, nil
+}
+
+func (s *s3Impl) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	files := make([]string, 0)
+	err := s.walk(ctx, prefix, func(obj types.Object) error {
+		files = append(files, aws.ToString(obj.Key))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (s *s3Impl) DeleteFile(ctx context.Context, filePath string) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
+	defer t.ObserveDuration()
+
+	// Delete the file.
+	_, err := s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(filePath),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting file: %w", s3Error(err))
+	}
+
+	return nil
+}
+
+// Purge deletes the dumps under the dumps/ prefix of the bucket that were taken before the time. Other files, such as
+// DDL snapshots, are kept.
+func (s *s3Impl) Purge(ctx context.Context, from time.Time) (int, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "purge"}))
+	defer t.ObserveDuration()
+
+	count := 0
+	err := s.walk(ctx, "dumps/", func(obj types.Object) error {
+		name := aws.ToString(obj.Key)
+
+		// Ignore all files that are not dumps.
+		if !isDumpFile(name) {
+			return nil
+		}
+
+		// Parse the file date from the file name.
+		fileDate, err := dumpFileTime(name)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", name))
+			return nil
+		}
+
+		// Check if the file date is before the purge date.
+		if fileDate.After(from) {
+			return nil
+		}
+
+		// Delete the file.
+		_, err = s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    obj.Key,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting file: %w", err)
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// walk calls fn with each file in the bucket that starts with the prefix.
+func (s *s3Impl) walk(ctx context.Context, prefix string, fn func(obj types.Object) error) error {
+	p := s3.NewListObjectsV2Paginator(s.s3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing files: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// s3Writer uploads a file to S3 a part at a time.
+type s3Writer struct {
+	// ctx is the context of the upload. The file is not saved if it is cancelled before the writer is closed.
+	ctx context.Context
+
+	s   *s3Impl
+	key string
+
+	// buf holds the data of the part being written.
+	buf []byte
+
+	// uploadID is the ID of the multipart upload, once the first part has been uploaded.
+	uploadID *string
+
+	// parts are the parts that have been uploaded.
+	parts []types.CompletedPart
+
+	// err is the first error uploading the file.
+	err error
+
+	// closed is whether the writer has been closed, after which Close returns the result of the first call.
+	closed bool
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		c := min(len(p), w.s.partSize-len(w.buf))
+		w.buf = append(w.buf, p[:c]...)
+		p = p[c:]
+		n += c
+
+		if len(w.buf) < w.s.partSize {
+			break
+		}
+
+		if err := w.uploadPart(); err != nil {
+			w.fail(err)
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Close saves the file, unless a write failed or the context has been cancelled. Closing the writer again returns the
+// result of the first call without saving the file again.
+func (w *s3Writer) Close() error {
+	if w.closed || w.err != nil {
+		w.closed = true
+		return w.err
+	}
+	w.closed = true
+
+	if err := w.ctx.Err(); err != nil {
+		w.fail(err)
+		return fmt.Errorf("error saving file: %w", err)
+	}
+
+	// Files smaller than a part are uploaded in a single request.
+	if w.uploadID == nil {
+		_, err := w.s.s3.PutObject(w.ctx, &s3.PutObjectInput{
+			Bucket:               aws.String(w.s.bucket),
+			Key:                  aws.String(w.key),
+			Body:                 bytes.NewReader(w.buf),
+			ServerSideEncryption: w.s.encryption.Algorithm,
+			SSEKMSKeyId:          kmsKeyID(w.s.encryption),
+		})
+		if err != nil {
+			err = fmt.Errorf("error uploading file: %w", err)
+			w.fail(err)
+			return err
+		}
+
+		return nil
+	}
+
+	if len(w.buf) > 0 {
+		if err := w.uploadPart(); err != nil {
+			w.fail(err)
+			return err
+		}
+	}
+
+	_, err := w.s.s3.CompleteMultipartUpload(w.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(w.s.bucket),
+		Key:             aws.String(w.key),
+		UploadId:        w.uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
+	})
+	if err != nil {
+		err = fmt.Errorf("error completing upload: %w", err)
+		w.fail(err)
+		return err
+	}
+
+	return nil
+}
+
+// uploadPart uploads the buffered data as the next part, starting the multipart upload with the first part.
+func (w *s3Writer) uploadPart() error {
+	if w.uploadID == nil {
+		out, err := w.s.s3.CreateMultipartUpload(w.ctx, &s3.CreateMultipartUploadInput{
+			Bucket:               aws.String(w.s.bucket),
+			Key:                  aws.String(w.key),
+			ServerSideEncryption: w.s.encryption.Algorithm,
+			SSEKMSKeyId:          kmsKeyID(w.s.encryption),
+		})
+		if err != nil {
+			return fmt.Errorf("error starting upload: %w", err)
+		}
+
+		w.uploadID = out.UploadId
+	}
+
+	number := aws.Int32(int32(len(w.parts) + 1))
+	out, err := w.s.s3.UploadPart(w.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(w.s.bucket),
+		Key:        aws.String(w.key),
+		UploadId:   w.uploadID,
+		PartNumber: number,
+		Body:       bytes.NewReader(w.buf),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading part %d: %w", *number, err)
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{
+		ETag:       out.ETag,
+		PartNumber: number,
+	})
+	w.buf = w.buf[:0]
+
+	return nil
+}
+
+// fail records the error and aborts the multipart upload, so that the uploaded parts are not kept, and billed, by S3.
+func (w *s3Writer) fail(err error) {
+	w.err = err
+	if w.uploadID == nil {
+		return
+	}
+
+	// The upload is aborted even if the context has been cancelled.
+	_, abortErr := w.s.s3.AbortMultipartUpload(context.WithoutCancel(w.ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(w.s.bucket),
+		Key:      aws.String(w.key),
+		UploadId: w.uploadID,
+	})
+	if abortErr != nil {
+		slog.Warn("Error aborting upload",
+			slog.String("key", w.key),
+			slog.String(logging.KeyError, abortErr.Error()),
+		)
+	}
+
+	w.uploadID = nil
+}
+
+// s3Error marks the errors for a missing object as fs.ErrNotExist. S3 itself does not report deleting a missing object
+// as an error, but some compatible stores do.
+func s3Error(err error) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return notExist(err)
+	}
+
+	return err
+}
+
+// kmsKeyID returns the KMS key of the encryption, or nil to use the AWS managed key.
+func kmsKeyID(e S3Encryption) *string {
+	if e.KMSKeyID == "" {
+		return nil
+	}
+
+	return aws.String(e.KMSKeyID)
+}
+
+// openS3 opens an s3://<bucket>/<prefix> destination, after checking the bucket can be reached. The options are:
+//
+//	region          The region of the bucket. Defaults to the region of the AWS configuration.
+//	endpoint        The URL of an S3 compatible store, such as MinIO.
+//	profile         The shared configuration profile to read credentials from.
+//	sse             The server-side encryption of uploaded files, AES256 or aws:kms.
+//	sse-kms-key-id  The KMS key to encrypt with aws:kms.
+func openS3(ctx context.Context, u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, errors.New("a bucket is required, e.g. s3://<bucket>/<prefix>")
+	}
+
+	q, err := destQuery(u, "region", "endpoint", "profile", "sse", "sse-kms-key-id")
+	if err != nil {
+		return nil, err
+	}
+
+	encryption := S3Encryption{
+		Algorithm: types.ServerSideEncryption(q.Get("sse")),
+		KMSKeyID:  q.Get("sse-kms-key-id"),
+	}
+
+	switch encryption.Algorithm {
+	case "", types.ServerSideEncryptionAes256, types.ServerSideEncryptionAwsKms:
+	default:
+		return nil, fmt.Errorf("unsupported server-side encryption %q, must be AES256 or aws:kms", encryption.Algorithm)
+	}
+
+	if encryption.KMSKeyID != "" && encryption.Algorithm != types.ServerSideEncryptionAwsKms {
+		return nil, errors.New("sse-kms-key-id requires sse=aws:kms")
+	}
+
+	opts := make([]func(*config.LoadOptions) error, 0)
+	if region := q.Get("region"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	if profile := q.Get("profile"); profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading AWS configuration: %w", err)
+	}
+
+	endpoint := q.Get("endpoint")
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint == "" {
+			return
+		}
+
+		// S3 compatible stores are addressed by path, and do not all support the checksums the SDK adds by default.
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	})
+
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(u.Host)})
+	if err != nil {
+		return nil, fmt.Errorf("error checking bucket: %w", err)
+	}
+
+	return WithPrefix(NewS3(client, u.Host, encryption), bucketPrefix(u)), nil
+}
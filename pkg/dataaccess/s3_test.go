@@ -0,0 +1,223 @@
+package dataaccess
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory S3 server with the requests used by the S3 storage, for a single bucket addressed by path.
+type fakeS3 struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	encryption map[string]string
+	uploads    map[string]map[int][]byte
+	nextID     int
+
+	// completed is the number of multipart uploads that have been completed.
+	completed int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3.Client) {
+	f := &fakeS3{
+		objects:    make(map[string][]byte),
+		encryption: make(map[string]string),
+		uploads:    make(map[string]map[int][]byte),
+	}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(srv.URL),
+		UsePathStyle:               true,
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return f, client
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The path is /bucket/key.
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		type object struct {
+			Key string
+		}
+
+		keys := make([]string, 0)
+		for k := range f.objects {
+			if strings.HasPrefix(k, q.Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		res := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []object
+		}{}
+		for _, k := range keys {
+			res.Contents = append(res.Contents, object{Key: k})
+		}
+
+		_ = xml.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+
+		_, _ = w.Write(obj)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		f.encryption[key] = r.Header.Get("X-Amz-Server-Side-Encryption")
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.uploads[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "<Error><Code>NoSuchUpload</Code></Error>")
+			return
+		}
+
+		f.completed++
+		obj := make([]byte, 0)
+		for n := 1; n <= len(parts); n++ {
+			obj = append(obj, parts[n]...)
+		}
+
+		f.objects[key] = obj
+		delete(f.uploads, q.Get("uploadId"))
+		_, _ = fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.encryption[key] = r.Header.Get("X-Amz-Server-Side-Encryption")
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestS3_SaveFile(t *testing.T) {
+	fake, client := newFakeS3(t)
+	s := NewS3(client, "backups", S3Encryption{Algorithm: "AES256"})
+
+	require.NoError(t, s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n")))
+	require.Equal(t, "AES256", fake.encryption["dumps/app/dump.sql"])
+
+	got, err := s.DownloadFile(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	_, err = s.DownloadFile(context.Background(), "dumps/app/missing.sql")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestS3_Writer(t *testing.T) {
+	fake, client := newFakeS3(t)
+	s := NewS3WithPartSize(client, "backups", S3Encryption{Algorithm: "aws:kms"}, 8)
+
+	// The file is uploaded in parts of 8 bytes.
+	w, err := s.Writer(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "0123456789")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "abcdefghij")
+	require.NoError(t, err)
+
+	require.Len(t, fake.uploads, 1)
+	require.NoError(t, w.Close())
+	require.Equal(t, "0123456789abcdefghij", string(fake.objects["dumps/app/dump.sql"]))
+	require.Equal(t, "aws:kms", fake.encryption["dumps/app/dump.sql"])
+	require.Empty(t, fake.uploads)
+
+	// Closing again does not complete the upload again.
+	completed := fake.completed
+	require.NoError(t, w.Close())
+	require.Equal(t, completed, fake.completed)
+
+	// A cancelled upload is aborted, and nothing is saved.
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = s.Writer(ctx, "dumps/app/cancelled.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "0123456789")
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, w.Close(), context.Canceled)
+	require.NotContains(t, fake.objects, "dumps/app/cancelled.sql")
+	require.Empty(t, fake.uploads)
+}
+
+func TestS3_Purge(t *testing.T) {
+	fake, client := newFakeS3(t)
+	s := NewS3(client, "backups", S3Encryption{})
+
+	now := time.Now().UTC()
+	for _, name := range []string{
+		"dumps/app/2020-01-01T00:00:00Z.sql",
+		"dumps/app/2020-01-02T00:00:00Z.sqlite",
+		"dumps/app/" + now.Format(time.RFC3339) + ".sql",
+		"dumps/app/notes.txt",
+		"dumps/app/latest.sql",
+		"ddl/app/2020-01-01T00:00:00Z.sql",
+	} {
+		fake.objects[name] = []byte("--")
+	}
+
+	files, err := s.ListFiles(context.Background(), "dumps/app/2020")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/2020-01-01T00:00:00Z.sql", "dumps/app/2020-01-02T00:00:00Z.sqlite"}, files)
+
+	count, err := s.Purge(context.Background(), now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Len(t, fake.objects, 4)
+
+	// DDL snapshots are not dumps, whatever their age.
+	require.Contains(t, fake.objects, "ddl/app/2020-01-01T00:00:00Z.sql")
+
+	require.NoError(t, s.DeleteFile(context.Background(), "dumps/app/notes.txt"))
+	require.NotContains(t, fake.objects, "dumps/app/notes.txt")
+}
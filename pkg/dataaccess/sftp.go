@@ -0,0 +1,528 @@
+package dataaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/pkg/sftp"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// EnvSFTPPassword is the password of the SFTP user.
+	EnvSFTPPassword = "SFTP_PASSWORD"
+
+	// EnvSFTPKeyPassphrase is the passphrase of the private key of the SFTP user.
+	EnvSFTPKeyPassphrase = "SFTP_KEY_PASSPHRASE"
+)
+
+// SSHConfig is the configuration of an SSH connection to an SFTP server.
+type SSHConfig struct {
+	// Addr is the host and port of the server, e.g. backups.example.com:22.
+	Addr string
+
+	// User is the user to log in as.
+	User string
+
+	// Password is the password of the user. Password authentication is not used if empty.
+	Password string
+
+	// KeyFile is the path of the private key of the user. Key authentication is not used if empty.
+	KeyFile string
+
+	// KeyPassphrase is the passphrase of the private key, if it is encrypted.
+	KeyPassphrase string
+
+	// KnownHostsFile is the known_hosts file the key of the server is verified against.
+	KnownHostsFile string
+
+	// Timeout is the maximum duration of the connection and handshake. There is no timeout if zero.
+	Timeout time.Duration
+}
+
+// DialSSH connects to the server, verifying its key against the known hosts and logging in with the key or password.
+func DialSSH(ctx context.Context, cfg SSHConfig) (*ssh.Client, error) {
+	if cfg.KnownHostsFile == "" {
+		return nil, errors.New("a known_hosts file is required to verify the server")
+	}
+
+	hostKeyCallback, err := knownhosts.New(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading known hosts: %w", err)
+	}
+
+	auth := make([]ssh.AuthMethod, 0)
+	if cfg.KeyFile != "" {
+		key, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private key: %w", err)
+		}
+
+		var signer ssh.Signer
+		if cfg.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing private key: %w", err)
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("a private key or password is required")
+	}
+
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	d := new(net.Dialer)
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %w", err)
+	}
+
+	// The handshake is bounded by the context.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, cfg.Addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error connecting to server: %w", err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+type sftpImpl struct {
+	// sftp is the SFTP client.
+	sftp *sftp.Client
+
+	// dir is the directory on the server that files are saved in.
+	dir string
+
+	// conn is the SSH connection of the client, which is closed with the storage. It is nil if the caller owns the
+	// client.
+	conn *ssh.Client
+}
+
+// NewSFTP returns the storage of the directory on the SFTP server. Relative directories are relative to the login
+// directory of the user.
+func NewSFTP(client *sftp.Client, dir string) Storage {
+	return &sftpImpl{
+		sftp: client,
+		dir:  dir,
+	}
+}
+
+// Close closes the SFTP session and the SSH connection of a storage opened from a destination URL. The client given to
+// NewSFTP is left open for the caller to close.
+func (s *sftpImpl) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return errors.Join(s.sftp.Close(), s.conn.Close())
+}
+
+// remotePath returns the path of the file on the server.
+func (s *sftpImpl) remotePath(filePath string) string {
+	return path.Join(s.dir, filePath)
+}
+
+func (s *sftpImpl) SaveFile(ctx context.Context, filePath string, file []byte) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "save_file"}))
+	defer t.ObserveDuration()
+
+	w, err := s.Writer(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Write the file.
+	_, err = w.Write(file)
+	if err != nil {
+		_ = w.Close()
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	// Close the file.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sftpImpl) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "download_file"}))
+	defer t.ObserveDuration()
+
+	// Open the file.
+	r, err := s.Reader(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the file.
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// Close the file.
+	err = r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Writer writes to a temporary file next to the file on the server, which is renamed into place once the writer is
+// closed, so that a failed or cancelled save never leaves a partial file behind. Missing directories are created.
+func (s *sftpImpl) Writer(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	p := s.remotePath(filePath)
+
+	err := s.sftp.MkdirAll(path.Dir(p))
+	if err != nil {
+		return nil, fmt.Errorf("error creating directories: %w", err)
+	}
+
+	tmp := path.Join(path.Dir(p), fmt.Sprintf(".%s.%d.tmp", path.Base(p), time.Now().UnixNano()))
+	f, err := s.sftp.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %w", err)
+	}
+
+	return &sftpWriter{
+		ctx:  ctx,
+		s:    s,
+		f:    f,
+		path: p,
+	}, nil
+}
+
+func (s *sftpImpl) Reader(_ context.Context, filePath string) (io.ReadCloser, error) {
+	// Open the file.
+	f, err := s.sftp.Open(s.remotePath(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	return f, nil
+}
+
+func (s *sftpImpl) ListFiles(_ context.Context, prefix string) ([]string, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "list_files"}))
+	defer t.ObserveDuration()
+
+	// Walk the directory that the prefix lives in.
+	root := path.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		root = path.Clean(prefix)
+	}
+
+	files := make([]string, 0)
+	err := s.walk(root, func(name string) error {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (s *sftpImpl) DeleteFile(_ context.Context, filePath string) error {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "delete_file"}))
+	defer t.ObserveDuration()
+
+	// Delete the file.
+	err := s.sftp.Remove(s.remotePath(filePath))
+	if err != nil {
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes the dumps under the dumps directory on the server that were taken before the time.
+func (s *sftpImpl) Purge(ctx context.Context, from time.Time) (int, error) {
+	// Start the prometheus timer.
+	t := prometheus.NewTimer(StorageLatency.With(prometheus.Labels{"query": "purge"}))
+	defer t.ObserveDuration()
+
+	count := 0
+	err := s.walk("dumps", func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Ignore all files that are not dumps.
+		if !isDumpFile(name) {
+			return nil
+		}
+
+		// Parse the file date from the file name.
+		fileDate, err := dumpFileTime(name)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Error parsing file date from file name: %s", name))
+			return nil
+		}
+
+		// Check if the file date is before the purge date.
+		if fileDate.After(from) {
+			return nil
+		}
+
+		// Delete the file.
+		err = s.sftp.Remove(s.remotePath(name))
+		if err != nil {
+			return fmt.Errorf("error deleting file: %w", err)
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// walk calls fn with the path, relative to the directory of the storage, of each file under root. A missing root has
+// no files.
+func (s *sftpImpl) walk(root string, fn func(name string) error) error {
+	w := s.sftp.Walk(s.remotePath(root))
+	for w.Step() {
+		if err := w.Err(); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error walking directory: %w", err)
+		}
+
+		if w.Stat().IsDir() {
+			continue
+		}
+
+		name := w.Path()
+		if s.dir != "" {
+			name = strings.TrimPrefix(name, strings.TrimSuffix(path.Clean(s.dir), "/")+"/")
+		}
+
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sftpWriter writes a file on the server through a temporary file.
+type sftpWriter struct {
+	// ctx is the context of the save. The file is not saved if it is cancelled before the writer is closed.
+	ctx context.Context
+
+	s *sftpImpl
+
+	// f is the temporary file.
+	f *sftp.File
+
+	// path is the path the file is saved to.
+	path string
+
+	// err is the first error writing the temporary file.
+	err error
+}
+
+func (w *sftpWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if err := w.ctx.Err(); err != nil {
+		w.err = err
+		return 0, err
+	}
+
+	n, err := w.f.Write(p)
+	if err != nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+// Close saves the file, unless a write failed or the context has been cancelled. The temporary file is removed if the
+// file is not saved.
+func (w *sftpWriter) Close() error {
+	tmp := w.f.Name()
+	saved := false
+	defer func() {
+		if saved {
+			return
+		}
+
+		if err := w.s.sftp.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Error removing temporary file", slog.String(logging.KeyError, err.Error()))
+		}
+	}()
+
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	if w.err != nil {
+		return fmt.Errorf("error writing file: %w", w.err)
+	}
+
+	if err := w.ctx.Err(); err != nil {
+		return fmt.Errorf("error saving file: %w", err)
+	}
+
+	// Move the complete file into place. Servers without the POSIX rename extension refuse to replace a file.
+	if _, ok := w.s.sftp.HasExtension("posix-rename@openssh.com"); ok {
+		if err := w.s.sftp.PosixRename(tmp, w.path); err != nil {
+			return fmt.Errorf("error renaming file: %w", err)
+		}
+	} else {
+		if err := w.s.sftp.Remove(w.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error replacing file: %w", err)
+		}
+
+		if err := w.s.sftp.Rename(tmp, w.path); err != nil {
+			return fmt.Errorf("error renaming file: %w", err)
+		}
+	}
+
+	saved = true
+	return nil
+}
+
+// openSFTP opens an sftp://[user@]host[:port]/<dir> destination. The directory is absolute, or relative to the login
+// directory if it starts with /~/. The options are:
+//
+//	key          The private key to log in with. The passphrase is read from SFTP_KEY_PASSPHRASE.
+//	known-hosts  The known_hosts file the key of the server is verified against. Defaults to ~/.ssh/known_hosts.
+//
+// The password is read from SFTP_PASSWORD. The user defaults to the current user and the port to 22.
+func openSFTP(ctx context.Context, u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, errors.New("a host is required, e.g. sftp://<host>/<dir>")
+	}
+
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("the password must be set in %s, not the destination", EnvSFTPPassword)
+	}
+
+	q, err := destQuery(u, "key", "known-hosts")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := SSHConfig{
+		Addr:           u.Host,
+		User:           u.User.Username(),
+		Password:       os.Getenv(EnvSFTPPassword),
+		KeyFile:        q.Get("key"),
+		KeyPassphrase:  os.Getenv(EnvSFTPKeyPassphrase),
+		KnownHostsFile: q.Get("known-hosts"),
+		Timeout:        30 * time.Second,
+	}
+
+	if u.Port() == "" {
+		cfg.Addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	if cfg.User == "" {
+		cu, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("error getting current user: %w", err)
+		}
+
+		cfg.User = cu.Username
+	}
+
+	if cfg.KnownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("error getting home directory: %w", err)
+		}
+
+		cfg.KnownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	conn, err := DialSSH(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error starting SFTP session: %w", err)
+	}
+
+	return &sftpImpl{
+		sftp: client,
+		dir:  sftpDir(u.Path),
+		conn: conn,
+	}, nil
+}
+
+// sftpDir returns the directory of the storage on the server from the path of a destination URL. Paths under /~ are
+// relative to the login directory.
+func sftpDir(p string) string {
+	if p == "/~" {
+		return ""
+	}
+
+	if rel, ok := strings.CutPrefix(p, "/~/"); ok {
+		return rel
+	}
+
+	return p
+}
@@ -0,0 +1,259 @@
+package dataaccess
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// errPermissionDenied is returned by the test server for unknown users, passwords and keys.
+var errPermissionDenied = errors.New("permission denied")
+
+// sshServer is an in-process SSH server with the SFTP subsystem, serving the local file system.
+type sshServer struct {
+	addr      string
+	hostKey   ssh.PublicKey
+	clientKey string
+}
+
+// newSSHServer starts a server that accepts the user "backup" with the password "secret" or the client key, which is
+// written to a file.
+func newSSHServer(t *testing.T) *sshServer {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	authorized, err := ssh.NewPublicKey(clientPub)
+	require.NoError(t, err)
+
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "backup" && string(password) == "secret" {
+				return nil, nil
+			}
+
+			return nil, errPermissionDenied
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "backup" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+
+			return nil, errPermissionDenied
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSSH(conn, cfg)
+		}
+	}()
+
+	return &sshServer{
+		addr:      l.Addr().String(),
+		hostKey:   hostSigner.PublicKey(),
+		clientKey: keyFile,
+	}
+}
+
+// serveSSH serves the SFTP subsystem on the sessions of the connection.
+func serveSSH(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				// The payload is the length prefixed name of the subsystem.
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				server, err := sftp.NewServer(ch)
+				if err != nil {
+					return
+				}
+
+				_ = server.Serve()
+				_ = ch.Close()
+			}
+		}()
+	}
+}
+
+// knownHosts writes a known_hosts file with the key for the address.
+func knownHosts(t *testing.T, addr string, key ssh.PublicKey) string {
+	p := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(p, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key)+"\n"), 0o600))
+	return p
+}
+
+// newSFTPClient logs in to the server and returns an SFTP client.
+func newSFTPClient(t *testing.T, cfg SSHConfig) *sftp.Client {
+	conn, err := DialSSH(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	client, err := sftp.NewClient(conn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func TestDialSSH(t *testing.T) {
+	srv := newSSHServer(t)
+	hosts := knownHosts(t, srv.addr, srv.hostKey)
+
+	// Both password and key authentication are accepted.
+	newSFTPClient(t, SSHConfig{Addr: srv.addr, User: "backup", Password: "secret", KnownHostsFile: hosts})
+	newSFTPClient(t, SSHConfig{Addr: srv.addr, User: "backup", KeyFile: srv.clientKey, KnownHostsFile: hosts})
+
+	_, err := DialSSH(context.Background(), SSHConfig{Addr: srv.addr, User: "backup", Password: "wrong", KnownHostsFile: hosts})
+	require.ErrorContains(t, err, "unable to authenticate")
+
+	// The key of the server must be known.
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	other, err := ssh.NewSignerFromKey(otherKey)
+	require.NoError(t, err)
+
+	_, err = DialSSH(context.Background(), SSHConfig{
+		Addr:           srv.addr,
+		User:           "backup",
+		Password:       "secret",
+		KnownHostsFile: knownHosts(t, srv.addr, other.PublicKey()),
+	})
+	require.ErrorContains(t, err, "key mismatch")
+
+	_, err = DialSSH(context.Background(), SSHConfig{Addr: srv.addr, User: "backup", Password: "secret"})
+	require.ErrorContains(t, err, "known_hosts")
+}
+
+func TestSFTP(t *testing.T) {
+	srv := newSSHServer(t)
+	client := newSFTPClient(t, SSHConfig{
+		Addr:           srv.addr,
+		User:           "backup",
+		Password:       "secret",
+		KnownHostsFile: knownHosts(t, srv.addr, srv.hostKey),
+	})
+
+	dir := t.TempDir()
+	s := NewSFTP(client, dir)
+
+	// Missing directories are created.
+	require.NoError(t, s.SaveFile(context.Background(), "dumps/app/dump.sql", []byte("CREATE TABLE t;\n")))
+
+	got, err := os.ReadFile(filepath.Join(dir, "dumps", "app", "dump.sql"))
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	r, err := s.Reader(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	got, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	// A cancelled save leaves the existing file in place, and no temporary file behind.
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := s.Writer(ctx, "dumps/app/dump.sql")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "partial")
+	require.NoError(t, err)
+
+	cancel()
+	require.ErrorIs(t, w.Close(), context.Canceled)
+
+	files, err := s.ListFiles(context.Background(), "dumps/app/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dumps/app/dump.sql"}, files)
+
+	got, err = s.DownloadFile(context.Background(), "dumps/app/dump.sql")
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t;\n", string(got))
+
+	// Purge walks the dumps directory.
+	now := time.Now().UTC()
+	for _, name := range []string{
+		"dumps/app/2020-01-01T00:00:00Z.sql",
+		"dumps/other/2020-01-02T00:00:00Z.sqlite",
+		"dumps/app/" + now.Format(time.RFC3339) + ".sql",
+		"2020-01-01T00:00:00Z.sql",
+	} {
+		require.NoError(t, s.SaveFile(context.Background(), name, []byte("--")))
+	}
+
+	count, err := s.Purge(context.Background(), now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	files, err = s.ListFiles(context.Background(), "dumps/")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"dumps/app/dump.sql", "dumps/app/" + now.Format(time.RFC3339) + ".sql"}, files)
+
+	require.NoError(t, s.DeleteFile(context.Background(), "2020-01-01T00:00:00Z.sql"))
+	require.ErrorIs(t, s.DeleteFile(context.Background(), "2020-01-01T00:00:00Z.sql"), fs.ErrNotExist)
+}
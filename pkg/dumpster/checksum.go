@@ -0,0 +1,358 @@
+package dumpster
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// crc64Table is the table of the CRC-64 checksums of table rows.
+	crc64Table = crc64.MakeTable(crc64.ECMA)
+
+	// dataDumpRegex matches the line of the comment written before the rows of a table by the default templates.
+	dataDumpRegex = regexp.MustCompile(`^-- Data dump for table (.+)$`)
+
+	// checksumCommentRegex matches the line of the comment with the checksum of a table written by the default templates.
+	checksumCommentRegex = regexp.MustCompile(`^-- Checksum for table (.+): rows=(\d+) crc64=([0-9a-f]{16})$`)
+)
+
+// TableChecksum is the row count and checksum of the rows of a table. The checksum is the CRC-64 (ECMA) of the rows as
+// they are written to the dump, e.g. ('1','alice'), each followed by a newline.
+type TableChecksum struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+
+	// Rows is the number of rows.
+	Rows int64 `json:"rows"`
+
+	// Checksum is the checksum of the rows in hexadecimal.
+	Checksum string `json:"checksum"`
+}
+
+// ChecksumResult is the comparison of the checksum of a single table.
+type ChecksumResult struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+
+	// Expected is the checksum the table should have. This is nil if the table is only in the actual checksums.
+	Expected *TableChecksum `json:"expected"`
+
+	// Actual is the checksum the table has. This is nil if the table is missing.
+	Actual *TableChecksum `json:"actual"`
+
+	// Match is true when the row count and checksum are the same.
+	Match bool `json:"match"`
+}
+
+// ChecksumReport is the result of comparing the checksums of two sets of tables.
+type ChecksumReport struct {
+	// Tables is the result for each table.
+	Tables []*ChecksumResult `json:"tables"`
+
+	// OK is true when every table matches.
+	OK bool `json:"ok"`
+}
+
+// rowChecksum computes the checksum of the rows of a table.
+type rowChecksum struct {
+	hash hash.Hash64
+	rows int64
+}
+
+// newRowChecksum returns the checksum of a table without rows.
+func newRowChecksum() *rowChecksum {
+	return &rowChecksum{
+		hash: crc64.New(crc64Table),
+	}
+}
+
+// add adds a row, as it is written to the dump, to the checksum.
+func (c *rowChecksum) add(row string) {
+	c.rows++
+
+	// Writes to a hash never fail.
+	_, _ = c.hash.Write([]byte(row))
+	_, _ = c.hash.Write([]byte{'\n'})
+}
+
+// checksum returns the checksum of the table.
+func (c *rowChecksum) checksum(name string) *TableChecksum {
+	return &TableChecksum{
+		Name:     name,
+		Rows:     c.rows,
+		Checksum: fmt.Sprintf("%016x", c.hash.Sum64()),
+	}
+}
+
+// GetTableChecksums reads the rows of the tables that would be dumped and returns their checksums, for comparison with
+// the checksums of a dump. Tables dumped without their rows are left out.
+func (d *Dumpster) GetTableChecksums(ctx context.Context) ([]*TableChecksum, error) {
+	tables, err := d.tables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables: %w", err)
+	}
+
+	checksums := make([]*TableChecksum, 0, len(tables))
+	for _, name := range tables {
+		if matchAny(d.excludeData, name) {
+			continue
+		}
+
+		_, rows, err := d.createTableDDL(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("error creating table: %w", err)
+		}
+
+		c := newRowChecksum()
+		if _, err := d.readTableRows(ctx, name, func(_ []string, values []sql.NullString) error {
+			c.add(rows.formatRow(values))
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("error reading table %s: %w", name, err)
+		}
+
+		checksums = append(checksums, c.checksum(name))
+	}
+
+	return checksums, nil
+}
+
+// ChecksumDump computes the checksums of the rows of every table in a dump written with a default template, and
+// returns them along with the checksums recorded in the dump. The dump is read a statement at a time.
+func ChecksumDump(r io.Reader) (computed []*TableChecksum, recorded []*TableChecksum, err error) {
+	s := &dumpScanner{
+		r:        bufio.NewReader(r),
+		computed: make([]*TableChecksum, 0),
+		recorded: make([]*TableChecksum, 0),
+	}
+
+	for {
+		line, readErr := s.r.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return nil, nil, fmt.Errorf("error reading dump: %w", readErr)
+		}
+
+		if err := s.scan(line); err != nil {
+			return nil, nil, err
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	s.finishTable()
+
+	found := make(map[string]bool, len(s.computed))
+	for _, c := range s.computed {
+		found[c.Name] = true
+	}
+
+	// Tables without rows have no data in the dump.
+	for _, c := range s.recorded {
+		if !found[c.Name] {
+			s.computed = append(s.computed, newRowChecksum().checksum(c.Name))
+		}
+	}
+
+	return s.computed, s.recorded, nil
+}
+
+// dumpScanner reads the rows and the checksum comments of a dump line by line.
+type dumpScanner struct {
+	r *bufio.Reader
+
+	// table is the name of the table whose rows are being read, and rows their checksum. rows is nil between tables.
+	table string
+	rows  *rowChecksum
+
+	computed []*TableChecksum
+	recorded []*TableChecksum
+}
+
+// scan handles a line of the dump. The rows of a table are the INSERT statements that follow its data comment, where
+// LOCK TABLES statements and blank lines are skipped, and they end at the first other line.
+func (s *dumpScanner) scan(line string) error {
+	text := strings.TrimSuffix(line, "\n")
+
+	if s.rows != nil {
+		switch {
+		case text == "", strings.HasPrefix(text, "LOCK TABLES "):
+			return nil
+		case strings.HasPrefix(text, "INSERT INTO "):
+			if err := s.insert(line); err != nil {
+				return fmt.Errorf("error reading rows of table %s: %w", s.table, err)
+			}
+
+			return nil
+		default:
+			s.finishTable()
+		}
+	}
+
+	if m := dataDumpRegex.FindStringSubmatch(text); m != nil {
+		s.table, s.rows = m[1], newRowChecksum()
+		return nil
+	}
+
+	if m := checksumCommentRegex.FindStringSubmatch(text); m != nil {
+		rows, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("error parsing row count of table %s: %w", m[1], err)
+		}
+
+		s.recorded = append(s.recorded, &TableChecksum{
+			Name:     m[1],
+			Rows:     rows,
+			Checksum: m[3],
+		})
+	}
+
+	return nil
+}
+
+// insert adds the rows of the INSERT statement that starts with line to the checksum of the table. Quotes are doubled
+// inside string literals in every dialect, so the statement goes on to the next line while its values hold an odd
+// number of quotes.
+func (s *dumpScanner) insert(line string) error {
+	values := strings.Index(line, " VALUES (")
+	if values < 0 {
+		return errors.New("missing VALUES")
+	}
+
+	stmt := new(strings.Builder)
+	stmt.WriteString(line)
+	quotes := strings.Count(line[values:], "'")
+	for quotes%2 == 1 {
+		next, err := s.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("error reading dump: %w", err)
+		}
+
+		stmt.WriteString(next)
+		quotes += strings.Count(next, "'")
+
+		if err != nil && quotes%2 == 1 {
+			return errors.New("unterminated string literal")
+		}
+	}
+
+	_, err := splitRows(stmt.String(), values+len(" VALUES "), s.rows.add)
+	return err
+}
+
+// finishTable records the checksum of the table whose rows were being read, if any.
+func (s *dumpScanner) finishTable() {
+	if s.rows != nil {
+		s.computed = append(s.computed, s.rows.checksum(s.table))
+		s.rows = nil
+	}
+}
+
+// splitRows calls fn with each row of the value list of an INSERT statement starting at i, and returns the index after
+// the semicolon that ends the statement. Quotes are doubled inside string literals in every dialect, and backslashes
+// only ever escape another backslash, so they do not need to be tracked.
+func splitRows(s string, i int, fn func(row string)) (int, error) {
+	for {
+		if i >= len(s) || s[i] != '(' {
+			return 0, fmt.Errorf("expected a row at offset %d", i)
+		}
+
+		start := i
+		depth := 0
+		inQuote := false
+	row:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case inQuote && c == '\'':
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					inQuote = false
+				}
+			case inQuote:
+			case c == '\'':
+				inQuote = true
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+				if depth == 0 {
+					break row
+				}
+			}
+		}
+
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated row at offset %d", start)
+		}
+
+		fn(s[start : i+1])
+		i++
+
+		if i < len(s) && s[i] == ',' {
+			i++
+			continue
+		}
+
+		if i < len(s) && s[i] == ';' {
+			return i + 1, nil
+		}
+
+		return 0, fmt.Errorf("expected , or ; at offset %d", i)
+	}
+}
+
+// CompareChecksums compares the actual checksums of tables against the expected checksums.
+func CompareChecksums(expected, actual []*TableChecksum) *ChecksumReport {
+	results := make(map[string]*ChecksumResult)
+	result := func(name string) *ChecksumResult {
+		r, ok := results[name]
+		if !ok {
+			r = &ChecksumResult{Name: name}
+			results[name] = r
+		}
+
+		return r
+	}
+
+	for _, c := range expected {
+		result(c.Name).Expected = c
+	}
+
+	for _, c := range actual {
+		result(c.Name).Actual = c
+	}
+
+	report := &ChecksumReport{
+		Tables: make([]*ChecksumResult, 0, len(results)),
+		OK:     true,
+	}
+
+	for _, r := range results {
+		r.Match = r.Expected != nil && r.Actual != nil &&
+			r.Expected.Rows == r.Actual.Rows && r.Expected.Checksum == r.Actual.Checksum
+
+		if !r.Match {
+			report.OK = false
+		}
+
+		report.Tables = append(report.Tables, r)
+	}
+
+	sort.Slice(report.Tables, func(i, j int) bool {
+		return report.Tables[i].Name < report.Tables[j].Name
+	})
+
+	return report
+}
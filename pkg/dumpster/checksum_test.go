@@ -0,0 +1,80 @@
+package dumpster
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumDump(t *testing.T) {
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	d, err := NewDumpster(src, WithBatchSize(1))
+	require.NoError(t, err)
+
+	dump, err := d.Dump(context.Background())
+	require.NoError(t, err)
+	require.Regexp(t, `\n-- Checksum for table users: rows=2 crc64=[0-9a-f]{16}\n`, dump)
+
+	computed, recorded, err := ChecksumDump(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Len(t, recorded, 2)
+	require.True(t, CompareChecksums(recorded, computed).OK)
+
+	// The checksums of the live tables match the dump.
+	live, err := d.GetTableChecksums(context.Background())
+	require.NoError(t, err)
+	require.True(t, CompareChecksums(recorded, live).OK)
+
+	// A changed row is detected.
+	computed, recorded, err = ChecksumDump(strings.NewReader(strings.Replace(dump, "'alice'", "'alicf'", 1)))
+	require.NoError(t, err)
+
+	report := CompareChecksums(recorded, computed)
+	require.False(t, report.OK)
+	require.Equal(t, "users", report.Tables[1].Name)
+	require.False(t, report.Tables[1].Match)
+	require.True(t, report.Tables[0].Match)
+}
+
+func TestChecksumDump_MultilineValue(t *testing.T) {
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	// A value can span lines, and look like a comment of the dump.
+	_, err = src.Exec(`INSERT INTO users (name) VALUES ('it''s' || char(10) || '-- Data dump for table users')`)
+	require.NoError(t, err)
+
+	d, err := NewDumpster(src)
+	require.NoError(t, err)
+
+	dump, err := d.Dump(context.Background())
+	require.NoError(t, err)
+
+	computed, recorded, err := ChecksumDump(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Len(t, computed, 2)
+	require.True(t, CompareChecksums(recorded, computed).OK)
+}
+
+func TestSplitRows(t *testing.T) {
+	// MySQL escapes backslashes, and every dialect doubles quotes.
+	s := `INSERT INTO t VALUES ('a\\',NULL),('it''s (1)',0x00),('\x00ff');`
+	rows := make([]string, 0)
+
+	end, err := splitRows(s, strings.Index(s, "("), func(row string) {
+		rows = append(rows, row)
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(s), end)
+	require.Equal(t, []string{`('a\\',NULL)`, `('it''s (1)',0x00)`, `('\x00ff')`}, rows)
+
+	_, err = splitRows(`('a'`, 0, func(string) {})
+	require.Error(t, err)
+}
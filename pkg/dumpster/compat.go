@@ -0,0 +1,171 @@
+package dumpster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+// Compat is the server a dump is made compatible with.
+type Compat string
+
+const (
+	// CompatNone leaves the output as the server returns it.
+	CompatNone Compat = ""
+
+	// CompatMariaDB rewrites the output for MariaDB 10.x.
+	CompatMariaDB Compat = "mariadb"
+
+	// CompatMySQL57 rewrites the output for MySQL 5.7.
+	CompatMySQL57 Compat = "mysql57"
+)
+
+var (
+	// collation0900Regex matches the MySQL 8 UCA 9.0.0 collations, e.g. utf8mb4_0900_ai_ci or utf8mb4_de_pb_0900_as_cs.
+	collation0900Regex = regexp.MustCompile(`\butf8mb4_(?:\w+_)?0900_(\w+)\b`)
+
+	// utf8mb3Regex matches the utf8mb3 character set and its collations, which older servers only know as utf8.
+	utf8mb3Regex = regexp.MustCompile(`\butf8mb3(_\w+)?\b`)
+
+	// invisibleColumnRegex matches the version comment MySQL 8 writes for an invisible column.
+	invisibleColumnRegex = regexp.MustCompile(`\s*/\*!80023 INVISIBLE \*/`)
+
+	// invisibleIndexRegex matches the version comment MySQL 8 writes for an invisible index.
+	invisibleIndexRegex = regexp.MustCompile(`\s*/\*!80000 INVISIBLE \*/`)
+
+	// sridRegex matches the version comment MySQL 8 writes for a spatial column restricted to an SRID.
+	sridRegex = regexp.MustCompile(`\s*/\*!80003 SRID \d+ \*/`)
+
+	// indexLineRegex matches a line of SHOW CREATE TABLE output that defines an index.
+	indexLineRegex = regexp.MustCompile(`^\s*(?:PRIMARY |UNIQUE |FULLTEXT |SPATIAL )?KEY\b`)
+
+	// checkLineRegex matches a line of SHOW CREATE TABLE output that defines a check constraint.
+	checkLineRegex = regexp.MustCompile(`^\s*CONSTRAINT \S+ CHECK \(`)
+)
+
+// ParseCompat returns the Compat for the name, which is empty, mariadb or mysql57.
+func ParseCompat(name string) (Compat, error) {
+	switch c := Compat(strings.ToLower(name)); c {
+	case CompatNone, CompatMariaDB, CompatMySQL57:
+		return c, nil
+	default:
+		return CompatNone, fmt.Errorf("unknown compatibility mode %q, must be one of mariadb, mysql57", name)
+	}
+}
+
+// compatCollation returns the collation or character set the target server accepts in place of the given one.
+func compatCollation(name string) string {
+	name = collation0900Regex.ReplaceAllStringFunc(name, func(m string) string {
+		switch collation0900Regex.FindStringSubmatch(m)[1] {
+		case "bin", "as_cs":
+			return "utf8mb4_bin"
+		default:
+			return "utf8mb4_unicode_520_ci"
+		}
+	})
+
+	return utf8mb3Regex.ReplaceAllString(name, "utf8$1")
+}
+
+// compatTableSQL rewrites the output of SHOW CREATE TABLE for the target server. It returns the rewritten statement
+// and a description of every construct that could not be translated, or was translated with a loss of behaviour.
+func compatTableSQL(createSQL string, compat Compat) (string, []string) {
+	if compat == CompatNone {
+		return createSQL, nil
+	}
+
+	warnings := collationWarnings(createSQL)
+	lines := strings.Split(compatCollation(createSQL), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "`"):
+			lines[i] = compatColumn(line, compat, &warnings)
+		case indexLineRegex.MatchString(line):
+			if invisibleIndexRegex.MatchString(line) {
+				line = invisibleIndexRegex.ReplaceAllString(line, "")
+				warnings = append(warnings, "invisible index made visible: "+strings.TrimSuffix(strings.TrimSpace(line), ","))
+			}
+			if strings.Contains(line, "((") {
+				warnings = append(warnings, "functional index cannot be translated: "+strings.TrimSuffix(strings.TrimSpace(line), ","))
+			}
+			lines[i] = line
+		case compat == CompatMySQL57 && checkLineRegex.MatchString(line):
+			warnings = append(warnings, "check constraint is not enforced: "+strings.TrimSuffix(trimmed, ","))
+		}
+	}
+
+	return strings.Join(lines, "\n"), warnings
+}
+
+// compatObjectSQL rewrites the collations and character sets of a CREATE VIEW, TRIGGER, PROCEDURE or FUNCTION
+// statement for the target server, such as those of routine arguments or COLLATE clauses in the body. The rest of the
+// statement is left as it is: definers are kept, as both servers accept them, and syntax the target server does not
+// support is not detected.
+func compatObjectSQL(createSQL string, compat Compat) (string, []string) {
+	if compat == CompatNone {
+		return createSQL, nil
+	}
+
+	return compatCollation(createSQL), collationWarnings(createSQL)
+}
+
+// collationWarnings returns a warning for every collation in the statement whose sort order changes when it is
+// replaced.
+func collationWarnings(createSQL string) []string {
+	warnings := make([]string, 0)
+	for _, m := range collation0900Regex.FindAllString(createSQL, -1) {
+		if !strings.HasSuffix(m, "_0900_ai_ci") && !strings.HasSuffix(m, "_0900_bin") {
+			warnings = append(warnings, fmt.Sprintf("collation %s replaced with %s, sort order may differ", m, compatCollation(m)))
+		}
+	}
+
+	return warnings
+}
+
+// compatColumn rewrites a column definition line of SHOW CREATE TABLE output for the target server.
+func compatColumn(line string, compat Compat, warnings *[]string) string {
+	if sridRegex.MatchString(line) {
+		line = sridRegex.ReplaceAllString(line, "")
+		*warnings = append(*warnings, "SRID restriction removed: "+columnName(line))
+	}
+
+	if invisibleColumnRegex.MatchString(line) {
+		if compat == CompatMariaDB {
+			line = invisibleColumnRegex.ReplaceAllString(line, " INVISIBLE")
+		} else {
+			line = invisibleColumnRegex.ReplaceAllString(line, "")
+			*warnings = append(*warnings, "invisible column made visible: "+columnName(line))
+		}
+	}
+
+	// MariaDB supports expression defaults, MySQL 5.7 does not.
+	if compat == CompatMySQL57 {
+		if start := sqlparse.IndexOutsideQuotes(line, " DEFAULT ("); start >= 0 {
+			end := parenEnd(line, start+len(" DEFAULT "))
+			*warnings = append(*warnings, "expression default removed: "+columnName(line)+line[start:end])
+			line = line[:start] + line[end:]
+		}
+	}
+
+	return line
+}
+
+// columnName returns the quoted name of the column defined on the line.
+func columnName(line string) string {
+	line = strings.TrimSpace(line)
+	if end := sqlparse.IndexOutsideQuotes(line[1:], " "); end >= 0 {
+		return line[:end+1]
+	}
+	return line
+}
+
+// parenEnd returns the index after the parenthesis that closes the one at i, or the end of s if it is not closed.
+func parenEnd(s string, i int) int {
+	if closing := sqlparse.MatchParen(s, i); closing >= 0 {
+		return closing + 1
+	}
+	return len(s)
+}
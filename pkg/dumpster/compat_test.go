@@ -0,0 +1,125 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const compatTable = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(255) COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT 'a (b)',\n" +
+	"  `tags` json DEFAULT (json_array()),\n" +
+	"  `secret` varchar(32) DEFAULT NULL /*!80023 INVISIBLE */,\n" +
+	"  `location` point NOT NULL /*!80003 SRID 4326 */,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_name` (`name`) /*!80000 INVISIBLE */,\n" +
+	"  KEY `idx_lower` ((lower(`name`))),\n" +
+	"  CONSTRAINT `chk_name` CHECK ((`name` <> _utf8mb4''))\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_as_cs"
+
+func TestCompatTableSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		compat       Compat
+		want         string
+		wantWarnings int
+	}{
+		{
+			name:   "none",
+			compat: CompatNone,
+			want:   compatTable,
+		},
+		{
+			name:   "mariadb",
+			compat: CompatMariaDB,
+			want: "CREATE TABLE `users` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(255) COLLATE utf8mb4_unicode_520_ci NOT NULL DEFAULT 'a (b)',\n" +
+				"  `tags` json DEFAULT (json_array()),\n" +
+				"  `secret` varchar(32) DEFAULT NULL INVISIBLE,\n" +
+				"  `location` point NOT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  KEY `idx_name` (`name`),\n" +
+				"  KEY `idx_lower` ((lower(`name`))),\n" +
+				"  CONSTRAINT `chk_name` CHECK ((`name` <> _utf8mb4''))\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+			// Collation, SRID, invisible index and functional index.
+			wantWarnings: 4,
+		},
+		{
+			name:   "mysql57",
+			compat: CompatMySQL57,
+			want: "CREATE TABLE `users` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(255) COLLATE utf8mb4_unicode_520_ci NOT NULL DEFAULT 'a (b)',\n" +
+				"  `tags` json,\n" +
+				"  `secret` varchar(32) DEFAULT NULL,\n" +
+				"  `location` point NOT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  KEY `idx_name` (`name`),\n" +
+				"  KEY `idx_lower` ((lower(`name`))),\n" +
+				"  CONSTRAINT `chk_name` CHECK ((`name` <> _utf8mb4''))\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+			// As mariadb, plus the expression default, invisible column and check constraint.
+			wantWarnings: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := compatTableSQL(compatTable, tt.compat)
+			require.Equal(t, tt.want, got)
+			require.Len(t, warnings, tt.wantWarnings)
+		})
+	}
+}
+
+func TestCompatCollation(t *testing.T) {
+	require.Equal(t, "utf8mb4_unicode_520_ci", compatCollation("utf8mb4_0900_ai_ci"))
+	require.Equal(t, "utf8mb4_bin", compatCollation("utf8mb4_0900_bin"))
+	require.Equal(t, "utf8mb4_unicode_520_ci", compatCollation("utf8mb4_de_pb_0900_ai_ci"))
+	require.Equal(t, "utf8_general_ci", compatCollation("utf8mb3_general_ci"))
+	require.Equal(t, "utf8", compatCollation("utf8mb3"))
+	require.Equal(t, "latin1_swedish_ci", compatCollation("latin1_swedish_ci"))
+}
+
+func TestCompatObjectSQL(t *testing.T) {
+	routine := "CREATE DEFINER=`app`@`%` PROCEDURE `rename`(IN `name` varchar(64) CHARSET utf8mb4 COLLATE utf8mb4_0900_as_cs)\n" +
+		"BEGIN\n" +
+		"  SELECT * FROM `users` WHERE `users`.`name` = `name` COLLATE utf8mb4_0900_ai_ci;\n" +
+		"END"
+
+	got, warnings := compatObjectSQL(routine, CompatNone)
+	require.Equal(t, routine, got)
+	require.Empty(t, warnings)
+
+	got, warnings = compatObjectSQL(routine, CompatMySQL57)
+	require.Equal(t, "CREATE DEFINER=`app`@`%` PROCEDURE `rename`(IN `name` varchar(64) CHARSET utf8mb4 COLLATE utf8mb4_bin)\n"+
+		"BEGIN\n"+
+		"  SELECT * FROM `users` WHERE `users`.`name` = `name` COLLATE utf8mb4_unicode_520_ci;\n"+
+		"END", got)
+	// Only the case sensitive collation changes the sort order.
+	require.Len(t, warnings, 1)
+
+	view := "CREATE ALGORITHM=UNDEFINED DEFINER=`app`@`%` SQL SECURITY DEFINER VIEW `names` AS " +
+		"select convert(`users`.`name` using utf8mb3) AS `name` from `users`"
+
+	got, warnings = compatObjectSQL(view, CompatMariaDB)
+	require.Equal(t, "CREATE ALGORITHM=UNDEFINED DEFINER=`app`@`%` SQL SECURITY DEFINER VIEW `names` AS "+
+		"select convert(`users`.`name` using utf8) AS `name` from `users`", got)
+	require.Empty(t, warnings)
+}
+
+func TestParseCompat(t *testing.T) {
+	c, err := ParseCompat("MariaDB")
+	require.NoError(t, err)
+	require.Equal(t, CompatMariaDB, c)
+
+	c, err = ParseCompat("")
+	require.NoError(t, err)
+	require.Equal(t, CompatNone, c)
+
+	_, err = ParseCompat("oracle")
+	require.Error(t, err)
+}
@@ -0,0 +1,684 @@
+package dumpster
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+var (
+	// typeArgsRegex matches a MySQL data type with its optional arguments, e.g. decimal(10,2).
+	typeArgsRegex = regexp.MustCompile(`^([a-z]+)(?:\((.*)\))?$`)
+
+	// introducerRegex matches a character set introducer before a string literal, e.g. _utf8mb4'a'.
+	introducerRegex = regexp.MustCompile(`\b_[a-z0-9]+'`)
+
+	// indexColumnRegex matches a column of an index with its optional prefix length and direction.
+	indexColumnRegex = regexp.MustCompile("(?i)^(`(?:[^`]|``)+`)(?:\\s*\\(\\d+\\))?(\\s+(?:ASC|DESC))?$")
+
+	// viewHeaderRegex matches the part of a MySQL CREATE VIEW statement before the view name.
+	viewHeaderRegex = regexp.MustCompile(`(?is)^CREATE\s+.*?\bVIEW\s+`)
+
+	// autoIncrementValueRegex matches the AUTO_INCREMENT table option, capturing the next value.
+	autoIncrementValueRegex = regexp.MustCompile(`(?i)\bAUTO_INCREMENT=(\d+)`)
+
+	// tableCommentRegex matches the COMMENT table option.
+	tableCommentRegex = regexp.MustCompile(`(?i)\bCOMMENT='((?:[^'\\]|''|\\.)*)'`)
+
+	// zeroDateRegex matches a MySQL date with a zero year, month or day, which PostgreSQL does not accept.
+	zeroDateRegex = regexp.MustCompile(`^(?:0000-\d\d-\d\d|\d{4}-00-\d\d|\d{4}-\d\d-00)`)
+)
+
+// valueKind is how the text form of a MySQL value is written for PostgreSQL.
+type valueKind int
+
+const (
+	// valueText is written as a string literal.
+	valueText valueKind = iota
+
+	// valueBool is written as true or false.
+	valueBool
+
+	// valueBytes is written as a bytea hex literal.
+	valueBytes
+
+	// valueBit is a BIT value, written as a number or, for BIT(1), as true or false.
+	valueBit
+
+	// valueTime is a date or time, where MySQL zero dates are written as NULL, or as -infinity in NOT NULL columns.
+	valueTime
+)
+
+// pgTargetColumn is a MySQL column converted to PostgreSQL.
+type pgTargetColumn struct {
+	// name is the name of the column.
+	name string
+
+	// kind is how the values of the column are written.
+	kind valueKind
+
+	// boolean is whether a BIT column was converted to boolean.
+	boolean bool
+
+	// generated is whether the column is generated, and so is not inserted.
+	generated bool
+
+	// notNull is whether the column is NOT NULL.
+	notNull bool
+
+	// zeroDates is whether a zero date of the column has been written.
+	zeroDates bool
+}
+
+// postgresTable is a MySQL table converted to PostgreSQL. It writes the rows of the table as PostgreSQL literals.
+type postgresTable struct {
+	// name is the name of the table.
+	name string
+
+	// columns are the columns of the table, in the order MySQL returns them.
+	columns []*pgTargetColumn
+
+	// createSQL is the CREATE TABLE statement.
+	createSQL string
+
+	// constraintsSQL are the statements to run once every table is loaded, separated by semicolons.
+	constraintsSQL string
+
+	// warnings describe the parts of the table that could not be converted exactly.
+	warnings []string
+}
+
+// rowFormatter writes the rows of a table as the value list of an INSERT statement.
+type rowFormatter interface {
+	// insertColumns returns the columns the values are written for, given the columns that were read.
+	insertColumns(columns []string) []string
+
+	// formatRow returns the values of a row as a parenthesised list.
+	formatRow(values []sql.NullString) string
+}
+
+// dialectRows writes rows with the value quoting of a dialect.
+type dialectRows struct {
+	dialect Dialect
+}
+
+func (r *dialectRows) insertColumns(columns []string) []string {
+	return columns
+}
+
+func (r *dialectRows) formatRow(values []sql.NullString) string {
+	dataStrings := make([]string, len(values))
+	for i, value := range values {
+		dataStrings[i] = r.dialect.QuoteValue(value)
+	}
+
+	return "(" + strings.Join(dataStrings, ",") + ")"
+}
+
+// outputDialect returns the dialect the output is written in.
+func (d *Dumpster) outputDialect() Dialect {
+	if d.target == DialectPostgres {
+		return NewPostgresDialect(nil)
+	}
+
+	return d.dialect
+}
+
+// logConversionWarnings logs the parts of an object that could not be converted exactly.
+func (d *Dumpster) logConversionWarnings(kind, name string, warnings []string) {
+	for _, w := range warnings {
+		slog.Warn("Definition is not fully converted to the target dialect",
+			slog.String("target", d.target),
+			slog.String(kind, name),
+			slog.String("detail", w),
+		)
+	}
+}
+
+// convertTableToPostgres converts a MySQL CREATE TABLE statement to PostgreSQL. Primary keys, unique keys and checks
+// are part of the table. Indexes, foreign keys, comments and the identity counter are set once the data is loaded.
+func convertTableToPostgres(createSQL string) (*postgresTable, error) {
+	parsed, err := sqlparse.ParseTable(createSQL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing table: %w", err)
+	}
+
+	t := &postgresTable{
+		name:     parsed.Name,
+		columns:  make([]*pgTargetColumn, 0, len(parsed.Columns)),
+		warnings: make([]string, 0),
+	}
+
+	p := NewPostgresDialect(nil)
+	table := p.QuoteIdentifier(parsed.Name)
+	definitions := make([]string, 0, len(parsed.Columns)+len(parsed.Indexes)+len(parsed.Checks))
+	statements := make([]string, 0)
+	identity := ""
+
+	for _, c := range parsed.Columns {
+		def, col, stmts := t.convertColumn(c, table)
+		definitions = append(definitions, def)
+		statements = append(statements, stmts...)
+		t.columns = append(t.columns, col)
+
+		if strings.Contains(def, " AS IDENTITY") {
+			identity = c.Name
+		}
+	}
+
+	for _, idx := range parsed.Indexes {
+		columns, ok := t.indexColumns(idx)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(idx.Kind) {
+		case "PRIMARY":
+			definitions = append(definitions, "PRIMARY KEY ("+columns+")")
+		case "UNIQUE":
+			// Unique keys are part of the table so that foreign keys of other tables can reference them.
+			definitions = append(definitions, "CONSTRAINT "+p.QuoteIdentifier(parsed.Name+"_"+idx.Name)+" UNIQUE ("+columns+")")
+		case "INDEX":
+			// Index names are unique per schema in PostgreSQL, so they are prefixed with the table name.
+			statements = append(statements, "CREATE INDEX "+p.QuoteIdentifier(parsed.Name+"_"+idx.Name)+" ON "+table+" ("+columns+")")
+		default:
+			t.warnings = append(t.warnings, fmt.Sprintf("%s index %s is not supported and was skipped", idx.Kind, idx.Name))
+		}
+	}
+
+	for _, c := range parsed.Checks {
+		definitions = append(definitions, postgresExpression(c.Definition))
+	}
+
+	for _, fk := range parsed.ForeignKeys {
+		statements = append(statements, "ALTER TABLE "+table+" ADD "+postgresExpression(fk.Definition))
+	}
+
+	if m := tableCommentRegex.FindStringSubmatch(parsed.Options); m != nil {
+		statements = append(statements, "COMMENT ON TABLE "+table+" IS "+p.QuoteValue(sql.NullString{String: unescapeMySQL(m[1]), Valid: true}))
+	}
+
+	// AUTO_INCREMENT is the next value MySQL would assign.
+	if m := autoIncrementValueRegex.FindStringSubmatch(parsed.Options); m != nil && identity != "" {
+		statements = append(statements, fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), %s, false)",
+			p.QuoteValue(sql.NullString{String: table, Valid: true}),
+			p.QuoteValue(sql.NullString{String: identity, Valid: true}),
+			m[1]))
+	}
+
+	t.createSQL = fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", table, strings.Join(definitions, ",\n  "))
+	t.constraintsSQL = strings.Join(statements, ";\n")
+
+	return t, nil
+}
+
+// convertColumn converts a MySQL column definition. It returns the PostgreSQL definition, how the values of the
+// column are written, and the statements to run after the table is loaded.
+func (t *postgresTable) convertColumn(c *sqlparse.Column, table string) (string, *pgTargetColumn, []string) {
+	p := NewPostgresDialect(nil)
+	col := &pgTargetColumn{
+		name: c.Name,
+	}
+
+	attrs := strings.TrimSpace(c.Definition[len(c.Type):])
+	unsigned := false
+	notNull := false
+	autoIncrement := false
+	defaultValue := ""
+	hasDefault := false
+	generated := ""
+	comment := ""
+
+	for i := 0; i < len(attrs); {
+		rest := attrs[i:]
+		upper := strings.ToUpper(rest)
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n':
+			i++
+			continue
+		case strings.HasPrefix(upper, "UNSIGNED"):
+			unsigned = true
+			i += len("UNSIGNED")
+		case strings.HasPrefix(upper, "ZEROFILL"):
+			i += len("ZEROFILL")
+		case strings.HasPrefix(upper, "NOT NULL"):
+			notNull = true
+			i += len("NOT NULL")
+		case strings.HasPrefix(upper, "NULL"):
+			i += len("NULL")
+		case strings.HasPrefix(upper, "AUTO_INCREMENT"):
+			autoIncrement = true
+			i += len("AUTO_INCREMENT")
+		case strings.HasPrefix(upper, "CHARACTER SET "):
+			i += len("CHARACTER SET ")
+			i += attrValueEnd(attrs[i:])
+		case strings.HasPrefix(upper, "COLLATE "):
+			i += len("COLLATE ")
+			i += attrValueEnd(attrs[i:])
+		case strings.HasPrefix(upper, "DEFAULT "):
+			start := i + len("DEFAULT ")
+			end := start + attrValueEnd(attrs[start:])
+			defaultValue = attrs[start:end]
+			hasDefault = true
+			i = end
+		case strings.HasPrefix(upper, "ON UPDATE "):
+			start := i + len("ON UPDATE ")
+			end := start + attrValueEnd(attrs[start:])
+			t.warnings = append(t.warnings, fmt.Sprintf("ON UPDATE %s of column %s was dropped", attrs[start:end], c.Name))
+			i = end
+		case strings.HasPrefix(upper, "COMMENT "):
+			start := i + len("COMMENT ")
+			end := start + attrValueEnd(attrs[start:])
+			comment = attrs[start:end]
+			i = end
+		case strings.HasPrefix(upper, "GENERATED ALWAYS AS "), strings.HasPrefix(upper, "AS "):
+			open := i + strings.IndexByte(rest, '(')
+			end := parenEnd(attrs, open)
+			generated = attrs[open:end]
+			i = end
+		case strings.HasPrefix(upper, "VIRTUAL"), strings.HasPrefix(upper, "STORED"):
+			i += attrValueEnd(rest)
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				end = len(rest) - 2
+			}
+			t.warnings = append(t.warnings, fmt.Sprintf("attribute %s of column %s was dropped", rest[:end+2], c.Name))
+			i += end + 2
+		default:
+			end := attrValueEnd(rest)
+			t.warnings = append(t.warnings, fmt.Sprintf("attribute %s of column %s was dropped", rest[:end], c.Name))
+			i += end
+		}
+	}
+
+	col.notNull = notNull
+	pgType, check := t.convertType(c, col, unsigned)
+	def := p.QuoteIdentifier(c.Name) + " " + pgType
+
+	switch {
+	case generated != "":
+		// PostgreSQL only has stored generated columns, so virtual columns are stored.
+		col.generated = true
+		def += " GENERATED ALWAYS AS " + postgresExpression(generated) + " STORED"
+	case autoIncrement:
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	case hasDefault:
+		if value, ok := t.convertDefault(c.Name, defaultValue, col); ok {
+			def += " DEFAULT " + value
+		}
+	}
+
+	if notNull {
+		def += " NOT NULL"
+	}
+
+	if check != "" {
+		def += " CHECK (" + check + ")"
+	}
+
+	statements := make([]string, 0)
+	if comment != "" {
+		statements = append(statements, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", table, p.QuoteIdentifier(c.Name),
+			p.QuoteValue(sql.NullString{String: unquoteMySQL(comment), Valid: true})))
+	}
+
+	return def, col, statements
+}
+
+// convertType returns the PostgreSQL type for the MySQL type of the column, and a check expression that restricts the
+// values of the column, for ENUM.
+func (t *postgresTable) convertType(c *sqlparse.Column, col *pgTargetColumn, unsigned bool) (string, string) {
+	m := typeArgsRegex.FindStringSubmatch(strings.ToLower(c.Type))
+	if m == nil {
+		t.warnings = append(t.warnings, fmt.Sprintf("type %s of column %s is not known and was written as text", c.Type, c.Name))
+		return "text", ""
+	}
+
+	name, args := m[1], m[2]
+	switch name {
+	case "tinyint":
+		if args == "1" && !unsigned {
+			col.kind = valueBool
+			return "boolean", ""
+		}
+		return "smallint", ""
+	case "smallint":
+		if unsigned {
+			return "integer", ""
+		}
+		return "smallint", ""
+	case "mediumint":
+		return "integer", ""
+	case "int", "integer":
+		if unsigned {
+			return "bigint", ""
+		}
+		return "integer", ""
+	case "bigint":
+		if unsigned {
+			return "numeric(20)", ""
+		}
+		return "bigint", ""
+	case "decimal", "numeric":
+		if args != "" {
+			return "numeric(" + args + ")", ""
+		}
+		return "numeric", ""
+	case "float":
+		return "real", ""
+	case "double", "real":
+		return "double precision", ""
+	case "bit":
+		col.kind = valueBit
+		if args == "" || args == "1" {
+			col.boolean = true
+			return "boolean", ""
+		}
+		return "bigint", ""
+	case "char", "varchar":
+		return name + "(" + args + ")", ""
+	case "tinytext", "text", "mediumtext", "longtext":
+		return "text", ""
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		col.kind = valueBytes
+		return "bytea", ""
+	case "date":
+		col.kind = valueTime
+		return "date", ""
+	case "time":
+		// MySQL times are durations, which can be negative or exceed 24 hours.
+		t.warnings = append(t.warnings, fmt.Sprintf("TIME column %s was written as interval", c.Name))
+		return "interval" + precision(args), ""
+	case "datetime":
+		col.kind = valueTime
+		return "timestamp" + precision(args), ""
+	case "timestamp":
+		// MySQL returns TIMESTAMP values in the time zone of the session, which the dump sets to UTC.
+		col.kind = valueTime
+		return "timestamptz" + precision(args), ""
+	case "year":
+		return "smallint", ""
+	case "json":
+		return "jsonb", ""
+	case "enum":
+		values := sqlparse.SplitList(args)
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = NewPostgresDialect(nil).QuoteValue(sql.NullString{String: unquoteMySQL(v), Valid: true})
+		}
+		return "text", NewPostgresDialect(nil).QuoteIdentifier(c.Name) + " IN (" + strings.Join(quoted, ", ") + ")"
+	case "set":
+		t.warnings = append(t.warnings, fmt.Sprintf("SET column %s was written as text without a check", c.Name))
+		return "text", ""
+	default:
+		t.warnings = append(t.warnings, fmt.Sprintf("type %s of column %s is not known and was written as text", c.Type, c.Name))
+		return "text", ""
+	}
+}
+
+// convertDefault returns the PostgreSQL default for the MySQL default of a column. It returns false if the default
+// is dropped.
+func (t *postgresTable) convertDefault(column, value string, col *pgTargetColumn) (string, bool) {
+	upper := strings.ToUpper(value)
+	switch {
+	case upper == "NULL":
+		return "", false
+	case strings.HasPrefix(upper, "CURRENT_TIMESTAMP"), strings.HasPrefix(upper, "NOW("), strings.HasPrefix(upper, "LOCALTIMESTAMP"):
+		return "CURRENT_TIMESTAMP", true
+	case strings.HasPrefix(value, "("):
+		t.warnings = append(t.warnings, fmt.Sprintf("expression default %s of column %s was dropped", value, column))
+		return "", false
+	case strings.HasPrefix(upper, "B'"):
+		n, ok := new(big.Int).SetString(strings.Trim(value[1:], "'"), 2)
+		if !ok {
+			return "", false
+		}
+		return t.formatNumber(n, col), true
+	}
+
+	s := value
+	if strings.HasPrefix(s, "'") {
+		s = unquoteMySQL(s)
+	}
+
+	switch col.kind {
+	case valueBool:
+		return strconv.FormatBool(s != "0"), true
+	case valueTime:
+		if zeroDateRegex.MatchString(s) {
+			if col.notNull {
+				t.warnings = append(t.warnings, fmt.Sprintf("zero date default of column %s was written as -infinity", column))
+				return "'-infinity'", true
+			}
+
+			t.warnings = append(t.warnings, fmt.Sprintf("zero date default of column %s was dropped", column))
+			return "", false
+		}
+	case valueBytes:
+		return "'\\x" + hex.EncodeToString([]byte(s)) + "'", true
+	}
+
+	return NewPostgresDialect(nil).QuoteValue(sql.NullString{String: s, Valid: true}), true
+}
+
+// indexColumns returns the columns of the index quoted for PostgreSQL. Prefix lengths are dropped. It returns false
+// if the index has expressions, which are not converted.
+func (t *postgresTable) indexColumns(idx *sqlparse.Index) (string, bool) {
+	columns := make([]string, 0, len(idx.Columns))
+	for _, c := range idx.Columns {
+		m := indexColumnRegex.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			t.warnings = append(t.warnings, fmt.Sprintf("functional index %s is not supported and was skipped", idx.Name))
+			return "", false
+		}
+
+		columns = append(columns, postgresExpression(m[1])+m[2])
+	}
+
+	return strings.Join(columns, ", "), true
+}
+
+func (t *postgresTable) insertColumns([]string) []string {
+	names := make([]string, 0, len(t.columns))
+	for _, c := range t.columns {
+		if !c.generated {
+			names = append(names, c.name)
+		}
+	}
+
+	return names
+}
+
+func (t *postgresTable) formatRow(values []sql.NullString) string {
+	p := NewPostgresDialect(nil)
+	dataStrings := make([]string, 0, len(values))
+	for i, value := range values {
+		c := t.columns[i]
+		if c.generated {
+			continue
+		}
+
+		if !value.Valid {
+			dataStrings = append(dataStrings, "NULL")
+			continue
+		}
+
+		switch c.kind {
+		case valueBool:
+			dataStrings = append(dataStrings, strconv.FormatBool(value.String != "0"))
+		case valueBit:
+			dataStrings = append(dataStrings, t.formatNumber(new(big.Int).SetBytes([]byte(value.String)), c))
+		case valueBytes:
+			dataStrings = append(dataStrings, "'\\x"+hex.EncodeToString([]byte(value.String))+"'")
+		case valueTime:
+			if zeroDateRegex.MatchString(value.String) {
+				c.zeroDates = true
+				dataStrings = append(dataStrings, zeroDateValue(c))
+				continue
+			}
+			dataStrings = append(dataStrings, p.QuoteValue(value))
+		default:
+			dataStrings = append(dataStrings, p.QuoteValue(value))
+		}
+	}
+
+	return "(" + strings.Join(dataStrings, ",") + ")"
+}
+
+// zeroDateValue returns the value a MySQL zero date is written as: NULL, or -infinity if the column is NOT NULL.
+func zeroDateValue(c *pgTargetColumn) string {
+	if c.notNull {
+		return "'-infinity'"
+	}
+
+	return "NULL"
+}
+
+// zeroDateWarnings returns a warning for each column that zero dates have been written for.
+func (t *postgresTable) zeroDateWarnings() []string {
+	warnings := make([]string, 0)
+	for _, c := range t.columns {
+		if c.zeroDates {
+			warnings = append(warnings, fmt.Sprintf("zero dates of column %s were written as %s", c.name, zeroDateValue(c)))
+		}
+	}
+
+	return warnings
+}
+
+// formatNumber writes the value of a BIT column.
+func (t *postgresTable) formatNumber(n *big.Int, col *pgTargetColumn) string {
+	if col.boolean || col.kind == valueBool {
+		return strconv.FormatBool(n.Sign() != 0)
+	}
+
+	return n.String()
+}
+
+// convertViewToPostgres converts a MySQL CREATE VIEW statement to PostgreSQL. The algorithm, definer and security
+// are dropped, and identifiers are quoted for PostgreSQL. The query itself is not translated.
+func convertViewToPostgres(createSQL string) string {
+	body := viewHeaderRegex.ReplaceAllString(strings.TrimSpace(createSQL), "")
+	return "CREATE OR REPLACE VIEW " + postgresExpression(body)
+}
+
+// postgresExpression quotes the identifiers of a MySQL expression with double quotes and removes character set
+// introducers from string literals.
+func postgresExpression(s string) string {
+	s = introducerRegex.ReplaceAllStringFunc(s, func(m string) string {
+		return "'"
+	})
+
+	b := new(strings.Builder)
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\'':
+			end := sqlparse.QuoteEnd(s, i)
+			b.WriteString(s[i:end])
+			i = end
+		case '`':
+			end := sqlparse.QuoteEnd(s, i)
+			b.WriteString(NewPostgresDialect(nil).QuoteIdentifier(sqlparse.Unquote(s[i:end])))
+			i = end
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// unquoteMySQL returns the value of a MySQL string literal.
+func unquoteMySQL(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = s[1 : len(s)-1]
+	}
+
+	return unescapeMySQL(s)
+}
+
+// unescapeMySQL removes the escaping from the content of a MySQL string literal.
+func unescapeMySQL(s string) string {
+	if !strings.ContainsAny(s, `\'`) {
+		return s
+	}
+
+	b := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case '0':
+				b.WriteByte(0)
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'Z':
+				b.WriteByte(26)
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// precision returns the fractional seconds precision of a time type, e.g. (3).
+func precision(args string) string {
+	if args == "" {
+		return ""
+	}
+
+	return "(" + args + ")"
+}
+
+// attrValueEnd returns the end of the value at the start of a column attribute list: a string literal, a
+// parenthesised expression, or a word with optional arguments such as CURRENT_TIMESTAMP(3).
+func attrValueEnd(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	switch s[0] {
+	case '\'', '"':
+		return sqlparse.QuoteEnd(s, 0)
+	case '(':
+		return parenEnd(s, 0)
+	}
+
+	if (s[0] == 'b' || s[0] == 'B' || s[0] == 'x' || s[0] == 'X') && len(s) > 1 && s[1] == '\'' {
+		return sqlparse.QuoteEnd(s, 1)
+	}
+
+	end := 0
+	for end < len(s) && s[end] != ' ' && s[end] != '(' {
+		end++
+	}
+
+	if end < len(s) && s[end] == '(' {
+		return parenEnd(s, end)
+	}
+
+	return end
+}
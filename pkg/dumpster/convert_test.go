@@ -0,0 +1,129 @@
+package dumpster
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const convertTable = "CREATE TABLE `orders` (\n" +
+	"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` bigint NOT NULL,\n" +
+	"  `paid` tinyint(1) NOT NULL DEFAULT '0',\n" +
+	"  `status` enum('new','it''s done') COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT 'new',\n" +
+	"  `total` decimal(10,2) DEFAULT NULL COMMENT 'In cents',\n" +
+	"  `flags` bit(8) DEFAULT b'101',\n" +
+	"  `payload` blob,\n" +
+	"  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),\n" +
+	"  `updated_at` timestamp NULL DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  `total_x2` decimal(11,2) GENERATED ALWAYS AS ((`total` * 2)) VIRTUAL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uq_user` (`user_id`,`created_at`),\n" +
+	"  KEY `idx_status` (`status`(4) DESC),\n" +
+	"  FULLTEXT KEY `ft_status` (`status`),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,\n" +
+	"  CONSTRAINT `chk_total` CHECK ((`total` >= 0))\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4 COMMENT='Customer orders'"
+
+func TestConvertTableToPostgres(t *testing.T) {
+	got, err := convertTableToPostgres(convertTable)
+	require.NoError(t, err)
+
+	require.Equal(t, "CREATE TABLE \"orders\" (\n"+
+		"  \"id\" bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,\n"+
+		"  \"user_id\" bigint NOT NULL,\n"+
+		"  \"paid\" boolean DEFAULT false NOT NULL,\n"+
+		"  \"status\" text DEFAULT 'new' NOT NULL CHECK (\"status\" IN ('new', 'it''s done')),\n"+
+		"  \"total\" numeric(10,2),\n"+
+		"  \"flags\" bigint DEFAULT 5,\n"+
+		"  \"payload\" bytea,\n"+
+		"  \"created_at\" timestamp(3) DEFAULT CURRENT_TIMESTAMP NOT NULL,\n"+
+		"  \"updated_at\" timestamptz,\n"+
+		"  \"total_x2\" numeric(11,2) GENERATED ALWAYS AS ((\"total\" * 2)) STORED,\n"+
+		"  PRIMARY KEY (\"id\"),\n"+
+		"  CONSTRAINT \"orders_uq_user\" UNIQUE (\"user_id\", \"created_at\"),\n"+
+		"  CONSTRAINT \"chk_total\" CHECK ((\"total\" >= 0))\n"+
+		")", got.createSQL)
+
+	require.Equal(t, "COMMENT ON COLUMN \"orders\".\"total\" IS 'In cents';\n"+
+		"CREATE INDEX \"orders_idx_status\" ON \"orders\" (\"status\" DESC);\n"+
+		"ALTER TABLE \"orders\" ADD CONSTRAINT \"fk_user\" FOREIGN KEY (\"user_id\") REFERENCES \"users\" (\"id\") ON DELETE CASCADE;\n"+
+		"COMMENT ON TABLE \"orders\" IS 'Customer orders';\n"+
+		"SELECT setval(pg_get_serial_sequence('\"orders\"', 'id'), 42, false)", got.constraintsSQL)
+
+	// ON UPDATE and the full text index.
+	require.Len(t, got.warnings, 2)
+
+	columns := []string{"id", "user_id", "paid", "status", "total", "flags", "payload", "created_at", "updated_at", "total_x2"}
+	require.Equal(t, []string{"id", "user_id", "paid", "status", "total", "flags", "payload", "created_at", "updated_at"},
+		got.insertColumns(columns))
+
+	row := []sql.NullString{
+		{String: "1", Valid: true},
+		{String: "7", Valid: true},
+		{String: "1", Valid: true},
+		{String: "it's done", Valid: true},
+		{},
+		{String: "\x05", Valid: true},
+		{String: "\x00\xff", Valid: true},
+		{String: "0000-00-00 00:00:00.000", Valid: true},
+		{String: "2024-01-02 03:04:05", Valid: true},
+		{String: "0.00", Valid: true},
+	}
+	require.Equal(t, `('1','7',true,'it''s done',NULL,5,'\x00ff','-infinity','2024-01-02 03:04:05')`, got.formatRow(row))
+	require.Equal(t, []string{"zero dates of column created_at were written as '-infinity'"}, got.zeroDateWarnings())
+}
+
+func TestConvertTableToPostgres_Dates(t *testing.T) {
+	got, err := convertTableToPostgres("CREATE TABLE `shifts` (\n" +
+		"  `day` date NOT NULL DEFAULT '0000-00-00',\n" +
+		"  `closed_on` date DEFAULT '2024-00-00',\n" +
+		"  `length` time(3) NOT NULL DEFAULT '00:00:00.000'\n" +
+		") ENGINE=InnoDB")
+	require.NoError(t, err)
+
+	require.Equal(t, "CREATE TABLE \"shifts\" (\n"+
+		"  \"day\" date DEFAULT '-infinity' NOT NULL,\n"+
+		"  \"closed_on\" date,\n"+
+		"  \"length\" interval(3) DEFAULT '00:00:00.000' NOT NULL\n"+
+		")", got.createSQL)
+
+	// The zero date defaults and the TIME column.
+	require.Len(t, got.warnings, 3)
+
+	// Times can be negative or exceed 24 hours, and dates can be partly zero.
+	require.Equal(t, `('-infinity',NULL,'838:59:59.000')`, got.formatRow([]sql.NullString{
+		{String: "2024-01-00", Valid: true},
+		{String: "2024-00-15", Valid: true},
+		{String: "838:59:59.000", Valid: true},
+	}))
+	require.Equal(t, `('2024-01-02','2024-01-03','-01:30:00.000')`, got.formatRow([]sql.NullString{
+		{String: "2024-01-02", Valid: true},
+		{String: "2024-01-03", Valid: true},
+		{String: "-01:30:00.000", Valid: true},
+	}))
+	require.Equal(t, []string{
+		"zero dates of column day were written as '-infinity'",
+		"zero dates of column closed_on were written as NULL",
+	}, got.zeroDateWarnings())
+}
+
+func TestConvertViewToPostgres(t *testing.T) {
+	got := convertViewToPostgres("CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `paid_orders` AS " +
+		"select `orders`.`id` AS `id` from `orders` where (`orders`.`status` = _utf8mb4'it''s done')")
+	require.Equal(t, "CREATE OR REPLACE VIEW \"paid_orders\" AS "+
+		"select \"orders\".\"id\" AS \"id\" from \"orders\" where (\"orders\".\"status\" = 'it''s done')", got)
+}
+
+func TestWithTargetDialect(t *testing.T) {
+	d := &Dumpster{dialect: NewMySQLDialect(nil)}
+	require.NoError(t, WithTargetDialect(DialectPostgres)(d))
+	require.Equal(t, DialectPostgres, d.outputDialect().Name())
+	require.Error(t, WithTargetDialect(DialectSQLite)(d))
+
+	d = &Dumpster{dialect: NewPostgresDialect(nil)}
+	require.Error(t, WithTargetDialect(DialectPostgres+"x")(d))
+	require.NoError(t, WithTargetDialect(DialectPostgres)(d))
+	require.Equal(t, DialectPostgres, d.outputDialect().Name())
+}
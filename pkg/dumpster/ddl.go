@@ -1,81 +1,130 @@
 package dumpster
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"text/template"
 	"time"
 )
 
-type ddl struct {
-	Database      string
-	ServerVersion string
-	Tables        []*table
-	Triggers      []*trigger
-	CompleteTime  string
+// GetDDL returns the DDL of the database.
+func (d *Dumpster) GetDDL(ctx context.Context) (string, error) {
+	start := time.Now()
+
+	data, err := d.getDDL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Set start and complete time
+	end := time.Now()
+	data.StartTime = start.Format(time.RFC3339)
+	data.started = start
+	data.completed = end
+
+	return d.render(data)
 }
 
-func (d *Dumpster) GetDDL() (string, error) {
-	schemaName, err := d.GetSchemaName()
+// GetNormalizedDDL returns the DDL of the database with volatile attributes removed, so that two runs against the same
+// schema produce the same output. This makes the DDL suitable for version control.
+func (d *Dumpster) GetNormalizedDDL(ctx context.Context, stripDefiners bool) (string, error) {
+	data, err := d.getDDL(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error getting schema name: %w", err)
+		return "", err
 	}
 
-	data := ddl{
+	normalizeDDL(data, stripDefiners)
+
+	s, err := d.render(data)
+	if err != nil {
+		return "", err
+	}
+
+	return normalizeWhitespace(s), nil
+}
+
+func (d *Dumpster) getDDL(ctx context.Context) (*TemplateData, error) {
+	schemaName, err := d.GetSchemaName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting schema name: %w", err)
+	}
+
+	data := &TemplateData{
+		Dialect:  d.outputDialect().Name(),
 		Database: schemaName,
 	}
 
 	// Get server version
-	if data.ServerVersion, err = d.getServerVersion(); err != nil {
-		return "", fmt.Errorf("error getting server version: %w", err)
+	if data.ServerVersion, err = d.dialect.ServerVersion(ctx); err != nil {
+		return nil, fmt.Errorf("error getting server version: %w", err)
+	}
+
+	// Get the character set, collation and SQL mode
+	if d.isMySQL() {
+		if data.Session, err = d.getSession(ctx); err != nil {
+			return nil, fmt.Errorf("error getting session: %w", err)
+		}
+	}
+
+	// Get the extensions, types and sequences the tables can depend on
+	if data.Objects, err = d.dialect.SchemaObjects(ctx); err != nil {
+		return nil, fmt.Errorf("error getting schema objects: %w", err)
 	}
 
 	// Get tables
-	tables, err := d.getTables()
+	tables, err := d.tables(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error getting tables: %w", err)
+		return nil, fmt.Errorf("error getting tables: %w", err)
 	}
 
-	// Get sql for each table
+	// Get sql for each table. For the DDL we don't need the values.
 	for _, tn := range tables {
-		t, err := d.createTable(tn)
+		if d.hooks.BeforeTable != nil {
+			if err := d.hooks.BeforeTable(tn); err != nil {
+				return nil, err
+			}
+		}
+
+		t, _, err := d.createTableDDL(ctx, tn)
 		if err != nil {
-			return "", fmt.Errorf("error creating table: %w", err)
+			return nil, fmt.Errorf("error creating table: %w", err)
 		}
 
-		t.Values = "" // For the DDL we don't need the values
+		if d.hooks.AfterTable != nil {
+			if err := d.hooks.AfterTable(t); err != nil {
+				return nil, err
+			}
+		}
 
 		data.Tables = append(data.Tables, t)
 	}
 
 	// Get triggers
-	triggers, err := d.getTriggers()
+	triggers, err := d.triggers(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error getting triggers: %w", err)
+		return nil, fmt.Errorf("error getting triggers: %w", err)
 	}
 
 	// Get sql for each trigger
 	for _, tn := range triggers {
-		t, err := d.createTrigger(tn)
+		t, err := d.createTrigger(ctx, tn)
 		if err != nil {
-			return "", fmt.Errorf("error creating trigger: %w", err)
+			return nil, fmt.Errorf("error creating trigger: %w", err)
 		}
 
-		data.Triggers = append(data.Triggers, t)
+		if d.includeTrigger(t) {
+			data.Triggers = append(data.Triggers, t)
+		}
 	}
 
-	// Set complete time
-	data.CompleteTime = time.Now().Format(time.RFC3339)
-
-	t, err := template.New("mysqldump").Parse(tmpl)
-	if err != nil {
-		return "", fmt.Errorf("error parsing template: %w", err)
+	// Get views
+	if data.Views, err = d.views(ctx); err != nil {
+		return nil, err
 	}
 
-	b := new(bytes.Buffer)
-	if err = t.Execute(b, data); err != nil {
-		return "", fmt.Errorf("error executing template: %w", err)
+	// Get routines
+	if data.Routines, err = d.routines(ctx); err != nil {
+		return nil, err
 	}
 
-	return b.String(), nil
+	return data, nil
 }
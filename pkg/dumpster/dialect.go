@@ -0,0 +1,214 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+)
+
+const (
+	// DialectMySQL is the name of the MySQL dialect. It is also used for MariaDB.
+	DialectMySQL = "mysql"
+
+	// DialectPostgres is the name of the PostgreSQL dialect.
+	DialectPostgres = "postgres"
+
+	// DialectSQLite is the name of the SQLite dialect.
+	DialectSQLite = "sqlite3"
+)
+
+// Dialect reads the schema and data of a database in the SQL dialect of its server.
+type Dialect interface {
+	// Name returns the name of the dialect, which is also the name of its database/sql driver.
+	Name() string
+
+	// SchemaName returns the name of the database being dumped.
+	SchemaName(ctx context.Context) (string, error)
+
+	// ServerVersion returns the version of the database server.
+	ServerVersion(ctx context.Context) (string, error)
+
+	// Tables returns the names of the tables, excluding views, ordered by name.
+	Tables(ctx context.Context) ([]string, error)
+
+	// TableSQL returns the statement that creates the table, without a trailing semicolon.
+	TableSQL(ctx context.Context, name string) (string, error)
+
+	// TableConstraintsSQL returns the statements that must run after every table has been created and loaded, such as
+	// foreign keys and indexes, separated by semicolons and without a trailing semicolon. It is empty if the table
+	// definition is complete.
+	TableConstraintsSQL(ctx context.Context, name string) (string, error)
+
+	// Triggers returns the names of the triggers.
+	Triggers(ctx context.Context) ([]string, error)
+
+	// TriggerSQL returns the statement that creates the trigger, without a trailing semicolon.
+	TriggerSQL(ctx context.Context, name string) (string, error)
+
+	// Views returns the names of the views, ordered so that every view comes after the views it selects from where the
+	// dialect tracks dependencies, and by name otherwise. MySQL views are returned by name, and the dump orders them
+	// by their definitions.
+	Views(ctx context.Context) ([]string, error)
+
+	// ViewSQL returns the statement that creates the view, without a trailing semicolon.
+	ViewSQL(ctx context.Context, name string) (string, error)
+
+	// TableEstimates returns the estimated number of rows and size of the tables, from the statistics of the server.
+	// The estimates can be inaccurate, and tables without statistics may be missing.
+	TableEstimates(ctx context.Context) ([]*TableEstimate, error)
+
+	// Routines returns the stored procedures and functions with the statements that create them.
+	Routines(ctx context.Context) ([]*Routine, error)
+
+	// SchemaObjects returns the objects the tables can depend on, such as extensions, types and sequences, in the order
+	// they must be created. It is empty for dialects whose tables only use built-in types.
+	SchemaObjects(ctx context.Context) ([]*SchemaObject, error)
+
+	// KeyColumns returns the columns of the primary key of the table in key order, or nil if the table has none.
+	KeyColumns(ctx context.Context, name string) ([]string, error)
+
+	// ReadRows calls fn with the values of the rows of the table in the range, or of every row if the range is nil, in
+	// the text form of the server, or as literals for SQLite. It returns the names of the columns the values are for.
+	ReadRows(ctx context.Context, name string, r *RowRange, fn func(values []sql.NullString) error) ([]string, error)
+
+	// QuoteIdentifier quotes the name of a database object.
+	QuoteIdentifier(name string) string
+
+	// QuoteValue returns the value as a literal, or NULL.
+	QuoteValue(value sql.NullString) string
+}
+
+// Queryer runs the statements of a dialect. It is implemented by *sqlx.DB, and by *sqlx.Tx to read a consistent
+// snapshot of the database.
+type Queryer interface {
+	// DriverName returns the name of the database/sql driver.
+	DriverName() string
+
+	// GetContext runs the query and scans the single row into dest.
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+
+	// SelectContext runs the query and scans every row into dest, which must be a slice.
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+
+	// PrepareContext creates a prepared statement.
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+
+	// QueryRowContext runs a query that returns at most one row.
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// newDialect returns the dialect for the driver the database was opened with. MySQL is used for unknown drivers.
+func newDialect(db Queryer) Dialect {
+	switch db.DriverName() {
+	case DialectPostgres:
+		return NewPostgresDialect(db)
+	case DialectSQLite:
+		return NewSQLiteDialect(db)
+	default:
+		return NewMySQLDialect(db)
+	}
+}
+
+// RowRange selects a chunk of the rows of a table in the order of its primary key.
+type RowRange struct {
+	// Key are the columns of the primary key of the table.
+	Key []string
+
+	// After are the values of the key of the last row of the previous chunk. The chunk starts at the first row if nil.
+	After []sql.NullString
+
+	// Limit is the maximum number of rows in the chunk.
+	Limit int
+}
+
+// clause returns the WHERE, ORDER BY and LIMIT clauses that select the range in the dialect, or an empty string to
+// select every row if the range is nil.
+func (r *RowRange) clause(d Dialect) string {
+	if r == nil {
+		return ""
+	}
+
+	key := make([]string, len(r.Key))
+	for i, k := range r.Key {
+		key[i] = d.QuoteIdentifier(k)
+	}
+
+	clause := ""
+	if r.After != nil {
+		// (a, b) > (x, y) is written out as a > x OR (a = x AND b > y), which every dialect can use the key for.
+		or := make([]string, len(key))
+		for i := range key {
+			and := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				and = append(and, key[j]+" = "+d.QuoteValue(r.After[j]))
+			}
+
+			and = append(and, key[i]+" > "+d.QuoteValue(r.After[i]))
+			or[i] = "(" + strings.Join(and, " AND ") + ")"
+		}
+
+		clause += " WHERE " + strings.Join(or, " OR ")
+	}
+
+	return clause + " ORDER BY " + strings.Join(key, ", ") + " LIMIT " + strconv.Itoa(r.Limit)
+}
+
+// readRows runs the query and calls fn with the values of every row. It returns the names of the columns.
+func readRows(ctx context.Context, db Queryer, query string, fn func(values []sql.NullString) error) ([]string, error) {
+	// Prepare statement for reading data
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	// Get columns
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error getting columns: %w", err)
+	} else if len(columns) == 0 {
+		return nil, errors.New("no columns found")
+	}
+
+	// Read data
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		if err := fn(values); err != nil {
+			return nil, err
+		}
+	}
+
+	return columns, rows.Err()
+}
@@ -1,48 +1,29 @@
 package dumpster
 
 import (
-	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path"
+	"slices"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+	"github.com/jmoiron/sqlx"
 )
 
-type table struct {
-	Name   string
-	SQL    string
-	Values string
-}
-
-type trigger struct {
-	Name string
-	SQL  string
-}
-
-type dump struct {
-	Database      string
-	ServerVersion string
-	Tables        []*table
-	Triggers      []*trigger
-	CompleteTime  string
-}
-
-// DumpFile creates a new dump of the database
-func (d *Dumpster) DumpFile() (string, error) {
+// DumpFile creates a new dump of the database in the dumps directory of the working directory and returns the path of
+// the file.
+func (d *Dumpster) DumpFile(ctx context.Context) (string, error) {
 	timestamp := time.Now().Format(time.RFC3339)
 
-	data, err := d.Dump()
-	if err != nil {
-		return "", fmt.Errorf("error creating dump: %w", err)
-	}
-
 	// Get the PWD
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -52,7 +33,7 @@ func (d *Dumpster) DumpFile() (string, error) {
 	pwd = pwd + "/dumps"
 
 	// Create the dump directory
-	p := path.Join(pwd, timestamp+".sql")
+	p := path.Join(pwd, timestamp+".sql"+d.compression.Extension())
 
 	// Ensure that the full path exists, if not create it
 	if err := os.MkdirAll(pwd, os.ModePerm); err != nil {
@@ -71,396 +52,559 @@ func (d *Dumpster) DumpFile() (string, error) {
 		}
 	}(f)
 
-	// Write the dump to the file
-	if _, err := f.WriteString(data); err != nil {
-		return "", fmt.Errorf("error writing to file: %w", err)
+	// Write the dump to the file, removing it if the dump fails so no partial dump is left behind
+	if err := d.DumpTo(ctx, f); err != nil {
+		if err := os.Remove(p); err != nil {
+			slog.Warn("Error removing partial dump", slog.String(logging.KeyError, err.Error()))
+		}
+
+		return "", fmt.Errorf("error creating dump: %w", err)
 	}
 
 	return p, nil
 }
 
-// Dump creates a new dump of the database and returns the content.
-func (d *Dumpster) Dump() (string, error) {
-	schemaName, err := d.GetSchemaName()
-	if err != nil {
-		return "", fmt.Errorf("error getting schema name: %w", err)
+// DumpTo creates a new dump of the database and writes it to w, compressed with the compression of the dumpster. The
+// dump is rendered straight into w, and the rows of each table are read as they are written, so only a batch of rows
+// at a time is held in memory.
+func (d *Dumpster) DumpTo(ctx context.Context, w io.Writer) error {
+	if d.compression != CompressionGzip {
+		return d.dumpTo(ctx, w)
 	}
 
-	data := dump{
-		Database: schemaName,
-		Tables:   make([]*table, 0),
+	gz := gzip.NewWriter(w)
+	if err := d.dumpTo(ctx, gz); err != nil {
+		return err
 	}
 
-	// Get server version
-	if data.ServerVersion, err = d.getServerVersion(); err != nil {
-		return "", fmt.Errorf("error getting server version: %w", err)
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error closing gzip writer: %w", err)
 	}
 
-	// Get tables
-	tables, err := d.getTables()
+	return nil
+}
+
+// Dump creates a new dump of the database and returns the content. The content is not compressed. If snapshot is set,
+// the tables are read in a single read-only transaction.
+func (d *Dumpster) Dump(ctx context.Context) (string, error) {
+	b := new(strings.Builder)
+	if err := d.dumpTo(ctx, b); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// dumpTo writes the dump to w, in a single read-only transaction if snapshot is set.
+func (d *Dumpster) dumpTo(ctx context.Context, w io.Writer) error {
+	if !d.snapshot {
+		return d.writeDump(ctx, w)
+	}
+
+	tx, err := d.db.BeginTxx(ctx, d.snapshotTxOptions())
 	if err != nil {
-		return "", fmt.Errorf("error getting tables: %w", err)
+		return fmt.Errorf("error starting snapshot transaction: %w", err)
 	}
 
-	// Get sql for each table
-	for _, tn := range tables {
-		t, err := d.createTable(tn)
-		if err != nil {
-			return "", fmt.Errorf("error creating table: %w", err)
+	// Nothing is written in the transaction, so it is rolled back once the dump has been written.
+	defer func(tx *sqlx.Tx) {
+		if err := tx.Rollback(); err != nil {
+			slog.Warn("Error rolling back snapshot transaction", slog.String(logging.KeyError, err.Error()))
 		}
+	}(tx)
 
-		data.Tables = append(data.Tables, t)
+	snapshot := *d
+	snapshot.tx = tx
+	snapshot.dialect = newDialect(tx)
+	return snapshot.writeDump(ctx, w)
+}
+
+// snapshotTxOptions returns the options of the transaction a snapshot is read in.
+func (d *Dumpster) snapshotTxOptions() *sql.TxOptions {
+	if d.dialect.Name() == DialectSQLite {
+		// SQLite transactions are serializable, and a deferred transaction reads a snapshot from the first table it
+		// reads. The driver only supports read-only transactions on connections that set pragmas.
+		return &sql.TxOptions{}
 	}
 
-	// Get triggers
-	triggers, err := d.getTriggers()
+	return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+}
+
+// writeDump reads the dump and renders it to w. The rows of each table are read as the template ranges over them.
+func (d *Dumpster) writeDump(ctx context.Context, w io.Writer) error {
+	streams := newRowStreams(ctx)
+
+	data, p, err := d.dump(ctx, streams)
 	if err != nil {
-		return "", fmt.Errorf("error getting triggers: %w", err)
+		streams.abort()
+		return err
 	}
 
-	// Get sql for each trigger
-	for _, tn := range triggers {
-		t, err := d.createTrigger(tn)
-		if err != nil {
-			return "", fmt.Errorf("error creating trigger: %w", err)
+	if err := d.renderTo(w, data); err != nil {
+		// A failed read stops the render, so it is the error to report.
+		readErr := streams.failed()
+		streams.abort()
+		if readErr != nil {
+			return readErr
 		}
 
-		data.Triggers = append(data.Triggers, t)
+		return fmt.Errorf("error writing dump: %w", err)
 	}
 
-	// Set complete time
-	data.CompleteTime = time.Now().Format(time.RFC3339)
+	if err := streams.finish(); err != nil {
+		return err
+	}
 
-	t, err := template.New("mysqldump").Parse(tmpl)
+	p.finished()
+
+	return nil
+}
+
+// dump reads the dump, with a stream reading the rows of each table, and returns it with its progress.
+func (d *Dumpster) dump(ctx context.Context, streams *rowStreams) (*TemplateData, *progress, error) {
+	start := time.Now()
+
+	schemaName, err := d.GetSchemaName(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error parsing template: %w", err)
+		return nil, nil, fmt.Errorf("error getting schema name: %w", err)
 	}
 
-	b := new(bytes.Buffer)
-	if err = t.Execute(b, data); err != nil {
-		return "", fmt.Errorf("error executing template: %w", err)
+	data := &TemplateData{
+		Dialect:  d.outputDialect().Name(),
+		Database: schemaName,
+		Tables:   make([]*Table, 0),
+		Options: TemplateOptions{
+			Data:   true,
+			Grants: d.includeGrants,
+		},
+		StartTime: start.Format(time.RFC3339),
+		started:   start,
 	}
 
-	return b.String(), nil
-}
+	// Get server version
+	if data.ServerVersion, err = d.dialect.ServerVersion(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error getting server version: %w", err)
+	}
 
-func (d *Dumpster) getTriggers() ([]string, error) {
-	sqlStmt := "SHOW TRIGGERS"
+	// Get the character set, collation and SQL mode
+	if d.isMySQL() {
+		if data.Session, err = d.getSession(ctx); err != nil {
+			return nil, nil, fmt.Errorf("error getting session: %w", err)
+		}
+	}
+
+	// Get the extensions, types and sequences the tables can depend on
+	if data.Objects, err = d.dialect.SchemaObjects(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error getting schema objects: %w", err)
+	}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
+	// Get tables
+	tables, err := d.tables(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error preparing statement: %w", err)
+		return nil, nil, fmt.Errorf("error getting tables: %w", err)
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+	progress := d.newProgress(ctx, tables)
+	throttle := d.newThrottler()
+	if err := throttle.start(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error checking replication: %w", err)
+	}
+
+	// Get sql for each table. The rows are read as the dump is rendered.
+	for _, tn := range tables {
+		if d.hooks.BeforeTable != nil {
+			if err := d.hooks.BeforeTable(tn); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		t, err := d.createTable(ctx, tn, streams, progress, throttle)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating table: %w", err)
+		}
+
+		if d.hooks.AfterTable != nil {
+			if err := d.hooks.AfterTable(t); err != nil {
+				return nil, nil, err
+			}
 		}
-	}(stmt)
 
-	// Execute statement
-	rows, err := stmt.Query()
+		data.Tables = append(data.Tables, t)
+	}
+
+	// Get triggers
+	triggers, err := d.triggers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error executing statement: %w", err)
+		return nil, nil, fmt.Errorf("error getting triggers: %w", err)
 	}
 
-	defer func(rows *sql.Rows) {
-		if err := rows.Close(); err != nil {
-			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+	// Get sql for each trigger
+	for _, tn := range triggers {
+		t, err := d.createTrigger(ctx, tn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating trigger: %w", err)
 		}
-	}(rows)
-
-	// Read data
-	triggers := make([]string, 0)
-	for rows.Next() {
-		t := new(sql.NullString)
-		event := new(sql.NullString)
-		sqlTable := new(sql.NullString)
-		statement := new(sql.NullString)
-		timing := new(sql.NullString)
-		created := new(sql.NullString)
-		sqlMode := new(sql.NullString)
-		definer := new(sql.NullString)
-		characterSetClient := new(sql.NullString)
-		collationConnection := new(sql.NullString)
-		databaseCollation := new(sql.NullString)
-
-		if err := rows.Scan(t, event, sqlTable, statement, timing, created, sqlMode, definer,
-			characterSetClient, collationConnection, databaseCollation); err != nil {
-			return nil, fmt.Errorf("error scanning: %w", err)
+
+		if d.includeTrigger(t) {
+			data.Triggers = append(data.Triggers, t)
 		}
+	}
 
-		if t.Valid {
-			triggers = append(triggers, t.String)
-		} else {
-			slog.Warn("trigger is not valid", slog.String("trigger", t.String))
+	// Get views
+	if data.Views, err = d.views(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// Get routines
+	if data.Routines, err = d.routines(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// Get users, roles and grants
+	if d.includeGrants && d.isMySQL() {
+		if data.Accounts, err = d.getAccounts(ctx, schemaName); err != nil {
+			return nil, nil, fmt.Errorf("error getting grants: %w", err)
 		}
 	}
 
-	return triggers, nil
+	return data, progress, nil
 }
 
-func (d *Dumpster) createTrigger(name string) (t *trigger, err error) {
-	t = &trigger{
+func (d *Dumpster) createTrigger(ctx context.Context, name string) (t *Trigger, err error) {
+	t = &Trigger{
 		Name: name,
 	}
 
-	if t.SQL, err = d.createTriggerSQL(name); err != nil {
+	if t.SQL, err = d.dialect.TriggerSQL(ctx, name); err != nil {
 		return nil, err
 	}
 
+	t.SQL = d.compatObjectSQL("trigger", name, t.SQL)
+
 	return t, nil
 }
 
-func (d *Dumpster) createTriggerSQL(name string) (string, error) {
-	sqlStmt := "SHOW CREATE TRIGGER " + name
-
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
+// views returns the views, ordered so that every view comes after the views it selects from. MySQL returns the views
+// by name, so they are ordered by their definitions before they are converted to the target dialect.
+func (d *Dumpster) views(ctx context.Context) ([]*View, error) {
+	names, err := d.dialect.Views(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error preparing statement: %w", err)
+		return nil, fmt.Errorf("error getting views: %w", err)
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+	views := make([]*View, 0, len(names))
+	for _, vn := range names {
+		v, err := d.createView(ctx, vn)
+		if err != nil {
+			return nil, fmt.Errorf("error creating view: %w", err)
 		}
-	}(stmt)
 
-	// Execute statement
-	triggerName := new(sql.NullString)
-	sqlMode := new(sql.NullString)
-	originalStatement := new(sql.NullString)
-	characterSetClient := new(sql.NullString)
-	collationConnection := new(sql.NullString)
-	databaseCollation := new(sql.NullString)
-	createdAt := new(sql.NullString)
+		views = append(views, v)
+	}
 
-	if err := stmt.QueryRow().Scan(triggerName, sqlMode, originalStatement, characterSetClient,
-		collationConnection, databaseCollation, createdAt); err != nil {
-		return "", fmt.Errorf("error executing statement: %w", err)
+	if !d.isMySQL() {
+		return views, nil
 	}
 
-	if originalStatement.Valid {
-		return originalStatement.String, nil
-	} else {
-		return "", fmt.Errorf("error getting trigger SQL: %w", err)
+	views = orderViews(views)
+	if d.target == DialectPostgres {
+		for _, v := range views {
+			v.SQL = convertViewToPostgres(v.SQL)
+		}
 	}
+
+	return views, nil
 }
 
-func (d *Dumpster) getTables() ([]string, error) {
-	sqlStmt := "SHOW TABLES"
+func (d *Dumpster) createView(ctx context.Context, name string) (v *View, err error) {
+	v = &View{
+		Name: name,
+	}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return nil, fmt.Errorf("error preparing statement: %w", err)
+	if v.SQL, err = d.dialect.ViewSQL(ctx, name); err != nil {
+		return nil, err
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
-		}
-	}(stmt)
+	v.SQL = d.compatObjectSQL("view", name, v.SQL)
 
-	// Execute statement
-	rows, err := stmt.Query()
+	return v, nil
+}
+
+// createTable returns the table with its definition, and a stream reading its rows once the template asks for them.
+func (d *Dumpster) createTable(ctx context.Context, name string, streams *rowStreams, p *progress, th *throttler) (*Table, error) {
+	t, rows, err := d.createTableDDL(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("error executing statement: %w", err)
+		return nil, err
 	}
 
-	defer func(rows *sql.Rows) {
-		if err := rows.Close(); err != nil {
-			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
-		}
-	}(rows)
-
-	// Read data
-	tables := make([]string, 0)
-	for rows.Next() {
-		var t sql.NullString
-		if err := rows.Scan(&t); err != nil {
-			return nil, fmt.Errorf("error scanning: %w", err)
-		}
+	t.stream = streams.table(func(ctx context.Context, send func(batch string) error) error {
+		p.tableStarted(name)
+
+		if !matchAny(d.excludeData, name) {
+			// Wait for the load of the server to drop before starting on the table.
+			if err := th.checkLoad(ctx); err != nil {
+				return err
+			}
+
+			if err := d.createTableValues(ctx, t, rows, p, th, send); err != nil {
+				return fmt.Errorf("error reading table %s: %w", name, err)
+			}
 
-		if t.Valid {
-			tables = append(tables, t.String)
-		} else {
-			slog.Warn("table is not valid", slog.String("table", t.String))
+			if pt, ok := rows.(*postgresTable); ok {
+				d.logConversionWarnings("table", name, pt.zeroDateWarnings())
+			}
 		}
-	}
 
-	return tables, nil
+		p.tableFinished()
+		return nil
+	})
+
+	return t, nil
 }
 
-func (d *Dumpster) getServerVersion() (string, error) {
-	sqlStmt := "SELECT version()"
+// createTableDDL returns the table with its definition but without its values, and the formatter for its rows.
+func (d *Dumpster) createTableDDL(ctx context.Context, name string) (t *Table, rows rowFormatter, err error) {
+	t = &Table{
+		Name: name,
+	}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return "", fmt.Errorf("error preparing statement: %w", err)
+	if t.SQL, err = d.createTableSQL(ctx, name); err != nil {
+		return nil, nil, err
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
-		}
-	}(stmt)
+	if t.ConstraintsSQL, err = d.dialect.TableConstraintsSQL(ctx, name); err != nil {
+		return nil, nil, err
+	}
 
-	version := ""
+	if s, ok := d.dialect.(*sqliteDialect); ok {
+		return t, &dialectRows{dialect: sqliteLiterals{s}}, nil
+	}
 
-	// Execute statement
-	if err := stmt.QueryRow().Scan(&version); err != nil {
-		return "", fmt.Errorf("error executing statement: %w", err)
+	if d.target != DialectPostgres {
+		return t, &dialectRows{dialect: d.dialect}, nil
 	}
 
-	if version == "" {
-		return "", errors.New("returned version is empty")
+	pt, err := convertTableToPostgres(t.SQL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error converting table %s: %w", name, err)
 	}
 
-	return version, nil
+	d.logConversionWarnings("table", name, pt.warnings)
+	t.SQL = pt.createSQL
+	t.ConstraintsSQL = pt.constraintsSQL
+
+	return t, pt, nil
 }
 
-func (d *Dumpster) createTable(name string) (t *table, err error) {
-	t = &table{
-		Name: name,
+// createTableSQL returns the statement that creates the table, rewritten for the compatibility mode.
+func (d *Dumpster) createTableSQL(ctx context.Context, name string) (string, error) {
+	tableSQL, err := d.dialect.TableSQL(ctx, name)
+	if err != nil {
+		return "", err
 	}
 
-	if t.SQL, err = d.createTableSQL(name); err != nil {
-		return nil, err
+	createSQL, warnings := compatTableSQL(tableSQL, d.compat)
+	for _, w := range warnings {
+		slog.Warn("Table definition is not fully compatible with the target server",
+			slog.String("compat", string(d.compat)),
+			slog.String("table", name),
+			slog.String("detail", w),
+		)
 	}
 
-	if t.Values, err = d.createTableValues(name); err != nil {
-		return nil, err
+	return createSQL, nil
+}
+
+// compatObjectSQL returns the statement that creates the view, trigger or routine, rewritten for the compatibility
+// mode.
+func (d *Dumpster) compatObjectSQL(kind, name, createSQL string) string {
+	createSQL, warnings := compatObjectSQL(createSQL, d.compat)
+	for _, w := range warnings {
+		slog.Warn("Definition is not fully compatible with the target server",
+			slog.String("compat", string(d.compat)),
+			slog.String(kind, name),
+			slog.String("detail", w),
+		)
 	}
 
-	return t, nil
+	return createSQL
 }
 
-func (d *Dumpster) createTableSQL(name string) (string, error) {
-	sqlStmt := "SHOW CREATE TABLE " + name
+// createTableValues sends the rows of the table, as the value lists of INSERT statements of at most the batch size,
+// and sets its columns before the first batch and the checksum of its rows once every row has been sent. Each row is
+// passed to the Row hook, counted by the progress and waits for the throttle.
+func (d *Dumpster) createTableValues(ctx context.Context, t *Table, rows rowFormatter, p *progress, th *throttler, send func(batch string) error) error {
+	checksum := newRowChecksum()
+	batch := make([]string, 0)
+	columns, err := d.readTableRows(ctx, t.Name, func(columns []string, values []sql.NullString) error {
+		if d.hooks.Row != nil {
+			if err := d.hooks.Row(t.Name, columns, values); err != nil {
+				return err
+			}
+		}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return "", fmt.Errorf("error preparing statement: %w", err)
-	}
+		row := rows.formatRow(values)
+		if err := th.wait(ctx, len(row)); err != nil {
+			return err
+		}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		p.row(len(row))
+		checksum.add(row)
+
+		batch = append(batch, row)
+		if len(batch) != d.batchSize {
+			return nil
+		}
+
+		// The template reads the columns with the batches, so they are only set once, before the first batch.
+		if t.Columns == nil {
+			t.Columns = rows.insertColumns(columns)
 		}
-	}(stmt)
 
-	// Execute statement
-	var tableReturn sql.NullString
-	var tableSql sql.NullString
-	if err := stmt.QueryRow().Scan(&tableReturn, &tableSql); err != nil {
-		return "", fmt.Errorf("error executing statement: %w", err)
+		err := send(strings.Join(batch, ","))
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	if tableReturn.String != name {
-		return "", errors.New("returned table is not the same as requested table")
+	if t.Columns == nil {
+		t.Columns = rows.insertColumns(columns)
 	}
 
-	if !tableSql.Valid {
-		return "", errors.New("returned table SQL is not valid")
+	if len(batch) > 0 {
+		if err := send(strings.Join(batch, ",")); err != nil {
+			return err
+		}
 	}
 
-	return tableSql.String, nil
-}
+	c := checksum.checksum(t.Name)
+	t.Rows = c.Rows
+	t.Checksum = c.Checksum
 
-func (d *Dumpster) createTableValues(name string) (string, error) {
-	sqlStmt := "SELECT * FROM " + name
+	return nil
+}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
+// rowChunkSize is the default number of rows read from a table with a primary key in each query.
+const rowChunkSize = 10000
+
+// readTableRows calls fn with the columns and the values of every row of the table, and returns the names of the
+// columns. Tables with a
+// primary key are read in chunks of the chunk size in key order. Each chunk is read to the end before fn is called
+// for its rows, so that fn can wait, e.g. for the throttle, without holding a query open on the server, which MySQL
+// aborts once it cannot send for net_write_timeout. Tables without a primary key are read in a single query, so they
+// are read to the end before fn is called for any of their rows.
+//
+// Each chunk is a separate query, so the rows of a table are only consistent with each other if the dump reads a
+// snapshot.
+func (d *Dumpster) readTableRows(ctx context.Context, name string, fn func(columns []string, values []sql.NullString) error) ([]string, error) {
+	key, err := d.dialect.KeyColumns(ctx, name)
 	if err != nil {
-		return "", fmt.Errorf("error preparing statement: %w", err)
+		return nil, err
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
-		}
-	}(stmt)
-
-	// Execute statement
-	rows, err := stmt.Query()
-	if err != nil {
-		return "", fmt.Errorf("error executing statement: %w", err)
+	var r *RowRange
+	if len(key) > 0 {
+		r = &RowRange{Key: key, Limit: cmp.Or(d.chunkSize, rowChunkSize)}
 	}
 
-	defer func(rows *sql.Rows) {
-		if err := rows.Close(); err != nil {
-			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+	for {
+		chunk := make([][]sql.NullString, 0)
+		columns, err := d.dialect.ReadRows(ctx, name, r, func(values []sql.NullString) error {
+			chunk = append(chunk, values)
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
-	}(rows)
 
-	// Get columns
-	columns, err := rows.Columns()
-	if err != nil {
-		return "", fmt.Errorf("error getting columns: %w", err)
-	} else if len(columns) == 0 {
-		return "", errors.New("no columns found")
-	}
-
-	// Read data
-	dataText := make([]string, 0)
-	for rows.Next() {
-		data := make([]*sql.NullString, len(columns))
-		pointers := make([]any, len(columns))
-		for i := range data {
-			pointers[i] = &data[i]
+		// The key of the next chunk is taken before fn is called, as fn can change the values.
+		last := r == nil || len(chunk) < r.Limit
+		if !last {
+			if r.After, err = keyValues(columns, key, chunk[len(chunk)-1]); err != nil {
+				return nil, fmt.Errorf("error reading table %s: %w", name, err)
+			}
 		}
 
-		// Read data
-		if err := rows.Scan(pointers...); err != nil {
-			return "", err
+		for _, values := range chunk {
+			if err := fn(columns, values); err != nil {
+				return nil, err
+			}
 		}
 
-		dataStrings := make([]string, len(columns))
+		if last {
+			return columns, nil
+		}
+	}
+}
 
-		for key, value := range data {
-			if value != nil && value.Valid {
-				dataStrings[key] = "'" + value.String + "'"
-			} else {
-				dataStrings[key] = "null"
-			}
+// keyValues returns the values of the key columns in the row.
+func keyValues(columns, key []string, row []sql.NullString) ([]sql.NullString, error) {
+	values := make([]sql.NullString, len(key))
+	for i, k := range key {
+		j := slices.Index(columns, k)
+		if j < 0 {
+			return nil, fmt.Errorf("primary key column %s is not read", k)
 		}
 
-		dataText = append(dataText, "("+strings.Join(dataStrings, ",")+")")
+		values[i] = row[j]
 	}
 
-	return strings.Join(dataText, ","), nil
+	return values, nil
 }
 
-func (d *Dumpster) GetSchemaName() (string, error) {
-	sqlStmt := "SELECT DATABASE()"
+// includeTrigger reports whether the trigger is on a table that is part of the dump and DDL.
+func (d *Dumpster) includeTrigger(t *Trigger) bool {
+	if len(d.includeTables) == 0 && len(d.excludeTables) == 0 {
+		return true
+	}
+
+	obj, err := sqlparse.ParseObject(t.SQL)
+	if err != nil || obj.Table == "" {
+		return true
+	}
+
+	return d.includeTable(obj.Table)
+}
 
-	// Prepare statement for reading data
-	stmt, err := d.db.Prepare(sqlStmt)
+// triggers returns the names of the triggers to write. Triggers are not converted to other dialects.
+func (d *Dumpster) triggers(ctx context.Context) ([]string, error) {
+	triggers, err := d.dialect.Triggers(ctx)
+	if err != nil || d.target == "" {
+		return triggers, err
+	}
+
+	for _, name := range triggers {
+		d.logConversionWarnings("trigger", name, []string{"triggers are not converted and were skipped"})
+	}
+
+	return make([]string, 0), nil
+}
+
+// routines returns the routines to write. Routines are not converted to other dialects.
+func (d *Dumpster) routines(ctx context.Context) ([]*Routine, error) {
+	routines, err := d.dialect.Routines(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error preparing statement: %w", err)
+		return nil, err
 	}
 
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+	if d.target == "" {
+		for _, r := range routines {
+			r.SQL = d.compatObjectSQL("routine", r.Name, r.SQL)
 		}
-	}(stmt)
 
-	// Execute statement
-	var schema sql.NullString
-	if err := stmt.QueryRow().Scan(&schema); err != nil {
-		return "", fmt.Errorf("error executing statement: %w", err)
+		return routines, nil
 	}
 
-	if !schema.Valid {
-		return "", errors.New("returned schema is not valid")
+	for _, r := range routines {
+		d.logConversionWarnings("routine", r.Name, []string{"routines are not converted and were skipped"})
 	}
 
-	return schema.String, nil
+	return make([]*Routine, 0), nil
+}
+
+// GetSchemaName returns the name of the database being dumped.
+func (d *Dumpster) GetSchemaName(ctx context.Context) (string, error) {
+	return d.dialect.SchemaName(ctx)
 }
@@ -0,0 +1,42 @@
+package dumpster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpster_Views(t *testing.T) {
+	newDialect := func(t *testing.T) *MockDialect {
+		dialect := NewMockDialect(t)
+		dialect.On("Name").Return(DialectMySQL)
+		dialect.On("Views", mock.Anything).Return([]string{"active_users", "report", "users_v"}, nil)
+		dialect.On("ViewSQL", mock.Anything, "active_users").Return("CREATE VIEW `active_users` AS select `id` from `users_v`", nil)
+		dialect.On("ViewSQL", mock.Anything, "report").Return("CREATE VIEW `report` AS select count(0) AS `n` from `active_users`", nil)
+		dialect.On("ViewSQL", mock.Anything, "users_v").Return("CREATE VIEW `users_v` AS select `id` from `users`", nil)
+		return dialect
+	}
+
+	t.Run("dependency order", func(t *testing.T) {
+		d := &Dumpster{dialect: newDialect(t)}
+
+		views, err := d.views(context.Background())
+		require.NoError(t, err)
+		require.Len(t, views, 3)
+		require.Equal(t, "users_v", views[0].Name)
+		require.Equal(t, "active_users", views[1].Name)
+		require.Equal(t, "report", views[2].Name)
+	})
+
+	t.Run("ordered before conversion", func(t *testing.T) {
+		d := &Dumpster{dialect: newDialect(t), target: DialectPostgres}
+
+		views, err := d.views(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "users_v", views[0].Name)
+		require.Equal(t, "report", views[2].Name)
+		require.Contains(t, views[2].SQL, `from "active_users"`)
+	})
+}
@@ -1,17 +1,113 @@
 package dumpster
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"text/template"
+
 	"github.com/jmoiron/sqlx"
 )
 
+// Dumper creates dumps and DDL of a database. It is implemented by *Dumpster.
+type Dumper interface {
+	// Dump creates a new dump of the database and returns the content.
+	Dump(ctx context.Context) (string, error)
+
+	// DumpTo creates a new dump of the database and writes it to w, compressed if compression is set.
+	DumpTo(ctx context.Context, w io.Writer) error
+
+	// DumpFile creates a new dump of the database in the dumps directory and returns the path of the file.
+	DumpFile(ctx context.Context) (string, error)
+
+	// GetDDL returns the DDL of the database.
+	GetDDL(ctx context.Context) (string, error)
+
+	// GetNormalizedDDL returns the DDL of the database with volatile attributes removed.
+	GetNormalizedDDL(ctx context.Context, stripDefiners bool) (string, error)
+
+	// GetSplitDDL returns the DDL of the database as one file per database object, plus an index file.
+	GetSplitDDL(ctx context.Context, normalize bool, stripDefiners bool) ([]*DDLFile, error)
+
+	// GetSchemaName returns the name of the database being dumped.
+	GetSchemaName(ctx context.Context) (string, error)
+}
+
 type Dumpster struct {
 	// db is the database to dump
 	db *sqlx.DB
+
+	// dialect reads the schema and data in the SQL dialect of the database
+	dialect Dialect
+
+	// tx is the transaction of the snapshot being read, or nil if the dump is not read in a snapshot
+	tx *sqlx.Tx
+
+	// includeGrants is whether the dump includes the users, roles and grants for the schema
+	includeGrants bool
+
+	// compat is the server the output is made compatible with
+	compat Compat
+
+	// template is the template to render dumps and DDL with. The default template is used if nil.
+	template *template.Template
+
+	// target is the dialect the output is converted to, or empty to write the dialect of the database
+	target string
+
+	// includeTables are the patterns of the tables to dump. Every table is dumped if empty.
+	includeTables []string
+
+	// excludeTables are the patterns of the tables to leave out
+	excludeTables []string
+
+	// excludeData are the patterns of the tables to dump without their rows
+	excludeData []string
+
+	// batchSize is the maximum number of rows per INSERT statement, or zero for a single statement per table
+	batchSize int
+
+	// chunkSize is the number of rows read from a table with a primary key in each query, or zero for rowChunkSize
+	chunkSize int
+
+	// snapshot is whether the tables are read in a single read-only transaction
+	snapshot bool
+
+	// compression is the compression of the dumps written by DumpTo and DumpFile
+	compression Compression
+
+	// hooks are called while a dump or DDL is created
+	hooks Hooks
+
+	// progress is called with the progress of each dump, or nil
+	progress func(e ProgressEvent)
+
+	// throttle limits how fast the rows of each dump are read
+	throttle Throttle
+
+	// replicaLag pauses each dump while the replication lag of the database is too high
+	replicaLag ReplicaLag
 }
 
-// NewDumpster creates a new dumpster
-func NewDumpster(db *sqlx.DB) *Dumpster {
-	return &Dumpster{
-		db: db,
+// NewDumpster creates a new dumpster configured by the options. The dialect is chosen from the driver the database was
+// opened with: postgres for PostgreSQL, sqlite3 for SQLite and MySQL otherwise.
+func NewDumpster(db *sqlx.DB, opts ...Option) (*Dumpster, error) {
+	d := &Dumpster{
+		db:      db,
+		dialect: newDialect(db),
+	}
+
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
 	}
+
+	return d, nil
+}
+
+// isMySQL reports whether the database is MySQL. Sessions, grants, verification and compatibility modes are only
+// supported for MySQL.
+func (d *Dumpster) isMySQL() bool {
+	return d.dialect.Name() == DialectMySQL
 }
@@ -0,0 +1,417 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// mysqlErrNoSuchTable is the MySQL error number for a table that does not exist.
+	mysqlErrNoSuchTable = 1146
+
+	// mysqlErrUnknownSystemVariable is the MySQL error number for an unknown system variable.
+	mysqlErrUnknownSystemVariable = 1193
+
+	// mysqlErrParse is the MySQL error number for a statement the server cannot parse.
+	mysqlErrParse = 1064
+)
+
+// Account is a user or role with privileges on the dumped schema.
+type Account struct {
+	// User is the user or role name.
+	User string
+
+	// Host is the host part of the account name.
+	Host string
+
+	// IsRole is whether the account is a role.
+	IsRole bool
+
+	// CreateSQL is the CREATE USER or CREATE ROLE statement, without a trailing semicolon.
+	CreateSQL string
+
+	// Grants are the GRANT statements of the account, without trailing semicolons.
+	Grants []string
+}
+
+// roleEdge is the grant of a role to a user or another role.
+type roleEdge struct {
+	roleUser, roleHost     string
+	memberUser, memberHost string
+	adminOption            bool
+}
+
+// role returns the quoted name of the role.
+func (e *roleEdge) role() string {
+	return accountName(e.roleUser, e.roleHost)
+}
+
+// member returns the quoted name of the account the role is granted to.
+func (e *roleEdge) member() string {
+	return accountName(e.memberUser, e.memberHost)
+}
+
+// grantSQL returns the statement that grants the role, without a trailing semicolon.
+func (e *roleEdge) grantSQL() string {
+	s := "GRANT " + e.role() + " TO " + e.member()
+	if e.adminOption {
+		s += " WITH ADMIN OPTION"
+	}
+
+	return s
+}
+
+// getAccounts returns the users and roles that have privileges on the schema, with the SQL to create them and their
+// grants. An account has privileges on the schema if it has privileges on the schema or its objects, has global
+// privileges, or has been granted a role that does. The reserved mysql.* accounts are left out. Roles are returned
+// first as they must exist before they can be granted to users.
+//
+// Only the grants on the schema and global grants are kept, so that restoring the dump does not grant privileges on
+// other schemas, and roles are only granted if they are part of the dump.
+func (d *Dumpster) getAccounts(ctx context.Context, schema string) ([]*Account, error) {
+	// The transaction of a snapshot is on a single connection, and reads the grants as of the snapshot.
+	if d.tx != nil {
+		return readAccounts(ctx, d.tx, schema)
+	}
+
+	// Use a single connection so the session variables apply to every statement.
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting connection: %w", err)
+	}
+
+	defer func(conn *sql.Conn) {
+		if err := conn.Close(); err != nil {
+			slog.Warn("Error closing connection", slog.String(logging.KeyError, err.Error()))
+		}
+	}(conn)
+
+	return readAccounts(ctx, conn, schema)
+}
+
+// sqlConn is a single connection to the database, such as a connection of the pool or a transaction.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readAccounts reads the accounts of getAccounts on the connection.
+func readAccounts(ctx context.Context, conn sqlConn, schema string) ([]*Account, error) {
+	// Print the password hashes as hex so that binary hashes survive being written to a text file. This is not
+	// supported before MySQL 8.0.17, where the hashes are printable anyway.
+	if _, err := conn.ExecContext(ctx, "SET SESSION print_identified_with_as_hex = ON"); err != nil &&
+		!isMySQLError(err, mysqlErrUnknownSystemVariable) {
+		return nil, fmt.Errorf("error setting print_identified_with_as_hex: %w", err)
+	}
+
+	accounts, err := queryAccounts(ctx, conn, `SELECT User, Host FROM mysql.db WHERE ? LIKE Db
+UNION SELECT User, Host FROM mysql.tables_priv WHERE Db = ?
+UNION SELECT User, Host FROM mysql.columns_priv WHERE Db = ?
+UNION SELECT User, Host FROM mysql.procs_priv WHERE Db = ?
+UNION SELECT User, Host FROM mysql.user WHERE User NOT LIKE 'mysql.%' AND 'Y' IN (Select_priv, Insert_priv,
+  Update_priv, Delete_priv, Create_priv, Drop_priv, References_priv, Index_priv, Alter_priv, Create_tmp_table_priv,
+  Lock_tables_priv, Execute_priv, Create_view_priv, Show_view_priv, Create_routine_priv, Alter_routine_priv, Event_priv,
+  Trigger_priv)
+ORDER BY User, Host`, schema, schema, schema, schema)
+	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %w", err)
+	}
+
+	// Roles are only available from MySQL 8.0.
+	edges, err := queryRoleEdges(ctx, conn)
+	if isMySQLError(err, mysqlErrNoSuchTable) {
+		edges = make([]*roleEdge, 0)
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting roles: %w", err)
+	}
+
+	accounts, edges = withRoleMembers(accounts, edges)
+
+	isRole := make(map[string]bool, len(edges))
+	for _, e := range edges {
+		isRole[e.role()] = true
+	}
+
+	// Sort roles before users, keeping the order by name.
+	ordered := make([]*Account, 0, len(accounts))
+	for _, a := range accounts {
+		if isRole[accountName(a.User, a.Host)] {
+			a.IsRole = true
+			ordered = append(ordered, a)
+		}
+	}
+	for _, a := range accounts {
+		if !a.IsRole {
+			ordered = append(ordered, a)
+		}
+	}
+
+	for _, a := range ordered {
+		name := accountName(a.User, a.Host)
+
+		if a.IsRole {
+			a.CreateSQL = "CREATE ROLE IF NOT EXISTS " + name
+		} else {
+			var createSQL string
+			if err := conn.QueryRowContext(ctx, "SHOW CREATE USER "+name).Scan(&createSQL); err != nil {
+				return nil, fmt.Errorf("error getting create user for %s: %w", name, err)
+			}
+
+			a.CreateSQL = strings.Replace(createSQL, "CREATE USER ", "CREATE USER IF NOT EXISTS ", 1)
+		}
+
+		grants, err := showGrants(ctx, conn, name)
+		if err != nil {
+			return nil, fmt.Errorf("error getting grants for %s: %w", name, err)
+		}
+
+		a.Grants = schemaGrants(grants, schema)
+		for _, e := range edges {
+			if e.member() == name {
+				a.Grants = append(a.Grants, e.grantSQL())
+			}
+		}
+	}
+
+	return ordered, nil
+}
+
+// queryRoleEdges returns the grants of roles to users and roles.
+func queryRoleEdges(ctx context.Context, conn sqlConn) ([]*roleEdge, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT FROM_USER, FROM_HOST, TO_USER, TO_HOST, WITH_ADMIN_OPTION = 'Y'
+FROM mysql.role_edges
+ORDER BY FROM_USER, FROM_HOST, TO_USER, TO_HOST`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	edges := make([]*roleEdge, 0)
+	for rows.Next() {
+		e := new(roleEdge)
+		if err := rows.Scan(&e.roleUser, &e.roleHost, &e.memberUser, &e.memberHost, &e.adminOption); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		edges = append(edges, e)
+	}
+
+	return edges, rows.Err()
+}
+
+// withRoleMembers adds the members of the roles among the accounts to the accounts, and the members of their roles in
+// turn, as they have the privileges of the roles. It returns the accounts and the grants of roles among them.
+func withRoleMembers(accounts []*Account, edges []*roleEdge) ([]*Account, []*roleEdge) {
+	included := make(map[string]bool, len(accounts))
+	for _, a := range accounts {
+		included[accountName(a.User, a.Host)] = true
+	}
+
+	for added := true; added; {
+		added = false
+		for _, e := range edges {
+			if included[e.role()] && !included[e.member()] {
+				included[e.member()] = true
+				accounts = append(accounts, &Account{User: e.memberUser, Host: e.memberHost})
+				added = true
+			}
+		}
+	}
+
+	kept := make([]*roleEdge, 0, len(edges))
+	for _, e := range edges {
+		if included[e.role()] {
+			kept = append(kept, e)
+		}
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		if accounts[i].User != accounts[j].User {
+			return accounts[i].User < accounts[j].User
+		}
+		return accounts[i].Host < accounts[j].Host
+	})
+
+	return accounts, kept
+}
+
+// queryAccounts returns the accounts from a query that selects the user and host.
+func queryAccounts(ctx context.Context, conn sqlConn, query string, args ...any) ([]*Account, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	accounts := make([]*Account, 0)
+	for rows.Next() {
+		a := new(Account)
+		if err := rows.Scan(&a.User, &a.Host); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+// showGrants returns the grant statements for the account.
+func showGrants(ctx context.Context, conn sqlConn, name string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SHOW GRANTS FOR "+name)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	grants := make([]string, 0)
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// schemaGrants returns the privilege grants on the schema, its objects or every schema. Grants on other schemas, proxy
+// grants and role grants are left out; the roles are granted from mysql.role_edges instead. Grants on a database
+// pattern, such as `app\_%`.*, are kept if the pattern matches the schema.
+func schemaGrants(grants []string, schema string) []string {
+	kept := make([]string, 0, len(grants))
+	for _, g := range grants {
+		target, ok := grantTarget(g)
+		if !ok {
+			continue
+		}
+
+		// Routine grants name the type of the object.
+		target = strings.TrimPrefix(target, "PROCEDURE ")
+		target = strings.TrimPrefix(target, "FUNCTION ")
+		target = strings.TrimPrefix(target, "TABLE ")
+
+		if strings.HasPrefix(target, "*.") {
+			kept = append(kept, g)
+			continue
+		}
+
+		db, ok := leadingIdentifier(target)
+		if ok && (db == schema || matchLike(db, schema)) {
+			kept = append(kept, g)
+		}
+	}
+
+	return kept
+}
+
+// grantTarget returns the object of a GRANT ... ON <object> TO statement, or false if the grant has no object, as
+// role grants do. Quoted identifiers are skipped, as column names can contain " ON ".
+func grantTarget(grant string) (string, bool) {
+	on := sqlparse.IndexOutsideQuotes(grant, " ON ")
+	if on < 0 {
+		return "", false
+	}
+
+	target := grant[on+len(" ON "):]
+	to := sqlparse.IndexOutsideQuotes(target, " TO ")
+	if to < 0 {
+		return "", false
+	}
+
+	return target[:to], true
+}
+
+// leadingIdentifier returns the backquoted identifier at the start of s, unquoted, or false if s does not start with
+// one.
+func leadingIdentifier(s string) (string, bool) {
+	if !strings.HasPrefix(s, "`") {
+		return "", false
+	}
+
+	b := new(strings.Builder)
+	for i := 1; i < len(s); i++ {
+		if s[i] != '`' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '`' {
+			b.WriteByte('`')
+			i++
+			continue
+		}
+
+		return b.String(), true
+	}
+
+	return "", false
+}
+
+// matchLike reports whether s matches the LIKE pattern, where % matches any characters, _ a single character and a
+// backslash escapes the next character.
+func matchLike(pattern, s string) bool {
+	re := new(strings.Builder)
+	re.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case c == '%':
+			re.WriteString("(?s:.*)")
+		case c == '_':
+			re.WriteString("(?s:.)")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	return regexp.MustCompile(re.String()).MatchString(s)
+}
+
+// accountName returns the quoted 'user'@'host' name of an account.
+func accountName(user, host string) string {
+	return quoteString(user) + "@" + quoteString(host)
+}
+
+// quoteString quotes a MySQL string literal with single quotes.
+func quoteString(s string) string {
+	return "'" + escapeString(s) + "'"
+}
+
+// isMySQLError reports whether err is a MySQL error with the given number.
+func isMySQLError(err error, number uint16) bool {
+	mysqlErr := new(mysql.MySQLError)
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
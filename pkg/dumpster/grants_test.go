@@ -0,0 +1,90 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountName(t *testing.T) {
+	require.Equal(t, "'app'@'%'", accountName("app", "%"))
+	require.Equal(t, `'o''brien'@'10.0.0.\\1'`, accountName("o'brien", `10.0.0.\1`))
+}
+
+func TestTemplate_Accounts(t *testing.T) {
+	data := &TemplateData{
+		Database: "test",
+		Accounts: []*Account{
+			{
+				User:      "app_read",
+				Host:      "%",
+				IsRole:    true,
+				CreateSQL: "CREATE ROLE IF NOT EXISTS 'app_read'@'%'",
+				Grants:    []string{"GRANT SELECT ON `test`.* TO `app_read`@`%`"},
+			},
+			{
+				User:      "app",
+				Host:      "%",
+				CreateSQL: "CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24",
+				Grants: []string{
+					"GRANT USAGE ON *.* TO `app`@`%`",
+					"GRANT `app_read`@`%` TO `app`@`%`",
+				},
+			},
+		},
+	}
+
+	got, err := renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+	require.Contains(t, got, "-- Users, roles and grants\n"+
+		"CREATE ROLE IF NOT EXISTS 'app_read'@'%';\n"+
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24;\n"+
+		"GRANT SELECT ON `test`.* TO `app_read`@`%`;\n"+
+		"GRANT USAGE ON *.* TO `app`@`%`;\n"+
+		"GRANT `app_read`@`%` TO `app`@`%`;\n")
+}
+
+func TestSchemaGrants(t *testing.T) {
+	grants := []string{
+		"GRANT USAGE ON *.* TO `app`@`%`",
+		"GRANT SELECT, INSERT ON `test`.* TO `app`@`%`",
+		"GRANT SELECT ON `other`.* TO `app`@`%`",
+		"GRANT SELECT (`a ON b`) ON `test`.`users` TO `app`@`%`",
+		"GRANT SELECT ON `other`.`test` TO `app`@`%`",
+		"GRANT EXECUTE ON PROCEDURE `test`.`cleanup` TO `app`@`%`",
+		"GRANT EXECUTE ON FUNCTION `other`.`f` TO `app`@`%`",
+		"GRANT SELECT ON `te%`.* TO `app`@`%`",
+		"GRANT SELECT ON `tes\\_%`.* TO `app`@`%`",
+		"GRANT PROXY ON ``@`` TO `app`@`%`",
+		"GRANT `app_read`@`%` TO `app`@`%`",
+	}
+
+	require.Equal(t, []string{
+		"GRANT USAGE ON *.* TO `app`@`%`",
+		"GRANT SELECT, INSERT ON `test`.* TO `app`@`%`",
+		"GRANT SELECT (`a ON b`) ON `test`.`users` TO `app`@`%`",
+		"GRANT EXECUTE ON PROCEDURE `test`.`cleanup` TO `app`@`%`",
+		"GRANT SELECT ON `te%`.* TO `app`@`%`",
+	}, schemaGrants(grants, "test"))
+}
+
+func TestWithRoleMembers(t *testing.T) {
+	accounts := []*Account{{User: "reader", Host: "%"}, {User: "zed", Host: "%"}}
+	edges := []*roleEdge{
+		{roleUser: "reader", roleHost: "%", memberUser: "analysts", memberHost: "%"},
+		{roleUser: "analysts", roleHost: "%", memberUser: "alice", memberHost: "%", adminOption: true},
+		{roleUser: "writer", roleHost: "%", memberUser: "bob", memberHost: "%"},
+		{roleUser: "writer", roleHost: "%", memberUser: "alice", memberHost: "%"},
+	}
+
+	// Members of the roles are added, transitively, and only the grants of included roles are kept.
+	got, kept := withRoleMembers(accounts, edges)
+	names := make([]string, 0, len(got))
+	for _, a := range got {
+		names = append(names, a.User)
+	}
+
+	require.Equal(t, []string{"alice", "analysts", "reader", "zed"}, names)
+	require.Equal(t, edges[:2], kept)
+	require.Equal(t, "GRANT 'analysts'@'%' TO 'alice'@'%' WITH ADMIN OPTION", kept[1].grantSQL())
+}
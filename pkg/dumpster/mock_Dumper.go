@@ -0,0 +1,217 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package dumpster
+
+import (
+	context "context"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockDumper is an autogenerated mock type for the Dumper type
+type MockDumper struct {
+	mock.Mock
+}
+
+// Dump provides a mock function with given fields: ctx
+func (_m *MockDumper) Dump(ctx context.Context) (string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dump")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DumpFile provides a mock function with given fields: ctx
+func (_m *MockDumper) DumpFile(ctx context.Context) (string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DumpFile")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DumpTo provides a mock function with given fields: ctx, w
+func (_m *MockDumper) DumpTo(ctx context.Context, w io.Writer) error {
+	ret := _m.Called(ctx, w)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DumpTo")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, io.Writer) error); ok {
+		r0 = rf(ctx, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDDL provides a mock function with given fields: ctx
+func (_m *MockDumper) GetDDL(ctx context.Context) (string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDDL")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNormalizedDDL provides a mock function with given fields: ctx, stripDefiners
+func (_m *MockDumper) GetNormalizedDDL(ctx context.Context, stripDefiners bool) (string, error) {
+	ret := _m.Called(ctx, stripDefiners)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNormalizedDDL")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) (string, error)); ok {
+		return rf(ctx, stripDefiners)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, bool) string); ok {
+		r0 = rf(ctx, stripDefiners)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(ctx, stripDefiners)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSchemaName provides a mock function with given fields: ctx
+func (_m *MockDumper) GetSchemaName(ctx context.Context) (string, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSchemaName")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSplitDDL provides a mock function with given fields: ctx, normalize, stripDefiners
+func (_m *MockDumper) GetSplitDDL(ctx context.Context, normalize bool, stripDefiners bool) ([]*DDLFile, error) {
+	ret := _m.Called(ctx, normalize, stripDefiners)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSplitDDL")
+	}
+
+	var r0 []*DDLFile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool, bool) ([]*DDLFile, error)); ok {
+		return rf(ctx, normalize, stripDefiners)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, bool, bool) []*DDLFile); ok {
+		r0 = rf(ctx, normalize, stripDefiners)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*DDLFile)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, bool, bool) error); ok {
+		r1 = rf(ctx, normalize, stripDefiners)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewMockDumper creates a new instance of MockDumper. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDumper(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDumper {
+	mock := &MockDumper{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,42 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package dumpster
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockOption is an autogenerated mock type for the Option type
+type MockOption struct {
+	mock.Mock
+}
+
+// Execute provides a mock function with given fields: d
+func (_m *MockOption) Execute(d *Dumpster) error {
+	ret := _m.Called(d)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*Dumpster) error); ok {
+		r0 = rf(d)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewMockOption creates a new instance of MockOption. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockOption(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockOption {
+	mock := &MockOption{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,142 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package dumpster
+
+import (
+	context "context"
+	sql "database/sql"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockQueryer is an autogenerated mock type for the Queryer type
+type MockQueryer struct {
+	mock.Mock
+}
+
+// DriverName provides a mock function with given fields:
+func (_m *MockQueryer) DriverName() string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for DriverName")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// GetContext provides a mock function with given fields: ctx, dest, query, args
+func (_m *MockQueryer) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, dest, query)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetContext")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, any, string, ...any) error); ok {
+		r0 = rf(ctx, dest, query, args...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PrepareContext provides a mock function with given fields: ctx, query
+func (_m *MockQueryer) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PrepareContext")
+	}
+
+	var r0 *sql.Stmt
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*sql.Stmt, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *sql.Stmt); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*sql.Stmt)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryRowContext provides a mock function with given fields: ctx, query, args
+func (_m *MockQueryer) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, query)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for QueryRowContext")
+	}
+
+	var r0 *sql.Row
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...any) *sql.Row); ok {
+		r0 = rf(ctx, query, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*sql.Row)
+		}
+	}
+
+	return r0
+}
+
+// SelectContext provides a mock function with given fields: ctx, dest, query, args
+func (_m *MockQueryer) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, dest, query)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SelectContext")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, any, string, ...any) error); ok {
+		r0 = rf(ctx, dest, query, args...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewMockQueryer creates a new instance of MockQueryer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockQueryer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockQueryer {
+	mock := &MockQueryer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
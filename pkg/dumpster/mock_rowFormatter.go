@@ -0,0 +1,66 @@
+// Code generated by mockery. DO NOT EDIT.
+
+package dumpster
+
+import (
+	sql "database/sql"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// mockRowFormatter is an autogenerated mock type for the rowFormatter type
+type mockRowFormatter struct {
+	mock.Mock
+}
+
+// formatRow provides a mock function with given fields: values
+func (_m *mockRowFormatter) formatRow(values []sql.NullString) string {
+	ret := _m.Called(values)
+
+	if len(ret) == 0 {
+		panic("no return value specified for formatRow")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func([]sql.NullString) string); ok {
+		r0 = rf(values)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// insertColumns provides a mock function with given fields: columns
+func (_m *mockRowFormatter) insertColumns(columns []string) []string {
+	ret := _m.Called(columns)
+
+	if len(ret) == 0 {
+		panic("no return value specified for insertColumns")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func([]string) []string); ok {
+		r0 = rf(columns)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// newMockRowFormatter creates a new instance of mockRowFormatter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func newMockRowFormatter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *mockRowFormatter {
+	mock := &mockRowFormatter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
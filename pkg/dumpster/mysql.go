@@ -0,0 +1,349 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+// mysqlDialect is the Dialect for MySQL and MariaDB.
+type mysqlDialect struct {
+	db Queryer
+}
+
+// NewMySQLDialect returns the Dialect for MySQL and MariaDB.
+func NewMySQLDialect(db Queryer) Dialect {
+	return &mysqlDialect{
+		db: db,
+	}
+}
+
+func (m *mysqlDialect) Name() string {
+	return DialectMySQL
+}
+
+func (m *mysqlDialect) SchemaName(ctx context.Context) (string, error) {
+	sqlStmt := "SELECT DATABASE()"
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	var schema sql.NullString
+	if err := stmt.QueryRowContext(ctx).Scan(&schema); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if !schema.Valid {
+		return "", errors.New("returned schema is not valid")
+	}
+
+	return schema.String, nil
+}
+
+func (m *mysqlDialect) ServerVersion(ctx context.Context) (string, error) {
+	sqlStmt := "SELECT version()"
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	version := ""
+
+	// Execute statement
+	if err := stmt.QueryRowContext(ctx).Scan(&version); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if version == "" {
+		return "", errors.New("returned version is empty")
+	}
+
+	return version, nil
+}
+
+func (m *mysqlDialect) Tables(ctx context.Context) ([]string, error) {
+	return m.tablesOfType(ctx, "BASE TABLE")
+}
+
+func (m *mysqlDialect) TableSQL(ctx context.Context, name string) (string, error) {
+	sqlStmt := "SHOW CREATE TABLE " + name
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	var tableReturn sql.NullString
+	var tableSql sql.NullString
+	if err := stmt.QueryRowContext(ctx).Scan(&tableReturn, &tableSql); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if tableReturn.String != name {
+		return "", errors.New("returned table is not the same as requested table")
+	}
+
+	if !tableSql.Valid {
+		return "", errors.New("returned table SQL is not valid")
+	}
+
+	return tableSql.String, nil
+}
+
+func (m *mysqlDialect) TableConstraintsSQL(context.Context, string) (string, error) {
+	// Foreign key checks are disabled while a dump is restored, so the table definition is complete.
+	return "", nil
+}
+
+func (m *mysqlDialect) TableEstimates(ctx context.Context) ([]*TableEstimate, error) {
+	sqlStmt := "SELECT TABLE_NAME AS name, COALESCE(TABLE_ROWS, 0) AS table_rows, COALESCE(DATA_LENGTH, 0) AS data_length " +
+		"FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'"
+
+	estimates := make([]*TableEstimate, 0)
+	if err := m.db.SelectContext(ctx, &estimates, sqlStmt); err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return estimates, nil
+}
+
+func (m *mysqlDialect) KeyColumns(ctx context.Context, name string) ([]string, error) {
+	key := make([]string, 0)
+	err := m.db.SelectContext(ctx, &key, "SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (m *mysqlDialect) ReadRows(ctx context.Context, name string, r *RowRange, fn func(values []sql.NullString) error) ([]string, error) {
+	return readRows(ctx, m.db, "SELECT * FROM "+m.QuoteIdentifier(name)+r.clause(m), fn)
+}
+
+func (m *mysqlDialect) QuoteIdentifier(name string) string {
+	return sqlparse.QuoteIdentifier(name)
+}
+
+func (m *mysqlDialect) QuoteValue(value sql.NullString) string {
+	if !value.Valid {
+		return "null"
+	}
+
+	return quoteString(value.String)
+}
+
+func (m *mysqlDialect) Routines(ctx context.Context) ([]*Routine, error) {
+	return m.getRoutinesWithSQL(ctx)
+}
+
+// SchemaObjects returns no objects, as MySQL types are declared in the columns that use them.
+func (m *mysqlDialect) SchemaObjects(ctx context.Context) ([]*SchemaObject, error) {
+	return make([]*SchemaObject, 0), nil
+}
+
+func (m *mysqlDialect) Triggers(ctx context.Context) ([]string, error) {
+	sqlStmt := "SHOW TRIGGERS"
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	// Read data
+	triggers := make([]string, 0)
+	for rows.Next() {
+		t := new(sql.NullString)
+		event := new(sql.NullString)
+		sqlTable := new(sql.NullString)
+		statement := new(sql.NullString)
+		timing := new(sql.NullString)
+		created := new(sql.NullString)
+		sqlMode := new(sql.NullString)
+		definer := new(sql.NullString)
+		characterSetClient := new(sql.NullString)
+		collationConnection := new(sql.NullString)
+		databaseCollation := new(sql.NullString)
+
+		if err := rows.Scan(t, event, sqlTable, statement, timing, created, sqlMode, definer,
+			characterSetClient, collationConnection, databaseCollation); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		if t.Valid {
+			triggers = append(triggers, t.String)
+		} else {
+			slog.Warn("trigger is not valid", slog.String("trigger", t.String))
+		}
+	}
+
+	return triggers, nil
+}
+
+func (m *mysqlDialect) TriggerSQL(ctx context.Context, name string) (string, error) {
+	sqlStmt := "SHOW CREATE TRIGGER " + name
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	triggerName := new(sql.NullString)
+	sqlMode := new(sql.NullString)
+	originalStatement := new(sql.NullString)
+	characterSetClient := new(sql.NullString)
+	collationConnection := new(sql.NullString)
+	databaseCollation := new(sql.NullString)
+	createdAt := new(sql.NullString)
+
+	if err := stmt.QueryRowContext(ctx).Scan(triggerName, sqlMode, originalStatement, characterSetClient,
+		collationConnection, databaseCollation, createdAt); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if originalStatement.Valid {
+		return originalStatement.String, nil
+	} else {
+		return "", fmt.Errorf("error getting trigger SQL: %w", err)
+	}
+}
+
+func (m *mysqlDialect) Views(ctx context.Context) ([]string, error) {
+	return m.tablesOfType(ctx, "VIEW")
+}
+
+func (m *mysqlDialect) ViewSQL(ctx context.Context, name string) (string, error) {
+	sqlStmt := "SHOW CREATE VIEW " + name
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	viewName := new(sql.NullString)
+	viewSQL := new(sql.NullString)
+	characterSetClient := new(sql.NullString)
+	collationConnection := new(sql.NullString)
+
+	if err := stmt.QueryRowContext(ctx).Scan(viewName, viewSQL, characterSetClient, collationConnection); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if !viewSQL.Valid {
+		return "", errors.New("returned view SQL is not valid")
+	}
+
+	return viewSQL.String, nil
+}
+
+func (m *mysqlDialect) tablesOfType(ctx context.Context, tableType string) ([]string, error) {
+	sqlStmt := "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = ? ORDER BY TABLE_NAME"
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	rows, err := stmt.QueryContext(ctx, tableType)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	// Read data
+	tables := make([]string, 0)
+	for rows.Next() {
+		var t sql.NullString
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		if t.Valid {
+			tables = append(tables, t.String)
+		} else {
+			slog.Warn("table is not valid", slog.String("table", t.String))
+		}
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,85 @@
+package dumpster
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+var (
+	// autoIncrementRegex matches the AUTO_INCREMENT table option from SHOW CREATE TABLE.
+	autoIncrementRegex = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
+
+	// blankLinesRegex matches two or more consecutive blank lines.
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalizeDDL removes volatile attributes from the DDL and sorts the objects by name.
+func normalizeDDL(data *TemplateData, stripDefiners bool) {
+	// The server version and timings change between runs without the schema changing.
+	data.ServerVersion = ""
+	data.StartTime = ""
+	data.started = time.Time{}
+	data.completed = time.Time{}
+
+	data.Options.Normalized = true
+	data.Options.StripDefiners = stripDefiners
+
+	for _, t := range data.Tables {
+		t.SQL = autoIncrementRegex.ReplaceAllString(t.SQL, "")
+	}
+
+	if stripDefiners {
+		for _, t := range data.Triggers {
+			t.SQL = sqlparse.StripDefiner(t.SQL)
+		}
+
+		for _, v := range data.Views {
+			v.SQL = sqlparse.StripDefiner(v.SQL)
+		}
+
+		for _, r := range data.Routines {
+			r.SQL = sqlparse.StripDefiner(r.SQL)
+		}
+	}
+
+	sort.SliceStable(data.Tables, func(i, j int) bool {
+		return data.Tables[i].Name < data.Tables[j].Name
+	})
+
+	sort.SliceStable(data.Triggers, func(i, j int) bool {
+		return data.Triggers[i].Name < data.Triggers[j].Name
+	})
+
+	// PostgreSQL views are already in a stable order, with every view after the views it selects from.
+	if data.Dialect != DialectPostgres {
+		sort.SliceStable(data.Views, func(i, j int) bool {
+			return data.Views[i].Name < data.Views[j].Name
+		})
+	}
+
+	sort.SliceStable(data.Routines, func(i, j int) bool {
+		if data.Routines[i].Type != data.Routines[j].Type {
+			return data.Routines[i].Type < data.Routines[j].Type
+		}
+		return data.Routines[i].Name < data.Routines[j].Name
+	})
+}
+
+// normalizeWhitespace standardizes line endings, removes trailing whitespace and collapses blank lines.
+func normalizeWhitespace(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+
+	s = strings.Join(lines, "\n")
+	s = blankLinesRegex.ReplaceAllString(s, "\n\n")
+
+	return strings.TrimSpace(s) + "\n"
+}
@@ -0,0 +1,52 @@
+package dumpster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDDL(t *testing.T) {
+	data := &TemplateData{
+		Database:      "test",
+		ServerVersion: "8.0.35",
+		Tables: []*Table{
+			{Name: "users", SQL: "CREATE TABLE `users` (\n  `id` int NOT NULL AUTO_INCREMENT\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"},
+			{Name: "accounts", SQL: "CREATE TABLE `accounts` (\n  `id` int NOT NULL\n) ENGINE=InnoDB"},
+		},
+		Triggers: []*Trigger{
+			{Name: "trg_b", SQL: "CREATE DEFINER=`root`@`%` TRIGGER `trg_b` BEFORE INSERT ON `users` FOR EACH ROW SET NEW.id = 1"},
+			{Name: "trg_a", SQL: "CREATE DEFINER=`root`@`%` TRIGGER `trg_a` BEFORE INSERT ON `accounts` FOR EACH ROW SET NEW.id = 1"},
+		},
+		Views: []*View{
+			{Name: "v_users", SQL: "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v_users` AS select 1 AS `1`"},
+		},
+		StartTime: "2026-10-14T03:00:00Z",
+		started:   time.Now(),
+	}
+
+	normalizeDDL(data, true)
+
+	require.Empty(t, data.ServerVersion)
+	require.Empty(t, data.StartTime)
+	require.Empty(t, data.CompleteTime())
+
+	require.Equal(t, "accounts", data.Tables[0].Name)
+	require.Equal(t, "users", data.Tables[1].Name)
+	require.Equal(t, "CREATE TABLE `users` (\n  `id` int NOT NULL AUTO_INCREMENT\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", data.Tables[1].SQL)
+
+	require.Equal(t, "trg_a", data.Triggers[0].Name)
+	require.Equal(t, "CREATE TRIGGER `trg_a` BEFORE INSERT ON `accounts` FOR EACH ROW SET NEW.id = 1", data.Triggers[0].SQL)
+	require.Equal(t, "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v_users` AS select 1 AS `1`", data.Views[0].SQL)
+
+	got, err := renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+	require.NotContains(t, got, "Server version")
+	require.NotContains(t, got, "Dump completed")
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	got := normalizeWhitespace("\r\nCREATE TABLE `a` (  \r\n  `id` int\t\r\n)\n\n\n\n;\n\n")
+	require.Equal(t, "CREATE TABLE `a` (\n  `id` int\n)\n\n;\n", got)
+}
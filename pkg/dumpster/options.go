@@ -0,0 +1,247 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"text/template"
+)
+
+// Compression is the compression applied to dumps written by DumpTo and DumpFile.
+type Compression string
+
+const (
+	// CompressionNone writes dumps uncompressed.
+	CompressionNone Compression = ""
+
+	// CompressionGzip writes dumps with gzip.
+	CompressionGzip Compression = "gzip"
+)
+
+// Extension returns the file extension for the compression, e.g. .gz.
+func (c Compression) Extension() string {
+	if c == CompressionGzip {
+		return ".gz"
+	}
+
+	return ""
+}
+
+// Hooks are called while a dump or DDL is created. A hook that returns an error stops the run with that error. Unset
+// hooks are skipped.
+type Hooks struct {
+	// BeforeTable is called with the name of each table before it is read.
+	BeforeTable func(name string) error
+
+	// AfterTable is called with each table once its definition has been read. The table can be changed, for example
+	// to rewrite the definition. The rows of a dump are read as it is rendered, after every AfterTable call.
+	AfterTable func(t *Table) error
+
+	// Row is called with the columns and the values of each row of a dump as it is read. The values can be changed,
+	// for example to mask them, and the checksum of the table is of the rows as they are written. SQLite values are
+	// read as SQL literals. It is called from the goroutine reading the rows, one call at a time.
+	Row func(table string, columns []string, values []sql.NullString) error
+
+	// BeforeRender is called with the data of the dump or DDL before it is rendered. The data can be changed.
+	BeforeRender func(data *TemplateData) error
+}
+
+// Option configures a Dumpster.
+type Option func(d *Dumpster) error
+
+// WithGrants sets whether the dump includes the users and roles with privileges on the schema, along with their
+// grants. This is only supported for MySQL.
+func WithGrants(include bool) Option {
+	return func(d *Dumpster) error {
+		d.includeGrants = include
+		return nil
+	}
+}
+
+// WithCompat sets the server the dump and DDL are made compatible with. Table definitions and collations are rewritten
+// into a form the target server accepts, and a warning is logged for any construct that cannot be translated. Views,
+// triggers and routines only have their collations rewritten, and keep their definers.
+func WithCompat(compat Compat) Option {
+	return func(d *Dumpster) error {
+		d.compat = compat
+		return nil
+	}
+}
+
+// WithTemplate sets the template used to render dumps and DDL. A nil template uses the default template of the
+// dialect, DefaultTemplate, DefaultPostgresTemplate or DefaultSQLiteTemplate. The template is executed with a
+// *TemplateData and should be parsed with ParseTemplate so the helper functions are available.
+func WithTemplate(t *template.Template) Option {
+	return func(d *Dumpster) error {
+		d.template = t
+		return nil
+	}
+}
+
+// WithTargetDialect sets the dialect the dump and DDL are written in, when it differs from the database being dumped.
+// Only MySQL databases can be converted, and only to PostgreSQL. An empty name writes the dialect of the database.
+func WithTargetDialect(name string) Option {
+	return func(d *Dumpster) error {
+		switch name {
+		case "", d.dialect.Name():
+			d.target = ""
+			return nil
+		case DialectPostgres:
+			if !d.isMySQL() {
+				return fmt.Errorf("conversion from %s to %s is not supported", d.dialect.Name(), name)
+			}
+
+			d.target = name
+			return nil
+		default:
+			return fmt.Errorf("unsupported target dialect: %s", name)
+		}
+	}
+}
+
+// WithTables limits the dump and DDL to the tables whose names match one of the patterns, in the syntax of
+// path.Match. Triggers on other tables are left out as well. Every table is included if no patterns are given.
+func WithTables(patterns ...string) Option {
+	return func(d *Dumpster) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		d.includeTables = patterns
+		return nil
+	}
+}
+
+// WithExcludeTables leaves the tables whose names match one of the patterns, in the syntax of path.Match, out of the
+// dump and DDL. Exclusions take precedence over WithTables.
+func WithExcludeTables(patterns ...string) Option {
+	return func(d *Dumpster) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		d.excludeTables = patterns
+		return nil
+	}
+}
+
+// WithExcludeData dumps the definition but not the rows of the tables whose names match one of the patterns, in the
+// syntax of path.Match. This is useful for large tables of logs or sessions.
+func WithExcludeData(patterns ...string) Option {
+	return func(d *Dumpster) error {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+
+		d.excludeData = patterns
+		return nil
+	}
+}
+
+// WithBatchSize splits the rows of each table into INSERT statements of at most the given number of rows, so that
+// they stay below the max_allowed_packet of the server on restore. Zero writes a single statement per table.
+func WithBatchSize(rows int) Option {
+	return func(d *Dumpster) error {
+		if rows < 0 {
+			return errors.New("batch size must not be negative")
+		}
+
+		d.batchSize = rows
+		return nil
+	}
+}
+
+// WithChunkSize sets the number of rows read from a table with a primary key in each query. The throttle only waits
+// between queries, so smaller chunks spread the reads more evenly at the cost of more queries. Zero uses the default
+// of 10000 rows.
+func WithChunkSize(rows int) Option {
+	return func(d *Dumpster) error {
+		if rows < 0 {
+			return errors.New("chunk size must not be negative")
+		}
+
+		d.chunkSize = rows
+		return nil
+	}
+}
+
+// WithSnapshot sets whether the dump is read in a single read-only transaction, so that the dump is a consistent
+// snapshot of the database even while it is written to. The schema, the rows, the session and the grants are all read
+// in the transaction. This requires a transactional storage engine such as InnoDB.
+func WithSnapshot(enabled bool) Option {
+	return func(d *Dumpster) error {
+		d.snapshot = enabled
+		return nil
+	}
+}
+
+// WithCompression sets the compression of the dumps written by DumpTo and DumpFile.
+func WithCompression(compression Compression) Option {
+	return func(d *Dumpster) error {
+		switch compression {
+		case CompressionNone, CompressionGzip:
+			d.compression = compression
+			return nil
+		default:
+			return fmt.Errorf("unsupported compression: %s", compression)
+		}
+	}
+}
+
+// WithHooks sets the hooks called while a dump or DDL is created.
+func WithHooks(hooks Hooks) Option {
+	return func(d *Dumpster) error {
+		d.hooks = hooks
+		return nil
+	}
+}
+
+// validatePatterns returns an error if any of the table name patterns is malformed.
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid table pattern %q: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// matchAny reports whether the name matches any of the patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// includeTable reports whether the table is part of the dump and DDL.
+func (d *Dumpster) includeTable(name string) bool {
+	if matchAny(d.excludeTables, name) {
+		return false
+	}
+
+	return len(d.includeTables) == 0 || matchAny(d.includeTables, name)
+}
+
+// tables returns the names of the tables that are part of the dump and DDL.
+func (d *Dumpster) tables(ctx context.Context) ([]string, error) {
+	tables, err := d.dialect.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make([]string, 0, len(tables))
+	for _, t := range tables {
+		if d.includeTable(t) {
+			included = append(included, t)
+		}
+	}
+
+	return included, nil
+}
@@ -0,0 +1,138 @@
+package dumpster
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions(t *testing.T) {
+	require.Implements(t, (*Dumper)(nil), new(Dumpster))
+
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	t.Run("filters", func(t *testing.T) {
+		d, err := NewDumpster(src, WithTables("*"), WithExcludeTables("order*"), WithExcludeData("users"))
+		require.NoError(t, err)
+
+		dump, err := d.Dump(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, dump, "CREATE TABLE users")
+		require.Contains(t, dump, "users_ai")
+		require.NotContains(t, dump, "order items")
+		require.NotContains(t, dump, "INSERT INTO \"users\"")
+
+		// Triggers on excluded tables are left out.
+		d, err = NewDumpster(src, WithExcludeTables("users"))
+		require.NoError(t, err)
+
+		dump, err = d.Dump(context.Background())
+		require.NoError(t, err)
+		require.NotContains(t, dump, "users_ai")
+	})
+
+	t.Run("batches and snapshot", func(t *testing.T) {
+		d, err := NewDumpster(src, WithBatchSize(1), WithSnapshot(true))
+		require.NoError(t, err)
+
+		dump, err := d.Dump(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, dump, "INSERT INTO \"users\" VALUES (1,'alice','new');\nINSERT INTO \"users\" VALUES (2,'o''brien','new');\n")
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		d, err := NewDumpster(src, WithHooks(Hooks{
+			Row: func(table string, columns []string, values []sql.NullString) error {
+				if table == "users" {
+					values[slices.Index(columns, "name")].String = "'masked'"
+				}
+				return nil
+			},
+			BeforeRender: func(data *TemplateData) error {
+				data.Views = nil
+				return nil
+			},
+		}))
+		require.NoError(t, err)
+
+		dump, err := d.Dump(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, dump, "INSERT INTO \"users\" VALUES (1,'masked','new'),(2,'masked','new');")
+		require.NotContains(t, dump, "alice")
+		require.NotContains(t, dump, "CREATE VIEW")
+
+		hookErr := errors.New("stop")
+		d, err = NewDumpster(src, WithHooks(Hooks{
+			BeforeTable: func(string) error { return hookErr },
+		}))
+		require.NoError(t, err)
+
+		_, err = d.GetDDL(context.Background())
+		require.ErrorIs(t, err, hookErr)
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		events := make([]ProgressEvent, 0)
+		d, err := NewDumpster(src, WithExcludeData("order*"), WithProgress(func(e ProgressEvent) {
+			events = append(events, e)
+		}))
+		require.NoError(t, err)
+
+		_, err = d.Dump(context.Background())
+		require.NoError(t, err)
+
+		// The rows of the excluded table are not part of the estimate.
+		require.Equal(t, ProgressStarted, events[0].Type)
+		require.Equal(t, int64(2), events[0].EstimatedRows)
+
+		last := events[len(events)-1]
+		require.Equal(t, ProgressFinished, last.Type)
+		require.Equal(t, 2, last.TablesDone)
+		require.Equal(t, int64(2), last.Rows)
+		require.Positive(t, last.Bytes)
+
+		finished := make(map[string]int64)
+		for _, e := range events {
+			if e.Type == ProgressTableFinished {
+				finished[e.Table] = e.TableRows
+			}
+		}
+		require.Equal(t, map[string]int64{"order items": 0, "users": 2}, finished)
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		d, err := NewDumpster(src, WithCompression(CompressionGzip))
+		require.NoError(t, err)
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, d.DumpTo(context.Background(), buf))
+
+		r, err := gzip.NewReader(buf)
+		require.NoError(t, err)
+
+		dump, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Contains(t, string(dump), "CREATE TABLE users")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewDumpster(src, WithTables("["))
+		require.Error(t, err)
+
+		_, err = NewDumpster(src, WithBatchSize(-1))
+		require.Error(t, err)
+
+		_, err = NewDumpster(src, WithCompression("zip"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,567 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// nextvalRegex matches a column default that takes its value from a sequence, capturing the sequence name.
+var nextvalRegex = regexp.MustCompile(`^nextval\('((?:[^']|'')+)'::regclass\)$`)
+
+// postgresDialect is the Dialect for PostgreSQL 12 and later. The tables, views, triggers and functions of the current
+// schema of the connection are dumped.
+type postgresDialect struct {
+	db Queryer
+}
+
+// NewPostgresDialect returns the Dialect for PostgreSQL.
+func NewPostgresDialect(db Queryer) Dialect {
+	return &postgresDialect{
+		db: db,
+	}
+}
+
+// pgColumn is a column of a PostgreSQL table.
+type pgColumn struct {
+	Name      string         `db:"name"`
+	Type      string         `db:"type"`
+	NotNull   bool           `db:"not_null"`
+	Default   sql.NullString `db:"default"`
+	Identity  string         `db:"identity"`
+	Generated string         `db:"generated"`
+	Sequence  sql.NullString `db:"sequence"`
+}
+
+// pgConstraint is a constraint of a PostgreSQL table.
+type pgConstraint struct {
+	Name       string `db:"name"`
+	Definition string `db:"definition"`
+}
+
+func (p *postgresDialect) Name() string {
+	return DialectPostgres
+}
+
+func (p *postgresDialect) SchemaName(ctx context.Context) (string, error) {
+	var name string
+	if err := p.db.GetContext(ctx, &name, "SELECT current_database()"); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return name, nil
+}
+
+func (p *postgresDialect) ServerVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := p.db.GetContext(ctx, &version, "SHOW server_version"); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if version == "" {
+		return "", errors.New("returned version is empty")
+	}
+
+	return version, nil
+}
+
+func (p *postgresDialect) Tables(ctx context.Context) ([]string, error) {
+	tables := make([]string, 0)
+	if err := p.db.SelectContext(ctx, &tables, "SELECT tablename FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename"); err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return tables, nil
+}
+
+// columns returns the columns of the table in order.
+func (p *postgresDialect) columns(ctx context.Context, name string) ([]*pgColumn, error) {
+	columns := make([]*pgColumn, 0)
+	err := p.db.SelectContext(ctx, &columns, `SELECT a.attname AS name,
+  format_type(a.atttypid, a.atttypmod) AS type,
+  a.attnotnull AS not_null,
+  pg_get_expr(d.adbin, d.adrelid) AS default,
+  a.attidentity AS identity,
+  a.attgenerated AS generated,
+  pg_get_serial_sequence($1::text, a.attname) AS sequence
+FROM pg_attribute a
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE a.attrelid = $1::text::regclass AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`, p.QuoteIdentifier(name))
+	if err != nil {
+		return nil, fmt.Errorf("error getting columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// constraints returns the constraints of the table of the given types, ordered by name.
+func (p *postgresDialect) constraints(ctx context.Context, name string, types string) ([]*pgConstraint, error) {
+	constraints := make([]*pgConstraint, 0)
+	err := p.db.SelectContext(ctx, &constraints, `SELECT conname AS name, pg_get_constraintdef(oid) AS definition
+FROM pg_constraint
+WHERE conrelid = $1::regclass AND strpos($2, contype::text) > 0
+ORDER BY contype, conname`, p.QuoteIdentifier(name), types)
+	if err != nil {
+		return nil, fmt.Errorf("error getting constraints: %w", err)
+	}
+
+	return constraints, nil
+}
+
+func (p *postgresDialect) TableSQL(ctx context.Context, name string) (string, error) {
+	columns, err := p.columns(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	// Primary key, unique and check constraints. Foreign keys are added once every table exists.
+	constraints, err := p.constraints(ctx, name, "puc")
+	if err != nil {
+		return "", err
+	}
+
+	b := new(strings.Builder)
+	definitions := make([]string, 0, len(columns)+len(constraints))
+	for _, c := range columns {
+		def := p.QuoteIdentifier(c.Name) + " " + c.Type
+		switch {
+		case c.Generated == "s":
+			def += " GENERATED ALWAYS AS (" + c.Default.String + ") STORED"
+		case c.Identity == "a":
+			def += " GENERATED ALWAYS AS IDENTITY"
+		case c.Identity == "d":
+			def += " GENERATED BY DEFAULT AS IDENTITY"
+		case c.Default.Valid:
+			// Sequences used by serial columns must exist before the table.
+			if m := nextvalRegex.FindStringSubmatch(c.Default.String); m != nil {
+				fmt.Fprintf(b, "CREATE SEQUENCE IF NOT EXISTS %s;\n", strings.ReplaceAll(m[1], "''", "'"))
+			}
+			def += " DEFAULT " + c.Default.String
+		}
+
+		if c.NotNull {
+			def += " NOT NULL"
+		}
+
+		definitions = append(definitions, def)
+	}
+
+	for _, c := range constraints {
+		definitions = append(definitions, "CONSTRAINT "+p.QuoteIdentifier(c.Name)+" "+c.Definition)
+	}
+
+	fmt.Fprintf(b, "CREATE TABLE %s (\n  %s\n)", p.QuoteIdentifier(name), strings.Join(definitions, ",\n  "))
+
+	return b.String(), nil
+}
+
+func (p *postgresDialect) TableConstraintsSQL(ctx context.Context, name string) (string, error) {
+	statements := make([]string, 0)
+
+	foreignKeys, err := p.constraints(ctx, name, "f")
+	if err != nil {
+		return "", err
+	}
+
+	for _, fk := range foreignKeys {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s",
+			p.QuoteIdentifier(name), p.QuoteIdentifier(fk.Name), fk.Definition))
+	}
+
+	// Indexes that do not back a constraint.
+	indexes := make([]string, 0)
+	err = p.db.SelectContext(ctx, &indexes, `SELECT pg_get_indexdef(i.indexrelid)
+FROM pg_index i
+WHERE i.indrelid = $1::regclass AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = i.indexrelid)
+ORDER BY i.indexrelid::regclass::text`, p.QuoteIdentifier(name))
+	if err != nil {
+		return "", fmt.Errorf("error getting indexes: %w", err)
+	}
+
+	statements = append(statements, indexes...)
+
+	// Sequences continue from their current value so that new rows do not collide with the restored ones.
+	columns, err := p.columns(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range columns {
+		if !c.Sequence.Valid {
+			continue
+		}
+
+		if c.Identity == "" {
+			statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s",
+				c.Sequence.String, p.QuoteIdentifier(name), p.QuoteIdentifier(c.Name)))
+		}
+
+		var lastValue int64
+		var isCalled bool
+		if err := p.db.QueryRowContext(ctx, "SELECT last_value, is_called FROM "+c.Sequence.String).Scan(&lastValue, &isCalled); err != nil {
+			return "", fmt.Errorf("error getting sequence %s: %w", c.Sequence.String, err)
+		}
+
+		statements = append(statements, fmt.Sprintf("SELECT setval(%s, %d, %t)",
+			p.QuoteValue(sql.NullString{String: c.Sequence.String, Valid: true}), lastValue, isCalled))
+	}
+
+	return strings.Join(statements, ";\n"), nil
+}
+
+func (p *postgresDialect) Triggers(ctx context.Context) ([]string, error) {
+	triggers := make([]string, 0)
+	err := p.db.SelectContext(ctx, &triggers, `SELECT t.tgname
+FROM pg_trigger t
+JOIN pg_class c ON c.oid = t.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND NOT t.tgisinternal
+ORDER BY t.tgname`)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return triggers, nil
+}
+
+func (p *postgresDialect) TriggerSQL(ctx context.Context, name string) (string, error) {
+	var triggerSQL string
+	err := p.db.GetContext(ctx, &triggerSQL, `SELECT pg_get_triggerdef(t.oid, true)
+FROM pg_trigger t
+JOIN pg_class c ON c.oid = t.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND t.tgname = $1
+LIMIT 1`, name)
+	if err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return triggerSQL, nil
+}
+
+// Views returns the views ordered by their depth in pg_depend, so that every view comes after the views it selects
+// from, and by name within a depth.
+func (p *postgresDialect) Views(ctx context.Context) ([]string, error) {
+	views := make([]string, 0)
+	err := p.db.SelectContext(ctx, &views, `WITH RECURSIVE views AS (
+  SELECT c.oid, c.relname
+  FROM pg_class c
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = current_schema() AND c.relkind = 'v'
+), uses AS (
+  SELECT DISTINCT r.ev_class AS view, d.refobjid AS used
+  FROM pg_rewrite r
+  JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid AND d.refclassid = 'pg_class'::regclass
+  WHERE r.ev_class IN (SELECT oid FROM views) AND d.refobjid IN (SELECT oid FROM views) AND d.refobjid <> r.ev_class
+), depths AS (
+  SELECT oid, 0 AS depth FROM views
+  UNION ALL
+  SELECT u.view, depths.depth + 1 FROM uses u JOIN depths ON depths.oid = u.used
+)
+SELECT v.relname
+FROM views v
+JOIN depths ON depths.oid = v.oid
+GROUP BY v.relname
+ORDER BY max(depths.depth), v.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return views, nil
+}
+
+func (p *postgresDialect) ViewSQL(ctx context.Context, name string) (string, error) {
+	var definition string
+	if err := p.db.GetContext(ctx, &definition, "SELECT pg_get_viewdef($1::regclass, true)", p.QuoteIdentifier(name)); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return "CREATE OR REPLACE VIEW " + p.QuoteIdentifier(name) + " AS\n" + strings.TrimSuffix(strings.TrimSpace(definition), ";"), nil
+}
+
+// Routines returns the functions and procedures of the schema. Those whose arguments or result use the row type of a
+// table or view are marked, as they can only be created after the tables and views.
+func (p *postgresDialect) Routines(ctx context.Context) ([]*Routine, error) {
+	routines := make([]*Routine, 0)
+	err := p.db.SelectContext(ctx, &routines, `SELECT p.proname AS name,
+  CASE p.prokind WHEN 'p' THEN 'PROCEDURE' ELSE 'FUNCTION' END AS type,
+  pg_get_functiondef(p.oid) AS sql,
+  EXISTS (
+    SELECT 1
+    FROM pg_depend d
+    JOIN pg_type t ON t.oid = d.refobjid
+    JOIN pg_class c ON c.oid = t.typrelid
+    WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.refclassid = 'pg_type'::regclass
+      AND c.relkind IN ('r', 'p', 'v', 'm', 'f')
+  ) AS uses_tables
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = current_schema() AND p.prokind IN ('f', 'p')
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')
+ORDER BY p.prokind, p.proname`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting routines: %w", err)
+	}
+
+	for _, r := range routines {
+		r.SQL = strings.TrimSpace(r.SQL)
+	}
+
+	return routines, nil
+}
+
+// pgType is a user-defined enum, composite type or domain of a PostgreSQL schema.
+type pgType struct {
+	Name string `db:"name"`
+
+	// Kind is the typtype of the type, e for enums, c for composite types and d for domains.
+	Kind string `db:"kind"`
+
+	// Definition is the labels of an enum, the attributes of a composite type or the base type of a domain.
+	Definition sql.NullString `db:"definition"`
+
+	// Default, NotNull and Checks are the default, NOT NULL and CHECK constraints of a domain.
+	Default sql.NullString `db:"default"`
+	NotNull bool           `db:"not_null"`
+	Checks  sql.NullString `db:"checks"`
+}
+
+// sql returns the statement that creates the type.
+func (t *pgType) sql(p *postgresDialect) string {
+	switch t.Kind {
+	case "e":
+		return "CREATE TYPE " + p.QuoteIdentifier(t.Name) + " AS ENUM (" + t.Definition.String + ")"
+	case "c":
+		return "CREATE TYPE " + p.QuoteIdentifier(t.Name) + " AS (" + t.Definition.String + ")"
+	}
+
+	s := "CREATE DOMAIN " + p.QuoteIdentifier(t.Name) + " AS " + t.Definition.String
+	if t.Default.Valid {
+		s += " DEFAULT " + t.Default.String
+	}
+
+	if t.NotNull {
+		s += " NOT NULL"
+	}
+
+	if t.Checks.Valid {
+		s += " " + t.Checks.String
+	}
+
+	return s
+}
+
+// pgSequence is a sequence of a PostgreSQL schema that is not owned by a column.
+type pgSequence struct {
+	Name      string `db:"name"`
+	Type      string `db:"type"`
+	Start     int64  `db:"start"`
+	Increment int64  `db:"increment"`
+	Min       int64  `db:"min"`
+	Max       int64  `db:"max"`
+	Cache     int64  `db:"cache"`
+	Cycle     bool   `db:"cycle"`
+	LastValue int64  `db:"last_value"`
+	IsCalled  bool   `db:"is_called"`
+}
+
+// sql returns the statements that create the sequence and continue it from its current value.
+func (s *pgSequence) sql(p *postgresDialect) string {
+	cycle := "NO CYCLE"
+	if s.Cycle {
+		cycle = "CYCLE"
+	}
+
+	return fmt.Sprintf("CREATE SEQUENCE %s AS %s INCREMENT BY %d MINVALUE %d MAXVALUE %d START WITH %d CACHE %d %s;\n"+
+		"SELECT setval(%s, %d, %t)",
+		p.QuoteIdentifier(s.Name), s.Type, s.Increment, s.Min, s.Max, s.Start, s.Cache, cycle,
+		p.QuoteValue(sql.NullString{String: p.QuoteIdentifier(s.Name), Valid: true}), s.LastValue, s.IsCalled)
+}
+
+// SchemaObjects returns the extensions installed in the schema, then its enums, composite types and domains in the
+// order they were created, then the sequences that are not owned by a column. Sequences owned by serial and identity
+// columns are created with their tables. Objects that belong to an extension are created by the extension.
+func (p *postgresDialect) SchemaObjects(ctx context.Context) ([]*SchemaObject, error) {
+	objects := make([]*SchemaObject, 0)
+
+	extensions := make([]string, 0)
+	err := p.db.SelectContext(ctx, &extensions, `SELECT e.extname
+FROM pg_extension e
+JOIN pg_namespace n ON n.oid = e.extnamespace
+WHERE n.nspname = current_schema()
+ORDER BY e.extname`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting extensions: %w", err)
+	}
+
+	for _, e := range extensions {
+		objects = append(objects, &SchemaObject{
+			Type: "EXTENSION",
+			Name: e,
+			SQL:  "CREATE EXTENSION IF NOT EXISTS " + p.QuoteIdentifier(e) + " CASCADE",
+		})
+	}
+
+	types := make([]*pgType, 0)
+	err = p.db.SelectContext(ctx, &types, `SELECT t.typname AS name,
+  t.typtype AS kind,
+  CASE t.typtype
+    WHEN 'e' THEN (SELECT string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder) FROM pg_enum e WHERE e.enumtypid = t.oid)
+    WHEN 'c' THEN (SELECT string_agg(quote_ident(a.attname) || ' ' || format_type(a.atttypid, a.atttypmod), ', ' ORDER BY a.attnum)
+      FROM pg_attribute a WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped)
+    ELSE format_type(t.typbasetype, t.typtypmod)
+  END AS definition,
+  t.typdefault AS default,
+  t.typnotnull AS not_null,
+  (SELECT string_agg('CONSTRAINT ' || quote_ident(c.conname) || ' ' || pg_get_constraintdef(c.oid), ' ' ORDER BY c.conname)
+    FROM pg_constraint c WHERE c.contypid = t.oid) AS checks
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+LEFT JOIN pg_class r ON r.oid = t.typrelid
+WHERE n.nspname = current_schema()
+  AND (t.typtype IN ('e', 'd') OR (t.typtype = 'c' AND r.relkind = 'c'))
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid AND d.deptype = 'e')
+ORDER BY t.oid`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting types: %w", err)
+	}
+
+	for _, t := range types {
+		typ := "TYPE"
+		if t.Kind == "d" {
+			typ = "DOMAIN"
+		}
+
+		objects = append(objects, &SchemaObject{
+			Type: typ,
+			Name: t.Name,
+			SQL:  t.sql(p),
+		})
+	}
+
+	sequences := make([]*pgSequence, 0)
+	err = p.db.SelectContext(ctx, &sequences, `SELECT c.relname AS name,
+  format_type(s.seqtypid, NULL) AS type,
+  s.seqstart AS start,
+  s.seqincrement AS increment,
+  s.seqmin AS min,
+  s.seqmax AS max,
+  s.seqcache AS cache,
+  s.seqcycle AS cycle
+FROM pg_sequence s
+JOIN pg_class c ON c.oid = s.seqrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema()
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('a', 'i', 'e'))
+ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sequences: %w", err)
+	}
+
+	for _, s := range sequences {
+		if err := p.db.QueryRowContext(ctx, "SELECT last_value, is_called FROM "+p.QuoteIdentifier(s.Name)).Scan(&s.LastValue, &s.IsCalled); err != nil {
+			return nil, fmt.Errorf("error getting sequence %s: %w", s.Name, err)
+		}
+
+		objects = append(objects, &SchemaObject{
+			Type: "SEQUENCE",
+			Name: s.Name,
+			SQL:  s.sql(p),
+		})
+	}
+
+	return objects, nil
+}
+
+// TableEstimates returns the row estimates of the planner, which are only set once a table has been analyzed.
+func (p *postgresDialect) TableEstimates(ctx context.Context) ([]*TableEstimate, error) {
+	estimates := make([]*TableEstimate, 0)
+	err := p.db.SelectContext(ctx, &estimates, `SELECT c.relname AS name, greatest(c.reltuples, 0)::bigint AS table_rows,
+       pg_relation_size(c.oid) AS data_length
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p')`)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return estimates, nil
+}
+
+func (p *postgresDialect) KeyColumns(ctx context.Context, name string) ([]string, error) {
+	key := make([]string, 0)
+	err := p.db.SelectContext(ctx, &key, `SELECT a.attname
+FROM pg_index i
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+WHERE i.indrelid = $1::text::regclass AND i.indisprimary
+ORDER BY array_position(i.indkey::int2[], a.attnum)`, p.QuoteIdentifier(name))
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ReadRows reads the values in their text output, which is valid input for their type, rather than as the driver scans
+// them, which is raw bytes for bytea and a timestamp on year 0 for time. bytea is read in the hex format whatever the
+// bytea_output of the server. Generated columns are not read, as they cannot be inserted.
+func (p *postgresDialect) ReadRows(ctx context.Context, name string, r *RowRange, fn func(values []sql.NullString) error) ([]string, error) {
+	columns, err := p.columns(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	query, names := p.readRowsQuery(name, columns, r)
+	if _, err := readRows(ctx, p.db, query, fn); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// readRowsQuery returns the query reading the range of the table as text, and the names of the columns it reads.
+func (p *postgresDialect) readRowsQuery(name string, columns []*pgColumn, r *RowRange) (string, []string) {
+	names := make([]string, 0, len(columns))
+	values := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if c.Generated != "" {
+			continue
+		}
+
+		names = append(names, c.Name)
+		if c.Type == "bytea" {
+			values = append(values, `'\x' || encode(`+p.QuoteIdentifier(c.Name)+", 'hex')")
+		} else {
+			values = append(values, p.QuoteIdentifier(c.Name)+"::text")
+		}
+	}
+
+	return "SELECT " + strings.Join(values, ", ") + " FROM " + p.QuoteIdentifier(name) + r.clause(pgTableKey{p, name}), names
+}
+
+// pgTableKey is the PostgreSQL dialect for the range of ReadRows. The text values keep the names of their columns, which
+// ORDER BY would sort by, so the key columns are qualified with the table.
+type pgTableKey struct {
+	*postgresDialect
+	table string
+}
+
+func (k pgTableKey) QuoteIdentifier(name string) string {
+	return k.postgresDialect.QuoteIdentifier(k.table) + "." + k.postgresDialect.QuoteIdentifier(name)
+}
+
+func (p *postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (p *postgresDialect) QuoteValue(value sql.NullString) string {
+	if !value.Valid {
+		return "NULL"
+	}
+
+	// The dump sets standard_conforming_strings, so backslashes are not escape characters.
+	return "'" + strings.ReplaceAll(value.String, "'", "''") + "'"
+}
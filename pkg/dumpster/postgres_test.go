@@ -0,0 +1,195 @@
+package dumpster
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDialect(t *testing.T) {
+	require.Equal(t, DialectPostgres, newDialect(sqlx.NewDb(nil, "postgres")).Name())
+	require.Equal(t, DialectMySQL, newDialect(sqlx.NewDb(nil, "mysql")).Name())
+}
+
+func TestQuoteValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		value   sql.NullString
+		want    string
+	}{
+		{
+			name:    "mysql null",
+			dialect: NewMySQLDialect(nil),
+			value:   sql.NullString{},
+			want:    "null",
+		},
+		{
+			name:    "mysql escaped",
+			dialect: NewMySQLDialect(nil),
+			value:   sql.NullString{String: `it's C:\tmp`, Valid: true},
+			want:    `'it''s C:\\tmp'`,
+		},
+		{
+			name:    "postgres null",
+			dialect: NewPostgresDialect(nil),
+			value:   sql.NullString{},
+			want:    "NULL",
+		},
+		{
+			name:    "postgres escaped",
+			dialect: NewPostgresDialect(nil),
+			value:   sql.NullString{String: `it's C:\tmp`, Valid: true},
+			want:    `'it''s C:\tmp'`,
+		},
+		{
+			name:    "postgres bytea",
+			dialect: NewPostgresDialect(nil),
+			value:   sql.NullString{String: `\x00ff27`, Valid: true},
+			want:    `'\x00ff27'`,
+		},
+		{
+			name:    "postgres time",
+			dialect: NewPostgresDialect(nil),
+			value:   sql.NullString{String: "12:30:00+02", Valid: true},
+			want:    "'12:30:00+02'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.dialect.QuoteValue(tt.value))
+		})
+	}
+}
+
+func TestPostgres_ReadRowsQuery(t *testing.T) {
+	p := NewPostgresDialect(nil).(*postgresDialect)
+	columns := []*pgColumn{
+		{Name: "id", Type: "integer"},
+		{Name: "data", Type: "bytea"},
+		{Name: "at", Type: "time without time zone"},
+		{Name: "at_tz", Type: "time with time zone"},
+		{Name: "total", Type: "integer", Generated: "s"},
+	}
+
+	// bytea is read in the hex format and the rest as text, so that neither is scanned by the driver.
+	query, names := p.readRowsQuery("events", columns, nil)
+	require.Equal(t, `SELECT "id"::text, '\x' || encode("data", 'hex'), "at"::text, "at_tz"::text FROM "events"`, query)
+	require.Equal(t, []string{"id", "data", "at", "at_tz"}, names)
+
+	// The key is qualified with the table, so that the rows are not sorted by the text of the key.
+	r := &RowRange{Key: []string{"id"}, After: []sql.NullString{{String: "10", Valid: true}}, Limit: 100}
+	query, _ = p.readRowsQuery("events", columns[:1], r)
+	require.Equal(t, `SELECT "id"::text FROM "events" WHERE ("events"."id" > '10') ORDER BY "events"."id" LIMIT 100`, query)
+}
+
+func TestNextvalRegex(t *testing.T) {
+	m := nextvalRegex.FindStringSubmatch(`nextval('"Users_id_seq"'::regclass)`)
+	require.Equal(t, `"Users_id_seq"`, m[1])
+
+	require.Nil(t, nextvalRegex.FindStringSubmatch("now()"))
+}
+
+func TestTemplate_Postgres(t *testing.T) {
+	d := &Dumpster{dialect: NewPostgresDialect(nil)}
+	data := &TemplateData{
+		Dialect:  DialectPostgres,
+		Database: "app",
+		Tables: []*Table{
+			{
+				Name:           "users",
+				SQL:            "CREATE TABLE \"users\" (\n  \"id\" integer GENERATED ALWAYS AS IDENTITY NOT NULL\n)",
+				Columns:        []string{"id", "Name"},
+				stream:         staticStream(t, "('1','a')"),
+				ConstraintsSQL: "SELECT setval('public.users_id_seq', 1, true)",
+			},
+		},
+	}
+
+	got, err := d.render(data)
+	require.NoError(t, err)
+	require.Contains(t, got, "SET standard_conforming_strings = on;\n")
+
+	// Types and routines come before the tables that use them, and routines that use the row type of a table after
+	// the tables and views.
+	data.Objects = []*SchemaObject{{Type: "TYPE", Name: "mood", SQL: "CREATE TYPE \"mood\" AS ENUM ('ok')"}}
+	data.Routines = []*Routine{
+		{Name: "active", Type: "FUNCTION", SQL: "CREATE FUNCTION active() RETURNS SETOF users", UsesTables: true},
+		{Name: "today", Type: "FUNCTION", SQL: "CREATE FUNCTION today() RETURNS date"},
+	}
+	data.Views = []*View{{Name: "names", SQL: "CREATE OR REPLACE VIEW \"names\" AS\nSELECT 1"}}
+	data.Tables[0].stream = staticStream(t, "('1','a')")
+
+	got, err = d.render(data)
+	require.NoError(t, err)
+	order := []string{"CREATE TYPE \"mood\"", "CREATE FUNCTION today()", "CREATE TABLE \"users\"", "CREATE OR REPLACE VIEW", "CREATE FUNCTION active()"}
+	for i := 1; i < len(order); i++ {
+		require.Less(t, strings.Index(got, order[i-1]), strings.Index(got, order[i]), order[i])
+	}
+	require.Contains(t, got, "-- Structure for TYPE mood\nCREATE TYPE \"mood\" AS ENUM ('ok');\n")
+	require.Contains(t, got, "INSERT INTO \"users\" (\"id\", \"Name\") OVERRIDING SYSTEM VALUE VALUES ('1','a');\n")
+	require.Contains(t, got, "-- Constraints for table users\nSELECT setval('public.users_id_seq', 1, true);\n")
+	require.NotContains(t, got, "CREATE DATABASE")
+
+	// Custom templates quote for the dialect of the database.
+	tmpl, err := ParseTemplate(`{{ quoteIdentifier .Database }} {{ escapeString "a'b" }}`)
+	require.NoError(t, err)
+
+	require.NoError(t, WithTemplate(tmpl)(d))
+	got, err = d.render(data)
+	require.NoError(t, err)
+	require.Equal(t, `"app" a''b`, got)
+}
+
+func TestPostgres_SchemaObjectSQL(t *testing.T) {
+	p := &postgresDialect{}
+
+	tests := []struct {
+		name string
+		typ  *pgType
+		want string
+	}{
+		{
+			name: "enum",
+			typ:  &pgType{Name: "Mood", Kind: "e", Definition: sql.NullString{String: "'sad', 'it''s ok'", Valid: true}},
+			want: `CREATE TYPE "Mood" AS ENUM ('sad', 'it''s ok')`,
+		},
+		{
+			name: "empty enum",
+			typ:  &pgType{Name: "mood", Kind: "e"},
+			want: `CREATE TYPE "mood" AS ENUM ()`,
+		},
+		{
+			name: "composite",
+			typ:  &pgType{Name: "point", Kind: "c", Definition: sql.NullString{String: "x integer, y integer", Valid: true}},
+			want: `CREATE TYPE "point" AS (x integer, y integer)`,
+		},
+		{
+			name: "domain",
+			typ: &pgType{
+				Name:       "email",
+				Kind:       "d",
+				Definition: sql.NullString{String: "character varying(255)", Valid: true},
+				Default:    sql.NullString{String: "''::character varying", Valid: true},
+				NotNull:    true,
+				Checks:     sql.NullString{String: "CONSTRAINT email_check CHECK (((VALUE)::text ~~ '%@%'::text))", Valid: true},
+			},
+			want: `CREATE DOMAIN "email" AS character varying(255) DEFAULT ''::character varying NOT NULL ` +
+				`CONSTRAINT email_check CHECK (((VALUE)::text ~~ '%@%'::text))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.typ.sql(p))
+		})
+	}
+
+	seq := &pgSequence{Name: "Order no", Type: "bigint", Start: 1000, Increment: 1, Min: 1, Max: 9223372036854775807, Cache: 1, LastValue: 1041, IsCalled: true}
+	require.Equal(t, `CREATE SEQUENCE "Order no" AS bigint INCREMENT BY 1 MINVALUE 1 MAXVALUE 9223372036854775807 START WITH 1000 CACHE 1 NO CYCLE;
+SELECT setval('"Order no"', 1041, true)`, seq.sql(p))
+}
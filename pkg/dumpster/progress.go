@@ -0,0 +1,184 @@
+package dumpster
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+)
+
+// progressRowInterval is the number of rows between ProgressRows events.
+const progressRowInterval = 1000
+
+// ProgressEventType is the kind of a ProgressEvent.
+type ProgressEventType string
+
+const (
+	// ProgressStarted is sent once the tables of the dump are known, with the estimated totals.
+	ProgressStarted ProgressEventType = "started"
+
+	// ProgressTableStarted is sent before the rows of a table are read.
+	ProgressTableStarted ProgressEventType = "table_started"
+
+	// ProgressRows is sent while the rows of a table are read, every 1000 rows.
+	ProgressRows ProgressEventType = "rows"
+
+	// ProgressTableFinished is sent once every row of a table has been read.
+	ProgressTableFinished ProgressEventType = "table_finished"
+
+	// ProgressFinished is sent once every table has been read and the dump has been rendered.
+	ProgressFinished ProgressEventType = "finished"
+)
+
+// ProgressEvent reports the progress of a dump. The counts are of the rows and the bytes of the values written to the
+// dump, before compression.
+type ProgressEvent struct {
+	// Type is the kind of the event.
+	Type ProgressEventType
+
+	// Table is the table the event is for. It is empty for ProgressStarted and ProgressFinished.
+	Table string
+
+	// TableRows is the number of rows of the table written so far.
+	TableRows int64
+
+	// TableBytes is the number of bytes of the table written so far.
+	TableBytes int64
+
+	// Tables is the number of tables in the dump.
+	Tables int
+
+	// TablesDone is the number of tables that have been read.
+	TablesDone int
+
+	// Rows is the number of rows of every table written so far.
+	Rows int64
+
+	// Bytes is the number of bytes of every table written so far.
+	Bytes int64
+
+	// EstimatedRows is the estimated number of rows of the dump, from the statistics of the server. It is zero if
+	// unknown.
+	EstimatedRows int64
+
+	// EstimatedBytes is the estimated size of the data of the dump in the storage of the server. It is zero if unknown.
+	EstimatedBytes int64
+}
+
+// TableEstimate is the estimated size of a table, from the statistics of the server.
+type TableEstimate struct {
+	// Name is the name of the table.
+	Name string `db:"name"`
+
+	// Rows is the estimated number of rows.
+	Rows int64 `db:"table_rows"`
+
+	// Bytes is the estimated size of the data in the storage of the server.
+	Bytes int64 `db:"data_length"`
+}
+
+// WithProgress sets the function called with the progress of each dump. The rows are read as the dump is rendered, so
+// the function is called from the goroutine reading the rows, one call at a time, and should return quickly.
+func WithProgress(fn func(e ProgressEvent)) Option {
+	return func(d *Dumpster) error {
+		d.progress = fn
+		return nil
+	}
+}
+
+// progress tracks the progress of a dump and sends it to the progress function. A nil progress sends nothing.
+type progress struct {
+	fn    func(e ProgressEvent)
+	event ProgressEvent
+}
+
+// newProgress returns the progress of a dump of the tables, or nil if no progress function is set.
+func (d *Dumpster) newProgress(ctx context.Context, tables []string) *progress {
+	if d.progress == nil {
+		return nil
+	}
+
+	p := &progress{
+		fn: d.progress,
+		event: ProgressEvent{
+			Tables: len(tables),
+		},
+	}
+
+	// The estimates only make the progress more useful, so the dump goes on without them.
+	estimates, err := d.dialect.TableEstimates(ctx)
+	if err != nil {
+		slog.Warn("Error estimating the size of the dump", slog.String(logging.KeyError, err.Error()))
+	}
+
+	sizes := make(map[string]*TableEstimate, len(estimates))
+	for _, e := range estimates {
+		sizes[e.Name] = e
+	}
+
+	for _, name := range tables {
+		if e, ok := sizes[name]; ok && !matchAny(d.excludeData, name) {
+			p.event.EstimatedRows += e.Rows
+			p.event.EstimatedBytes += e.Bytes
+		}
+	}
+
+	p.send(ProgressStarted)
+	return p
+}
+
+// send calls the progress function with an event of the type.
+func (p *progress) send(t ProgressEventType) {
+	p.event.Type = t
+	p.fn(p.event)
+}
+
+// tableStarted sends the start of the table.
+func (p *progress) tableStarted(name string) {
+	if p == nil {
+		return
+	}
+
+	p.event.Table = name
+	p.event.TableRows = 0
+	p.event.TableBytes = 0
+	p.send(ProgressTableStarted)
+}
+
+// row counts a row of the table of the given size, and sends the progress every progressRowInterval rows.
+func (p *progress) row(size int) {
+	if p == nil {
+		return
+	}
+
+	p.event.TableRows++
+	p.event.TableBytes += int64(size)
+	p.event.Rows++
+	p.event.Bytes += int64(size)
+
+	if p.event.TableRows%progressRowInterval == 0 {
+		p.send(ProgressRows)
+	}
+}
+
+// tableFinished sends the end of the table.
+func (p *progress) tableFinished() {
+	if p == nil {
+		return
+	}
+
+	p.event.TablesDone++
+	p.send(ProgressTableFinished)
+}
+
+// finished sends the end of the dump.
+func (p *progress) finished() {
+	if p == nil {
+		return
+	}
+
+	p.event.Table = ""
+	p.event.TableRows = 0
+	p.event.TableBytes = 0
+	p.send(ProgressFinished)
+}
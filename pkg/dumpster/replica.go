@@ -0,0 +1,204 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplicaLag pauses a dump of a MySQL replica while its replication lag is too high, so that a backup is not silently
+// taken from stale data and the dump does not add to the lag.
+type ReplicaLag struct {
+	// Pause is the lag above which reading pauses until the replica has caught up.
+	Pause time.Duration
+
+	// Fail is the lag above which the dump fails. The dump never fails on lag if zero.
+	Fail time.Duration
+
+	// Interval is how often the lag is checked, between chunks and while paused. One second is used if zero.
+	Interval time.Duration
+}
+
+// WithReplicaLag checks the replication lag of the database before each table and between chunks. The dump refuses to
+// start if the replication SQL thread of the replica is stopped. This is only supported for MySQL.
+func WithReplicaLag(lag ReplicaLag) Option {
+	return func(d *Dumpster) error {
+		if !d.isMySQL() {
+			return fmt.Errorf("replica lag checks are not supported for %s", d.dialect.Name())
+		}
+
+		if lag.Pause <= 0 {
+			return errors.New("replica lag pause must be positive")
+		}
+
+		if lag.Fail != 0 && lag.Fail < lag.Pause {
+			return errors.New("replica lag failure limit must not be below the pause limit")
+		}
+
+		if lag.Interval < 0 {
+			return errors.New("replica lag interval must not be negative")
+		}
+
+		d.replicaLag = lag
+		return nil
+	}
+}
+
+// replicaStatus is the state of replication on a replica.
+type replicaStatus struct {
+	// sqlRunning is whether the replication SQL thread is running.
+	sqlRunning bool
+
+	// lag is the replication lag. It is only set if lagKnown is true.
+	lag time.Duration
+
+	// lagKnown is whether the server reported a lag. It is unknown if either replication thread is stopped.
+	lagKnown bool
+}
+
+// getReplicaStatus returns the replication status of the database. SHOW SLAVE STATUS is used on servers older than
+// MySQL 8.0.22. An error is returned if the database is not a replica.
+func (d *Dumpster) getReplicaStatus(ctx context.Context) (*replicaStatus, error) {
+	rows, err := d.db.QueryxContext(ctx, "SHOW REPLICA STATUS")
+	if isOldReplicaStatus(err) {
+		rows, err = d.db.QueryxContext(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting replica status: %w", err)
+	}
+
+	defer func(rows *sqlx.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error getting replica status: %w", err)
+		}
+
+		return nil, errors.New("database is not a replica")
+	}
+
+	row := make(map[string]any)
+	if err := rows.MapScan(row); err != nil {
+		return nil, fmt.Errorf("error scanning replica status: %w", err)
+	}
+
+	return parseReplicaStatus(row), nil
+}
+
+// isOldReplicaStatus reports whether SHOW REPLICA STATUS failed because the server is older than MySQL 8.0.22 and does
+// not know the statement. Other errors, such as a missing privilege, are not retried with SHOW SLAVE STATUS.
+func isOldReplicaStatus(err error) bool {
+	return isMySQLError(err, mysqlErrParse)
+}
+
+// parseReplicaStatus returns the replication status from a row of SHOW REPLICA STATUS or SHOW SLAVE STATUS.
+func parseReplicaStatus(row map[string]any) *replicaStatus {
+	status := &replicaStatus{
+		sqlRunning: statusValue(row, "Replica_SQL_Running", "Slave_SQL_Running") == "Yes",
+	}
+
+	if seconds, err := strconv.ParseInt(statusValue(row, "Seconds_Behind_Source", "Seconds_Behind_Master"), 10, 64); err == nil {
+		status.lag = time.Duration(seconds) * time.Second
+		status.lagKnown = true
+	}
+
+	return status
+}
+
+// statusValue returns the value of the first of the columns in the row, or an empty string if none are set.
+func statusValue(row map[string]any, columns ...string) string {
+	for _, c := range columns {
+		switch v := row[c].(type) {
+		case []byte:
+			return string(v)
+		case string:
+			return v
+		case int64:
+			return strconv.FormatInt(v, 10)
+		}
+	}
+
+	return ""
+}
+
+// lagMonitor pauses and fails a dump on the replication lag of the database.
+type lagMonitor struct {
+	limits    ReplicaLag
+	status    func(ctx context.Context) (*replicaStatus, error)
+	lastCheck time.Time
+}
+
+// start checks that replication is running and waits for the lag to drop below the pause limit.
+func (m *lagMonitor) start(ctx context.Context) error {
+	status, err := m.status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !status.sqlRunning {
+		return errors.New("replication SQL thread is not running, refusing to dump stale data")
+	}
+
+	return m.wait(ctx)
+}
+
+// check waits for the lag to drop below the pause limit if the interval has passed since the last check.
+func (m *lagMonitor) check(ctx context.Context) error {
+	if time.Since(m.lastCheck) < m.limits.Interval {
+		return nil
+	}
+
+	return m.wait(ctx)
+}
+
+// wait blocks while the lag is above the pause limit. It fails if replication stops or the lag is above the failure
+// limit.
+func (m *lagMonitor) wait(ctx context.Context) error {
+	paused := false
+	for {
+		status, err := m.status(ctx)
+		if err != nil {
+			return err
+		}
+
+		m.lastCheck = time.Now()
+		switch {
+		case !status.sqlRunning:
+			return errors.New("replication SQL thread stopped during the dump")
+		case !status.lagKnown:
+			return errors.New("replication lag is unknown, the replication IO thread may be stopped")
+		case m.limits.Fail != 0 && status.lag > m.limits.Fail:
+			return fmt.Errorf("replication lag of %s is above the limit of %s", status.lag, m.limits.Fail)
+		case status.lag <= m.limits.Pause:
+			if paused {
+				slog.Info("Replication lag is below the limit, resuming the dump", slog.String("lag", status.lag.String()))
+			}
+
+			return nil
+		}
+
+		if !paused {
+			slog.Info("Replication lag is above the limit, pausing the dump",
+				slog.String("lag", status.lag.String()),
+				slog.String("limit", m.limits.Pause.String()),
+			)
+			paused = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.limits.Interval):
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package dumpster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplicaStatus(t *testing.T) {
+	got := parseReplicaStatus(map[string]any{
+		"Replica_SQL_Running":   []byte("Yes"),
+		"Seconds_Behind_Source": []byte("12"),
+	})
+	require.Equal(t, &replicaStatus{sqlRunning: true, lag: 12 * time.Second, lagKnown: true}, got)
+
+	// Servers older than MySQL 8.0.22, with replication stopped.
+	got = parseReplicaStatus(map[string]any{
+		"Slave_SQL_Running":     []byte("No"),
+		"Seconds_Behind_Master": nil,
+	})
+	require.Equal(t, &replicaStatus{}, got)
+}
+
+func TestIsOldReplicaStatus(t *testing.T) {
+	require.False(t, isOldReplicaStatus(nil))
+	require.True(t, isOldReplicaStatus(fmt.Errorf("error: %w", &mysql.MySQLError{Number: mysqlErrParse})))
+
+	// Errors other than a syntax error are returned as they are.
+	require.False(t, isOldReplicaStatus(&mysql.MySQLError{Number: 1227, Message: "Access denied; you need the REPLICATION CLIENT privilege"}))
+	require.False(t, isOldReplicaStatus(context.DeadlineExceeded))
+}
+
+func TestLagMonitor(t *testing.T) {
+	// statuses returns a status function that returns the statuses in order, repeating the last one.
+	statuses := func(s ...*replicaStatus) func(context.Context) (*replicaStatus, error) {
+		calls := 0
+		return func(context.Context) (*replicaStatus, error) {
+			status := s[min(calls, len(s)-1)]
+			calls++
+			return status, nil
+		}
+	}
+
+	limits := ReplicaLag{Pause: 10 * time.Second, Fail: time.Minute, Interval: time.Millisecond}
+	lag := func(d time.Duration) *replicaStatus {
+		return &replicaStatus{sqlRunning: true, lag: d, lagKnown: true}
+	}
+
+	tests := []struct {
+		name    string
+		status  []*replicaStatus
+		wantErr string
+	}{
+		{
+			name:   "caught up",
+			status: []*replicaStatus{lag(0)},
+		},
+		{
+			name:   "pauses until caught up",
+			status: []*replicaStatus{lag(30 * time.Second), lag(20 * time.Second), lag(5 * time.Second)},
+		},
+		{
+			name:    "above the failure limit",
+			status:  []*replicaStatus{lag(30 * time.Second), lag(2 * time.Minute)},
+			wantErr: "above the limit",
+		},
+		{
+			name:    "SQL thread stopped",
+			status:  []*replicaStatus{{lagKnown: true}},
+			wantErr: "not running",
+		},
+		{
+			name:    "unknown lag",
+			status:  []*replicaStatus{{sqlRunning: true}},
+			wantErr: "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &lagMonitor{limits: limits, status: statuses(tt.status...)}
+			err := m.start(context.Background())
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWithReplicaLag(t *testing.T) {
+	d := &Dumpster{dialect: NewMySQLDialect(nil)}
+	require.NoError(t, WithReplicaLag(ReplicaLag{Pause: time.Second})(d))
+	require.Error(t, WithReplicaLag(ReplicaLag{})(d))
+	require.Error(t, WithReplicaLag(ReplicaLag{Pause: time.Minute, Fail: time.Second})(d))
+
+	d = &Dumpster{dialect: NewSQLiteDialect(nil)}
+	require.Error(t, WithReplicaLag(ReplicaLag{Pause: time.Second})(d))
+}
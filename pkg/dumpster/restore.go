@@ -0,0 +1,21 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Restore executes the given dump against the database. The connection must allow multiple statements to be run in
+// a single query (multiStatements=true).
+func (d *Dumpster) Restore(ctx context.Context, dump string) error {
+	if dump == "" {
+		return errors.New("dump is empty")
+	}
+
+	if _, err := d.db.ExecContext(ctx, dump); err != nil {
+		return fmt.Errorf("error executing dump: %w", err)
+	}
+
+	return nil
+}
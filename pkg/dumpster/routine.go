@@ -0,0 +1,129 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+const (
+	// routineProcedure is the type of a stored procedure.
+	routineProcedure = "PROCEDURE"
+
+	// routineFunction is the type of a stored function.
+	routineFunction = "FUNCTION"
+)
+
+// Routine is a stored procedure or function of the dumped schema.
+type Routine struct {
+	// Name is the name of the routine.
+	Name string
+
+	// Type is PROCEDURE or FUNCTION.
+	Type string
+
+	// SQL is the CREATE PROCEDURE or CREATE FUNCTION statement, without a trailing semicolon.
+	SQL string
+
+	// UsesTables is whether the arguments or result of the routine use the row type of a table or view, so that it can
+	// only be created after them. It is only set for PostgreSQL, where routines are otherwise created before tables.
+	UsesTables bool `db:"uses_tables"`
+}
+
+func (m *mysqlDialect) getRoutines(ctx context.Context) ([]*Routine, error) {
+	sqlStmt := "SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = DATABASE() ORDER BY ROUTINE_TYPE, ROUTINE_NAME"
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	// Read data
+	routines := make([]*Routine, 0)
+	for rows.Next() {
+		r := new(Routine)
+		if err := rows.Scan(&r.Name, &r.Type); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		routines = append(routines, r)
+	}
+
+	return routines, nil
+}
+
+func (m *mysqlDialect) createRoutineSQL(ctx context.Context, r *Routine) (string, error) {
+	sqlStmt := "SHOW CREATE " + r.Type + " " + sqlparse.QuoteIdentifier(r.Name)
+
+	// Prepare statement for reading data
+	stmt, err := m.db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return "", fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	// Execute statement
+	routineName := new(sql.NullString)
+	sqlMode := new(sql.NullString)
+	routineSQL := new(sql.NullString)
+	characterSetClient := new(sql.NullString)
+	collationConnection := new(sql.NullString)
+	databaseCollation := new(sql.NullString)
+
+	if err := stmt.QueryRowContext(ctx).Scan(routineName, sqlMode, routineSQL, characterSetClient,
+		collationConnection, databaseCollation); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if !routineSQL.Valid {
+		// The body is NULL when the user does not have privileges to see the routine definition.
+		return "", errors.New("returned routine SQL is not valid")
+	}
+
+	return routineSQL.String, nil
+}
+
+// getRoutinesWithSQL returns the routines of the schema with their definitions.
+func (m *mysqlDialect) getRoutinesWithSQL(ctx context.Context) ([]*Routine, error) {
+	routines, err := m.getRoutines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting routines: %w", err)
+	}
+
+	for _, r := range routines {
+		if r.SQL, err = m.createRoutineSQL(ctx, r); err != nil {
+			return nil, fmt.Errorf("error creating %s %s: %w", r.Type, r.Name, err)
+		}
+	}
+
+	return routines, nil
+}
@@ -0,0 +1,120 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// sessionCharset is the character set of the dump session.
+	sessionCharset = "utf8mb4"
+
+	// sessionCollation is the collation of the dump session connection.
+	sessionCollation = "utf8mb4_general_ci"
+
+	// sessionTimeZone is the time zone of the dump session. TIMESTAMP values are dumped and restored in UTC.
+	sessionTimeZone = "+00:00"
+)
+
+// SessionConnStr returns the connection string configured for a dump session. Every connection uses the utf8mb4
+// character set and the UTC time zone, and time values are returned as they are stored rather than being parsed.
+// Without this, utf8mb4 data and TIMESTAMP values depend on the connection string and can be corrupted on restore.
+func SessionConnStr(connStr string) (string, error) {
+	cfg, err := mysql.ParseDSN(connStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing connection string: %w", err)
+	}
+
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+
+	cfg.Params["charset"] = sessionCharset
+	cfg.Params["time_zone"] = "'" + sessionTimeZone + "'"
+	cfg.Collation = sessionCollation
+	cfg.ParseTime = false
+
+	return cfg.FormatDSN(), nil
+}
+
+// Session is the character set, collation and SQL mode of the dumped database.
+type Session struct {
+	// CharacterSet is the default character set of the schema.
+	CharacterSet string
+
+	// Collation is the default collation of the schema.
+	Collation string
+
+	// SQLMode is the SQL mode of the dump session.
+	SQLMode string
+}
+
+// RestoreSQLMode returns the SQL mode to restore the dump with. This is the SQL mode of the dumped database with
+// NO_AUTO_VALUE_ON_ZERO added, so that zero values in AUTO_INCREMENT columns are not replaced on restore, and
+// NO_BACKSLASH_ESCAPES removed, as the values of the dump escape backslashes.
+func (s *Session) RestoreSQLMode() string {
+	modes := make([]string, 0)
+	if s.SQLMode != "" {
+		modes = strings.Split(s.SQLMode, ",")
+	}
+
+	modes = slices.DeleteFunc(modes, func(m string) bool {
+		return m == "NO_BACKSLASH_ESCAPES"
+	})
+
+	if !slices.Contains(modes, "NO_AUTO_VALUE_ON_ZERO") {
+		modes = append(modes, "NO_AUTO_VALUE_ON_ZERO")
+	}
+
+	return strings.Join(modes, ",")
+}
+
+func (d *Dumpster) getSession(ctx context.Context) (*Session, error) {
+	sqlStmt := "SELECT @@character_set_database, @@collation_database, @@SESSION.sql_mode, @@character_set_connection, @@SESSION.time_zone"
+
+	// Prepare statement for reading data, in the transaction of the snapshot if one is read
+	var db Queryer = d.db
+	if d.tx != nil {
+		db = d.tx
+	}
+
+	stmt, err := db.PrepareContext(ctx, sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing statement: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("Error closing statement", slog.String(logging.KeyError, err.Error()))
+		}
+	}(stmt)
+
+	s := new(Session)
+	var connCharset, timeZone string
+
+	// Execute statement
+	if err := stmt.QueryRowContext(ctx).Scan(&s.CharacterSet, &s.Collation, &s.SQLMode, &connCharset, &timeZone); err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if connCharset != sessionCharset || (timeZone != sessionTimeZone && timeZone != "UTC") {
+		slog.Warn("Dump session is not using utf8mb4 and UTC, data may not restore correctly. Use dumpster.SessionConnStr to configure the connection.",
+			slog.String("character_set_connection", connCharset),
+			slog.String("time_zone", timeZone),
+		)
+	}
+
+	if d.compat != CompatNone {
+		s.CharacterSet = compatCollation(s.CharacterSet)
+		s.Collation = compatCollation(s.Collation)
+	}
+
+	return s, nil
+}
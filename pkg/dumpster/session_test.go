@@ -0,0 +1,76 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionConnStr(t *testing.T) {
+	got, err := SessionConnStr("user:pass@tcp(localhost:3306)/test?parseTime=true&charset=latin1")
+	require.NoError(t, err)
+
+	cfg, err := mysql.ParseDSN(got)
+	require.NoError(t, err)
+	require.Equal(t, "utf8mb4", cfg.Params["charset"])
+	require.Equal(t, "'+00:00'", cfg.Params["time_zone"])
+	require.Equal(t, "utf8mb4_general_ci", cfg.Collation)
+	require.False(t, cfg.ParseTime)
+	require.Equal(t, "test", cfg.DBName)
+}
+
+func TestSession_RestoreSQLMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		sqlMode string
+		want    string
+	}{
+		{
+			name:    "empty",
+			sqlMode: "",
+			want:    "NO_AUTO_VALUE_ON_ZERO",
+		},
+		{
+			name:    "adds no auto value on zero",
+			sqlMode: "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION",
+			want:    "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION,NO_AUTO_VALUE_ON_ZERO",
+		},
+		{
+			name:    "already set",
+			sqlMode: "NO_AUTO_VALUE_ON_ZERO,STRICT_TRANS_TABLES",
+			want:    "NO_AUTO_VALUE_ON_ZERO,STRICT_TRANS_TABLES",
+		},
+		{
+			name:    "removes no backslash escapes",
+			sqlMode: "NO_BACKSLASH_ESCAPES,STRICT_TRANS_TABLES",
+			want:    "STRICT_TRANS_TABLES,NO_AUTO_VALUE_ON_ZERO",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{SQLMode: tt.sqlMode}
+			require.Equal(t, tt.want, s.RestoreSQLMode())
+		})
+	}
+}
+
+func TestTemplate_Session(t *testing.T) {
+	data := &TemplateData{
+		Database: "test",
+		Session: &Session{
+			CharacterSet: "utf8mb4",
+			Collation:    "utf8mb4_0900_ai_ci",
+			SQLMode:      "STRICT_TRANS_TABLES",
+		},
+	}
+
+	got, err := renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+	require.Contains(t, got, "SET NAMES utf8mb4;\n")
+	require.Contains(t, got, "SET TIME_ZONE='+00:00';\n")
+	require.Contains(t, got, "SET SQL_MODE='STRICT_TRANS_TABLES,NO_AUTO_VALUE_ON_ZERO';\n")
+	require.Contains(t, got, "SET SQL_MODE=@OLD_SQL_MODE;\n")
+	require.Contains(t, got, "SET TIME_ZONE=@OLD_TIME_ZONE;\n")
+}
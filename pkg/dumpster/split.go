@@ -0,0 +1,174 @@
+package dumpster
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+const (
+	// SplitIndexFile is the name of the index file of a split DDL. It sources every object file in apply order.
+	SplitIndexFile = "index.sql"
+
+	splitDirTables   = "tables"
+	splitDirViews    = "views"
+	splitDirRoutines = "routines"
+	splitDirTriggers = "triggers"
+)
+
+// DDLFile is a single file of a DDL split into one file per database object.
+type DDLFile struct {
+	// Path is the path of the file relative to the root of the split DDL, e.g. tables/users.sql or
+	// routines/cleanup.procedure.sql.
+	Path string
+
+	// SQL is the content of the file.
+	SQL string
+}
+
+// GetSplitDDL returns the DDL of the database as one file per database object, plus an index file that sources them
+// in the order they must be applied. If normalize is set, the objects are normalized as in GetNormalizedDDL.
+func (d *Dumpster) GetSplitDDL(ctx context.Context, normalize bool, stripDefiners bool) ([]*DDLFile, error) {
+	data, err := d.getDDL(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if normalize {
+		normalizeDDL(data, stripDefiners)
+	}
+
+	return splitDDL(data)
+}
+
+// splitDDL splits the DDL into one file per object. Tables are ordered so that referenced tables come first, then
+// routines, views and triggers.
+func splitDDL(data *TemplateData) ([]*DDLFile, error) {
+	tables, err := orderTables(data.Tables)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*DDLFile, 0, len(data.Tables)+len(data.Views)+len(data.Routines)+len(data.Triggers)+1)
+	for _, t := range tables {
+		files = append(files, &DDLFile{
+			Path: path.Join(splitDirTables, t.Name+".sql"),
+			SQL:  t.SQL + ";\n",
+		})
+	}
+
+	// MySQL does not check the tables a routine uses until it runs, so routines come before the views that call them.
+	// Routines and triggers contain semicolons in their bodies, so they need a different delimiter for the
+	// mysql client. A procedure and a function can have the same name, so the type is part of the file name.
+	for _, r := range data.Routines {
+		files = append(files, &DDLFile{
+			Path: path.Join(splitDirRoutines, r.Name+"."+strings.ToLower(r.Type)+".sql"),
+			SQL:  "DELIMITER ;;\n" + r.SQL + ";;\nDELIMITER ;\n",
+		})
+	}
+
+	for _, v := range orderViews(data.Views) {
+		files = append(files, &DDLFile{
+			Path: path.Join(splitDirViews, v.Name+".sql"),
+			SQL:  v.SQL + ";\n",
+		})
+	}
+
+	for _, t := range data.Triggers {
+		files = append(files, &DDLFile{
+			Path: path.Join(splitDirTriggers, t.Name+".sql"),
+			SQL:  "DELIMITER ;;\n" + t.SQL + ";;\nDELIMITER ;\n",
+		})
+	}
+
+	index := new(strings.Builder)
+	fmt.Fprintf(index, "-- Apply order for schema %s. Run from this directory with the mysql client.\n\n", data.Database)
+	index.WriteString("SET FOREIGN_KEY_CHECKS=0;\n\n")
+	for _, f := range files {
+		fmt.Fprintf(index, "SOURCE %s;\n", f.Path)
+	}
+	index.WriteString("\nSET FOREIGN_KEY_CHECKS=1;\n")
+
+	files = append(files, &DDLFile{
+		Path: SplitIndexFile,
+		SQL:  index.String(),
+	})
+
+	return files, nil
+}
+
+// orderTables orders the tables so that every table comes after the tables it references with foreign keys. Tables
+// without dependencies between them keep their order by name.
+func orderTables(tables []*Table) ([]*Table, error) {
+	deps := make(map[string][]string, len(tables))
+	for _, t := range tables {
+		parsed, err := sqlparse.ParseTable(t.SQL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing table %s: %w", t.Name, err)
+		}
+
+		deps[t.Name] = parsed.References()
+	}
+
+	return orderByDependencies(tables, func(t *Table) string { return t.Name }, func(t *Table) []string {
+		return deps[t.Name]
+	}), nil
+}
+
+// orderViews orders the views so that every view comes after the views it selects from.
+func orderViews(views []*View) []*View {
+	return orderByDependencies(views, func(v *View) string { return v.Name }, func(v *View) []string {
+		deps := make([]string, 0)
+		for _, other := range views {
+			if other.Name != v.Name && strings.Contains(v.SQL, sqlparse.QuoteIdentifier(other.Name)) {
+				deps = append(deps, other.Name)
+			}
+		}
+		return deps
+	})
+}
+
+// orderByDependencies sorts the items topologically by their dependencies, falling back to name order. Dependency
+// cycles are broken by name order.
+func orderByDependencies[T any](items []T, name func(T) string, dependsOn func(T) []string) []T {
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return name(sorted[i]) < name(sorted[j])
+	})
+
+	byName := make(map[string]T, len(sorted))
+	for _, item := range sorted {
+		byName[name(item)] = item
+	}
+
+	ordered := make([]T, 0, len(sorted))
+	visited := make(map[string]bool, len(sorted))
+
+	var visit func(item T)
+	visit = func(item T) {
+		n := name(item)
+		if visited[n] {
+			return
+		}
+		visited[n] = true
+
+		for _, dep := range dependsOn(item) {
+			if d, ok := byName[dep]; ok {
+				visit(d)
+			}
+		}
+
+		ordered = append(ordered, item)
+	}
+
+	for _, item := range sorted {
+		visit(item)
+	}
+
+	return ordered
+}
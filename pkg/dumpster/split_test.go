@@ -0,0 +1,55 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDDL(t *testing.T) {
+	data := &TemplateData{
+		Database: "test",
+		Tables: []*Table{
+			{Name: "accounts", SQL: "CREATE TABLE `accounts` (\n  `id` int NOT NULL,\n  `user_id` int NOT NULL,\n  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n)"},
+			{Name: "audit", SQL: "CREATE TABLE `audit` (\n  `id` int NOT NULL\n)"},
+			{Name: "users", SQL: "CREATE TABLE `users` (\n  `id` int NOT NULL,\n  `org_id` int NOT NULL,\n  CONSTRAINT `fk_org` FOREIGN KEY (`org_id`) REFERENCES `orgs` (`id`)\n)"},
+			{Name: "orgs", SQL: "CREATE TABLE `orgs` (\n  `id` int NOT NULL\n)"},
+		},
+		Views: []*View{
+			{Name: "a_view", SQL: "CREATE VIEW `a_view` AS select * from `b_view`"},
+			{Name: "b_view", SQL: "CREATE VIEW `b_view` AS select * from `users`"},
+		},
+		Routines: []*Routine{
+			{Name: "do_thing", Type: routineProcedure, SQL: "CREATE PROCEDURE `do_thing`() BEGIN SELECT 1; END"},
+			{Name: "do_thing", Type: routineFunction, SQL: "CREATE FUNCTION `do_thing`() RETURNS int RETURN 1"},
+		},
+		Triggers: []*Trigger{
+			{Name: "trg", SQL: "CREATE TRIGGER `trg` BEFORE INSERT ON `users` FOR EACH ROW SET NEW.id = 1"},
+		},
+	}
+
+	got, err := splitDDL(data)
+	require.NoError(t, err)
+
+	paths := make([]string, 0, len(got))
+	for _, f := range got {
+		paths = append(paths, f.Path)
+	}
+
+	require.Equal(t, []string{
+		"tables/orgs.sql",
+		"tables/users.sql",
+		"tables/accounts.sql",
+		"tables/audit.sql",
+		"routines/do_thing.procedure.sql",
+		"routines/do_thing.function.sql",
+		"views/b_view.sql",
+		"views/a_view.sql",
+		"triggers/trg.sql",
+		SplitIndexFile,
+	}, paths)
+
+	require.Equal(t, "DELIMITER ;;\nCREATE PROCEDURE `do_thing`() BEGIN SELECT 1; END;;\nDELIMITER ;\n", got[4].SQL)
+	require.Equal(t, "DELIMITER ;;\nCREATE FUNCTION `do_thing`() RETURNS int RETURN 1;;\nDELIMITER ;\n", got[5].SQL)
+	require.Contains(t, got[9].SQL, "SOURCE tables/orgs.sql;\nSOURCE tables/users.sql;\n")
+}
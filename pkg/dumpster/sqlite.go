@@ -0,0 +1,248 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// sqliteMainSchema is the name of the main database of a SQLite connection.
+const sqliteMainSchema = "main"
+
+// sqliteDialect is the Dialect for SQLite. The schema is read from sqlite_master. Values are written in their text
+// form, so BLOB values are only kept exactly by a backup file, see Dumpster.Backup.
+type sqliteDialect struct {
+	db Queryer
+}
+
+// NewSQLiteDialect returns the Dialect for SQLite.
+func NewSQLiteDialect(db Queryer) Dialect {
+	return &sqliteDialect{
+		db: db,
+	}
+}
+
+func (s *sqliteDialect) Name() string {
+	return DialectSQLite
+}
+
+// SchemaName returns the file name of the database without its extension, or main for an in-memory database.
+func (s *sqliteDialect) SchemaName(ctx context.Context) (string, error) {
+	var file string
+	if err := s.db.GetContext(ctx, &file, "SELECT file FROM pragma_database_list WHERE name = ?", sqliteMainSchema); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if file == "" {
+		return sqliteMainSchema, nil
+	}
+
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base)), nil
+}
+
+func (s *sqliteDialect) ServerVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := s.db.GetContext(ctx, &version, "SELECT sqlite_version()"); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if version == "" {
+		return "", errors.New("returned version is empty")
+	}
+
+	return version, nil
+}
+
+// objects returns the names of the objects of the type in sqlite_master, ordered by name. Internal objects are skipped.
+func (s *sqliteDialect) objects(ctx context.Context, objectType string) ([]string, error) {
+	names := make([]string, 0)
+	err := s.db.SelectContext(ctx, &names, "SELECT name FROM sqlite_master WHERE type = ? AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name", objectType)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	return names, nil
+}
+
+// objectSQL returns the statement that created the object.
+func (s *sqliteDialect) objectSQL(ctx context.Context, objectType, name string) (string, error) {
+	var objectSQL sql.NullString
+	if err := s.db.GetContext(ctx, &objectSQL, "SELECT sql FROM sqlite_master WHERE type = ? AND name = ?", objectType, name); err != nil {
+		return "", fmt.Errorf("error executing statement: %w", err)
+	}
+
+	if !objectSQL.Valid {
+		return "", fmt.Errorf("returned %s SQL is not valid", objectType)
+	}
+
+	return objectSQL.String, nil
+}
+
+func (s *sqliteDialect) Tables(ctx context.Context) ([]string, error) {
+	return s.objects(ctx, "table")
+}
+
+func (s *sqliteDialect) TableSQL(ctx context.Context, name string) (string, error) {
+	return s.objectSQL(ctx, "table", name)
+}
+
+// TableConstraintsSQL returns the indexes of the table, which are created after the data is loaded, and the
+// AUTOINCREMENT counter of the table.
+func (s *sqliteDialect) TableConstraintsSQL(ctx context.Context, name string) (string, error) {
+	statements := make([]string, 0)
+	err := s.db.SelectContext(ctx, &statements, "SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL ORDER BY name", name)
+	if err != nil {
+		return "", fmt.Errorf("error getting indexes: %w", err)
+	}
+
+	// sqlite_sequence only exists once a table with AUTOINCREMENT has been created.
+	var hasSequence bool
+	if err := s.db.GetContext(ctx, &hasSequence, "SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'"); err != nil {
+		return "", fmt.Errorf("error checking sqlite_sequence: %w", err)
+	}
+
+	if hasSequence {
+		seqs := make([]int64, 0)
+		if err := s.db.SelectContext(ctx, &seqs, "SELECT seq FROM sqlite_sequence WHERE name = ?", name); err != nil {
+			return "", fmt.Errorf("error getting sequence: %w", err)
+		}
+
+		for _, seq := range seqs {
+			quoted := s.QuoteValue(sql.NullString{String: name, Valid: true})
+			statements = append(statements,
+				"DELETE FROM sqlite_sequence WHERE name = "+quoted,
+				fmt.Sprintf("INSERT INTO sqlite_sequence (name, seq) VALUES (%s, %d)", quoted, seq))
+		}
+	}
+
+	return strings.Join(statements, ";\n"), nil
+}
+
+func (s *sqliteDialect) Triggers(ctx context.Context) ([]string, error) {
+	return s.objects(ctx, "trigger")
+}
+
+func (s *sqliteDialect) TriggerSQL(ctx context.Context, name string) (string, error) {
+	return s.objectSQL(ctx, "trigger", name)
+}
+
+func (s *sqliteDialect) Views(ctx context.Context) ([]string, error) {
+	return s.objects(ctx, "view")
+}
+
+func (s *sqliteDialect) ViewSQL(ctx context.Context, name string) (string, error) {
+	return s.objectSQL(ctx, "view", name)
+}
+
+// Routines returns no routines, as SQLite does not have stored procedures or functions.
+func (s *sqliteDialect) Routines(ctx context.Context) ([]*Routine, error) {
+	return make([]*Routine, 0), nil
+}
+
+// SchemaObjects returns no objects, as SQLite does not have user-defined types or sequences.
+func (s *sqliteDialect) SchemaObjects(ctx context.Context) ([]*SchemaObject, error) {
+	return make([]*SchemaObject, 0), nil
+}
+
+// TableEstimates returns the number of rows of each table. SQLite keeps no statistics of the size of a table, so the
+// rows are counted and the size is unknown.
+func (s *sqliteDialect) TableEstimates(ctx context.Context) ([]*TableEstimate, error) {
+	tables, err := s.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	estimates := make([]*TableEstimate, 0, len(tables))
+	for _, name := range tables {
+		e := &TableEstimate{Name: name}
+		if err := s.db.GetContext(ctx, &e.Rows, "SELECT count(*) FROM "+s.QuoteIdentifier(name)); err != nil {
+			return nil, fmt.Errorf("error counting rows: %w", err)
+		}
+
+		estimates = append(estimates, e)
+	}
+
+	return estimates, nil
+}
+
+func (s *sqliteDialect) KeyColumns(ctx context.Context, name string) ([]string, error) {
+	key := make([]string, 0)
+	err := s.db.SelectContext(ctx, &key, "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ReadRows reads the values as SQLite literals, as written by quote(), since SQLite values keep their own type whatever
+// the type of their column: NULL is returned as an invalid value, integers and reals unquoted, text quoted and blobs as
+// X'..' literals. Generated columns are not read, as they cannot be inserted.
+func (s *sqliteDialect) ReadRows(ctx context.Context, name string, r *RowRange, fn func(values []sql.NullString) error) ([]string, error) {
+	columns := make([]string, 0)
+	if err := s.db.SelectContext(ctx, &columns, "SELECT name FROM pragma_table_info(?) ORDER BY cid", name); err != nil {
+		return nil, fmt.Errorf("error getting columns: %w", err)
+	}
+
+	// The literals are not aliased to the names of their columns, which ORDER BY would then sort by.
+	literals := make([]string, len(columns))
+	for i, c := range columns {
+		literals[i] = "NULLIF(quote(" + s.QuoteIdentifier(c) + "), 'NULL')"
+	}
+
+	query := "SELECT " + strings.Join(literals, ", ") + " FROM " + s.QuoteIdentifier(name) + r.clause(sqliteLiterals{s})
+	if _, err := readRows(ctx, s.db, query, fn); err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+func (s *sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (s *sqliteDialect) QuoteValue(value sql.NullString) string {
+	if !value.Valid {
+		return "NULL"
+	}
+
+	return "'" + strings.ReplaceAll(value.String, "'", "''") + "'"
+}
+
+// sqliteLiterals is the SQLite dialect for the values read by ReadRows, which are already literals.
+type sqliteLiterals struct {
+	*sqliteDialect
+}
+
+func (s sqliteLiterals) QuoteValue(value sql.NullString) string {
+	if !value.Valid {
+		return "NULL"
+	}
+
+	return value.String
+}
+
+// Backup writes a consistent copy of the SQLite database to the file, which must not exist. The database can be
+// written to while the backup is taken.
+func (d *Dumpster) Backup(ctx context.Context, path string) error {
+	if d.dialect.Name() != DialectSQLite {
+		return fmt.Errorf("backup is not supported for %s", d.dialect.Name())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error backing up database: %w", err)
+	}
+
+	// The driver keeps a statement running on connections used with a cancellable context, so that it can interrupt
+	// them, and SQLite refuses to VACUUM while a statement is running. The backup cannot be cancelled once started.
+	if _, err := d.db.ExecContext(context.WithoutCancel(ctx), "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("error backing up database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package dumpster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/stretchr/testify/require"
+)
+
+const sqliteSchema = `CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, note TEXT);
+CREATE INDEX idx_users_name ON users (name);
+CREATE TABLE "order items" (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id));
+CREATE VIEW user_names AS SELECT name FROM users;
+CREATE TRIGGER users_ai AFTER INSERT ON users BEGIN UPDATE users SET note = 'new' WHERE id = NEW.id; END;
+INSERT INTO users (name) VALUES ('alice'), ('o''brien');
+INSERT INTO "order items" (id, user_id) VALUES (1, 2);`
+
+func newSQLiteDB(t *testing.T, path string) *sqlx.DB {
+	db, err := sqlx.Open(DialectSQLite, "file:"+path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	return db
+}
+
+func TestSQLite_Dump(t *testing.T) {
+	dir := t.TempDir()
+
+	src := newSQLiteDB(t, filepath.Join(dir, "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	d, err := NewDumpster(src)
+	require.NoError(t, err)
+
+	name, err := d.GetSchemaName(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "app", name)
+
+	dump, err := d.Dump(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, dump, "INSERT INTO \"users\" VALUES (1,'alice','new'),(2,'o''brien','new');\n")
+	require.Contains(t, dump, "CREATE INDEX idx_users_name ON users (name);\n")
+	require.Contains(t, dump, "INSERT INTO sqlite_sequence (name, seq) VALUES ('users', 2);\n")
+
+	// The dump restores into an empty database with the same rows, and the trigger does not fire during the restore.
+	dst := newSQLiteDB(t, filepath.Join(dir, "restored.db"))
+	_, err = dst.Exec(dump)
+	require.NoError(t, err)
+
+	var names []string
+	require.NoError(t, dst.Select(&names, "SELECT name FROM user_names ORDER BY name"))
+	require.Equal(t, []string{"alice", "o'brien"}, names)
+
+	var seq int
+	require.NoError(t, dst.Get(&seq, "SELECT seq FROM sqlite_sequence WHERE name = 'users'"))
+	require.Equal(t, 2, seq)
+}
+
+func TestSQLite_DumpTypes(t *testing.T) {
+	dir := t.TempDir()
+
+	// SQLite values keep their own type whatever the type of their column, and an untyped key is compared by type.
+	src := newSQLiteDB(t, filepath.Join(dir, "app.db"))
+	_, err := src.Exec(`CREATE TABLE files (k PRIMARY KEY, size INTEGER, ratio REAL, body BLOB, note);
+INSERT INTO files VALUES (10, 3, 0.5, x'00ff0a27', 'it''s'), (9, NULL, 1e300, x'', 7), ('10', -1, 2, NULL, 1.25);`)
+	require.NoError(t, err)
+
+	d, err := NewDumpster(src, WithChunkSize(1))
+	require.NoError(t, err)
+
+	dump, err := d.Dump(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, dump, "(10,3,0.5,X'00FF0A27','it''s')")
+
+	dst := newSQLiteDB(t, filepath.Join(dir, "restored.db"))
+	_, err = dst.Exec(dump)
+	require.NoError(t, err)
+
+	// Every value comes back with its type, and blobs byte for byte.
+	query := `SELECT quote(k), typeof(k), quote(size), quote(ratio), quote(body), typeof(body), quote(note), typeof(note)
+FROM files ORDER BY k`
+	want := make([][]string, 0)
+	got := make([][]string, 0)
+	for _, r := range []struct {
+		db   *sqlx.DB
+		rows *[][]string
+	}{{src, &want}, {dst, &got}} {
+		rows, err := r.db.Query(query)
+		require.NoError(t, err)
+
+		for rows.Next() {
+			values := make([]string, 8)
+			ptrs := make([]any, len(values))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+
+			require.NoError(t, rows.Scan(ptrs...))
+			*r.rows = append(*r.rows, values)
+		}
+
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+	}
+
+	require.Len(t, got, 3)
+	require.Equal(t, want, got)
+	require.Equal(t, []string{"'10'", "text", "-1", "2.0", "NULL", "null", "1.25", "real"}, got[2])
+}
+
+func TestSQLite_Backup(t *testing.T) {
+	dir := t.TempDir()
+
+	src := newSQLiteDB(t, filepath.Join(dir, "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "backup.sqlite")
+	d, err := NewDumpster(src)
+	require.NoError(t, err)
+
+	// Commands run with a cancellable context.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, d.Backup(ctx, path))
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, newSQLiteDB(t, path).Get(&count, `SELECT count(*) FROM "order items"`))
+	require.Equal(t, 1, count)
+}
+
+func TestSQLite_Cancel(t *testing.T) {
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	d, err := NewDumpster(src, WithSnapshot(true))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = d.Dump(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,150 @@
+package dumpster
+
+import (
+	"context"
+	"sync"
+)
+
+// rowStreams reads the rows of the tables of a dump as the template ranges over their batches. The tables are read one
+// at a time: starting a table first reads the rest of the previous one, so the rows are only read by one goroutine at
+// a time, in the order the template asks for them.
+type rowStreams struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// current is the table being read. It is only used from the goroutine rendering the dump.
+	current *tableStream
+
+	mu  sync.Mutex
+	err error
+}
+
+// newRowStreams returns the streams of a dump, reading with the context.
+func newRowStreams(ctx context.Context) *rowStreams {
+	ctx, cancel := context.WithCancel(ctx)
+	return &rowStreams{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// table returns the stream of a table, which calls read with a function sending each batch of its rows.
+func (s *rowStreams) table(read func(ctx context.Context, send func(batch string) error) error) *tableStream {
+	return &tableStream{
+		streams: s,
+		read:    read,
+		batches: make(chan string, 1),
+		ready:   make(chan struct{}),
+	}
+}
+
+// fail records the first error reading the rows, and stops reading.
+func (s *rowStreams) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+
+	s.cancel()
+}
+
+// failed returns the first error reading the rows.
+func (s *rowStreams) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// finish reads the rest of the table being read once the dump has been rendered, and returns the first error reading
+// the rows.
+func (s *rowStreams) finish() error {
+	s.drain()
+	s.cancel()
+	return s.failed()
+}
+
+// abort stops reading the rows once rendering the dump failed.
+func (s *rowStreams) abort() {
+	s.cancel()
+	s.drain()
+}
+
+// drain waits for the table being read, discarding the batches the template did not range over.
+func (s *rowStreams) drain() {
+	if s.current == nil {
+		return
+	}
+
+	for range s.current.batches {
+		// The batches are not written.
+	}
+}
+
+// tableStream reads the rows of a table in a goroutine, once the template asks for its batches.
+type tableStream struct {
+	streams *rowStreams
+	read    func(ctx context.Context, send func(batch string) error) error
+	once    sync.Once
+
+	// batches holds the next batch, so the next batch is read while the template writes the current one.
+	batches chan string
+
+	// ready is closed once the first batch has been sent, or the table has been read without any.
+	ready chan struct{}
+
+	// sent is whether a batch has been sent. It is set before ready is closed.
+	sent bool
+}
+
+// start starts reading the table, and returns the batches once the first one has been read. The batches are nil if the
+// table has no rows.
+func (t *tableStream) start() (<-chan string, error) {
+	t.once.Do(func() {
+		t.streams.drain()
+		t.streams.current = t
+		go t.run()
+	})
+
+	<-t.ready
+
+	if err := t.streams.failed(); err != nil {
+		return nil, err
+	}
+
+	if !t.sent {
+		return nil, nil
+	}
+
+	return t.batches, nil
+}
+
+// run reads the table and sends its batches, closing the batches once it has been read.
+func (t *tableStream) run() {
+	defer close(t.batches)
+
+	ctx := t.streams.ctx
+	err := t.read(ctx, func(batch string) error {
+		select {
+		case t.batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if !t.sent {
+			t.sent = true
+			close(t.ready)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.streams.fail(err)
+	}
+
+	if !t.sent {
+		close(t.ready)
+	}
+}
@@ -0,0 +1,106 @@
+package dumpster
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// staticStream returns the stream of a table with the batches.
+func staticStream(t *testing.T, batches ...string) *tableStream {
+	streams := newRowStreams(context.Background())
+	t.Cleanup(streams.abort)
+
+	return streams.table(func(_ context.Context, send func(batch string) error) error {
+		for _, b := range batches {
+			if err := send(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// syncBuffer is a buffer that can be read while the dump is written to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestDumpTo_Streams(t *testing.T) {
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(`CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+INSERT INTO events (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');`)
+	require.NoError(t, err)
+
+	t.Run("rows are written as they are read", func(t *testing.T) {
+		w := new(syncBuffer)
+		written := ""
+		d, err := NewDumpster(src, WithChunkSize(1), WithBatchSize(1), WithHooks(Hooks{
+			Row: func(_ string, _ []string, values []sql.NullString) error {
+				if values[0].String == "5" {
+					written = w.String()
+				}
+				return nil
+			},
+		}))
+		require.NoError(t, err)
+
+		require.NoError(t, d.DumpTo(context.Background(), w))
+
+		// The first row was written before the last row of the table was read.
+		require.Contains(t, written, "INSERT INTO \"events\" VALUES (1,'a');\n")
+		require.NotContains(t, written, "(5,'e')")
+		require.NotContains(t, written, "-- Checksum for table events")
+		require.Contains(t, w.String(), "INSERT INTO \"events\" VALUES (5,'e');\n")
+		require.Contains(t, w.String(), "-- Checksum for table events: rows=5 crc64=")
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		rowErr := errors.New("stop")
+		d, err := NewDumpster(src, WithBatchSize(1), WithHooks(Hooks{
+			Row: func(_ string, _ []string, values []sql.NullString) error {
+				if values[0].String == "3" {
+					return rowErr
+				}
+				return nil
+			},
+		}))
+		require.NoError(t, err)
+
+		_, err = d.Dump(context.Background())
+		require.ErrorIs(t, err, rowErr)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		tmpl, err := ParseTemplate(`{{ range .Tables }}{{ if .Values }}{{ .Name }}: {{ .Values }}{{ end }}{{ end }}`)
+		require.NoError(t, err)
+
+		d, err := NewDumpster(src, WithTemplate(tmpl), WithBatchSize(2))
+		require.NoError(t, err)
+
+		dump, err := d.Dump(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "events: (1,'a'),(2,'b'),(3,'c'),(4,'d'),(5,'e')", dump)
+	})
+}
@@ -1,31 +1,479 @@
 package dumpster
 
-const tmpl = `
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+var (
+	// defaultTemplate is the parsed DefaultTemplate.
+	defaultTemplate = template.Must(ParseTemplate(DefaultTemplate))
+
+	// defaultPostgresTemplate is the parsed DefaultPostgresTemplate.
+	defaultPostgresTemplate = template.Must(ParseTemplate(DefaultPostgresTemplate))
+
+	// defaultSQLiteTemplate is the parsed DefaultSQLiteTemplate.
+	defaultSQLiteTemplate = template.Must(ParseTemplate(DefaultSQLiteTemplate))
+)
+
+// templateFuncs are the helper functions available to every template.
+var templateFuncs = template.FuncMap{
+	"quoteIdentifier": sqlparse.QuoteIdentifier,
+	"quoteString":     quoteString,
+	"escapeString":    escapeString,
+	"join":            strings.Join,
+}
+
+// TemplateData is the data passed to the template that renders a dump or DDL. Fields are only ever added to it, so a
+// custom template keeps working across releases.
+type TemplateData struct {
+	// Dialect is the name of the dialect of the dumped database, mysql, postgres or sqlite3.
+	Dialect string
+
+	// Database is the name of the dumped schema.
+	Database string
+
+	// ServerVersion is the version of the MySQL server. It is empty in normalized DDL.
+	ServerVersion string
+
+	// Session is the character set, collation and SQL mode of the dumped schema. It is only set for MySQL.
+	Session *Session
+
+	// Objects are the extensions, types and standalone sequences of the schema, in the order they are created. They are
+	// only set for PostgreSQL.
+	Objects []*SchemaObject
+
+	// Tables are the tables of the schema.
+	Tables []*Table
+
+	// Triggers are the triggers of the schema.
+	Triggers []*Trigger
+
+	// Views are the views of the schema.
+	Views []*View
+
+	// Routines are the stored procedures and functions of the schema.
+	Routines []*Routine
+
+	// Accounts are the users and roles with privileges on the schema. They are only set when grants are included.
+	Accounts []*Account
+
+	// Options are the options the output was created with.
+	Options TemplateOptions
+
+	// StartTime is the time the dump started, in RFC 3339 format. It is empty in normalized DDL.
+	StartTime string
+
+	// started is when the dump started. The complete time and duration are empty if it is zero, as in normalized DDL.
+	started time.Time
+
+	// completed is when the dump completed. The rows of a dump are read as it is rendered, so this is set when the
+	// template first asks for it.
+	completed time.Time
+}
+
+// CompleteTime returns the time the dump completed, in RFC 3339 format. The rows of a dump are read as the template
+// ranges over them, so this is the time the template first uses CompleteTime or Duration, which the default templates
+// do after every table. It is empty in normalized DDL.
+func (d *TemplateData) CompleteTime() string {
+	if d.complete().IsZero() {
+		return ""
+	}
+
+	return d.completed.Format(time.RFC3339)
+}
+
+// Duration returns how long the dump took, up to CompleteTime. It is zero in normalized DDL.
+func (d *TemplateData) Duration() time.Duration {
+	if d.complete().IsZero() {
+		return 0
+	}
+
+	return d.completed.Sub(d.started)
+}
+
+// complete returns when the dump completed, taking the current time if it has not been set.
+func (d *TemplateData) complete() time.Time {
+	if d.started.IsZero() {
+		return time.Time{}
+	}
+
+	if d.completed.IsZero() {
+		d.completed = time.Now()
+	}
+
+	return d.completed
+}
+
+// TemplateOptions are the options a dump or DDL was created with.
+type TemplateOptions struct {
+	// Data is whether the table data is included, i.e. whether this is a dump rather than DDL.
+	Data bool
+
+	// Grants is whether the users, roles and grants are included.
+	Grants bool
+
+	// Normalized is whether volatile attributes have been removed for version control.
+	Normalized bool
+
+	// StripDefiners is whether the definers have been removed from views, triggers and routines.
+	StripDefiners bool
+}
+
+// Table is a table of the dumped schema.
+type Table struct {
+	// Name is the name of the table.
+	Name string
+
+	// SQL is the CREATE TABLE statement, without a trailing semicolon.
+	SQL string
+
+	// Columns are the names of the columns in Batches, in order. It is set once the first batch has been read, and is
+	// empty for DDL.
+	Columns []string
+
+	// ConstraintsSQL are the statements to run after every table has been created and loaded, such as foreign keys,
+	// separated by semicolons and without a trailing semicolon. It is empty for MySQL.
+	ConstraintsSQL string
+
+	// Rows is the number of rows of the table. It is set once the batches have been ranged over, and is zero for DDL
+	// and for tables dumped without their rows.
+	Rows int64
+
+	// Checksum is the checksum of the rows of the table as they were written, see TableChecksum. It is set once the
+	// batches have been ranged over, and is empty for DDL and for tables dumped without their rows.
+	Checksum string
+
+	// stream reads the rows of the table as the template ranges over its batches. It is nil for DDL.
+	stream *tableStream
+
+	// values are the rows of the table once Values has read them.
+	values *string
+}
+
+// Batches returns the lists of rows for each INSERT statement of the table, e.g. (1,'a'),(2,'b'). There is a single
+// batch unless a batch size is set. The rows are read from the database as the template ranges over the batches, so
+// only a batch at a time is held in memory. The batches can only be ranged over once. They are nil for DDL and for
+// tables without rows, and an error is returned if reading the rows has failed.
+func (t *Table) Batches() (<-chan string, error) {
+	if t.stream == nil {
+		return nil, nil
+	}
+
+	return t.stream.start()
+}
+
+// Values returns the list of every row of the table for a single INSERT statement. Unlike Batches, this holds every
+// row of the table in memory. It is empty once the batches have been ranged over.
+func (t *Table) Values() (string, error) {
+	if t.values != nil {
+		return *t.values, nil
+	}
+
+	batches, err := t.Batches()
+	if err != nil {
+		return "", err
+	}
+
+	all := make([]string, 0)
+	for b := range batches {
+		all = append(all, b)
+	}
+
+	values := strings.Join(all, ",")
+	t.values = &values
+
+	return values, nil
+}
+
+// Trigger is a trigger of the dumped schema.
+type Trigger struct {
+	// Name is the name of the trigger.
+	Name string
+
+	// SQL is the CREATE TRIGGER statement, without a trailing semicolon.
+	SQL string
+}
+
+// SchemaObject is an object of the dumped schema that tables can depend on, created before them.
+type SchemaObject struct {
+	// Type is EXTENSION, TYPE, DOMAIN or SEQUENCE.
+	Type string
+
+	// Name is the name of the object.
+	Name string
+
+	// SQL is the statement that creates the object, and for sequences the statement that sets their value, separated
+	// by semicolons and without a trailing semicolon.
+	SQL string
+}
+
+// View is a view of the dumped schema.
+type View struct {
+	// Name is the name of the view.
+	Name string
+
+	// SQL is the CREATE VIEW statement, without a trailing semicolon.
+	SQL string
+}
+
+// ParseTemplate parses a dump template. Besides the text/template builtins, the template can use the following
+// functions, which quote for the dialect of the dumped database:
+//
+//	quoteIdentifier  quotes a name with backticks, e.g. {{ quoteIdentifier .Name }}
+//	quoteString      quotes a string literal with single quotes
+//	escapeString     escapes a string for use inside a single quoted literal
+//	join             joins a list of strings with a separator, e.g. {{ join .Grants ";\n" }}
+func ParseTemplate(text string) (*template.Template, error) {
+	t, err := template.New("mysqldump").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+
+	return t, nil
+}
+
+// ParseTemplateFile parses a dump template from a file. See ParseTemplate.
+func ParseTemplateFile(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %w", err)
+	}
+
+	return ParseTemplate(string(b))
+}
+
+// render renders the data with the template set on the dumpster, or the default template of the dialect.
+func (d *Dumpster) render(data *TemplateData) (string, error) {
+	b := new(strings.Builder)
+	if err := d.renderTo(b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// renderTo renders the data to w. See render.
+func (d *Dumpster) renderTo(w io.Writer, data *TemplateData) error {
+	if d.hooks.BeforeRender != nil {
+		if err := d.hooks.BeforeRender(data); err != nil {
+			return err
+		}
+	}
+
+	dialect := d.outputDialect()
+
+	t := d.template
+	if t == nil {
+		switch dialect.Name() {
+		case DialectPostgres:
+			t = defaultPostgresTemplate
+		case DialectSQLite:
+			t = defaultSQLiteTemplate
+		default:
+			t = defaultTemplate
+		}
+	}
+
+	if dialect.Name() != DialectMySQL {
+		// The helper functions default to MySQL quoting.
+		clone, err := t.Clone()
+		if err != nil {
+			return fmt.Errorf("error cloning template: %w", err)
+		}
+
+		t = clone.Funcs(dialectFuncs(dialect))
+	}
+
+	if err := t.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
+
+	return nil
+}
+
+// dialectFuncs returns the quoting helper functions for the dialect.
+func dialectFuncs(dialect Dialect) template.FuncMap {
+	quote := func(s string) string {
+		return dialect.QuoteValue(sql.NullString{String: s, Valid: true})
+	}
+
+	return template.FuncMap{
+		"quoteIdentifier": dialect.QuoteIdentifier,
+		"quoteString":     quote,
+		"escapeString": func(s string) string {
+			q := quote(s)
+			return q[1 : len(q)-1]
+		},
+	}
+}
+
+// renderTemplate executes the template with the data.
+func renderTemplate(t *template.Template, data *TemplateData) (string, error) {
+	b := new(bytes.Buffer)
+	if err := t.Execute(b, data); err != nil {
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// escapeString escapes a string for use inside a single quoted MySQL string literal.
+func escapeString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `'`, `''`)
+}
+
+// DefaultTemplate is the template used when no template is set. It is a good starting point for a custom template.
+const DefaultTemplate = `{{ if .ServerVersion }}
 -- Server version	{{ .ServerVersion }}
+{{ end }}
+{{- with .Session }}
+-- Character set: {{ .CharacterSet }}, collation: {{ .Collation }}, SQL mode: {{ .SQLMode }}
 
-CREATE DATABASE IF NOT EXISTS {{ .Database }};
-USE {{ .Database }};
+SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT;
+SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS;
+SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION;
+SET NAMES utf8mb4;
+SET @OLD_TIME_ZONE=@@TIME_ZONE;
+SET TIME_ZONE='+00:00';
+SET @OLD_SQL_MODE=@@SQL_MODE;
+SET SQL_MODE='{{ .RestoreSQLMode }}';
+{{ end }}
+CREATE DATABASE IF NOT EXISTS {{ quoteIdentifier .Database }}{{ with .Session }} DEFAULT CHARACTER SET {{ .CharacterSet }} COLLATE {{ .Collation }}{{ end }};
+USE {{ quoteIdentifier .Database }};
 
 SET FOREIGN_KEY_CHECKS=0;
-{{ range .Tables}}
+{{ range $t := .Tables }}
 -- Table structure for table {{ .Name }}
 {{ .SQL }};
-{{ if .Values }}
+{{ if .Batches }}
 -- Data dump for table {{ .Name }}
-LOCK TABLES {{ .Name }} WRITE;
-
-INSERT INTO {{ .Name }} VALUES {{ .Values }};
-
+LOCK TABLES {{ quoteIdentifier .Name }} WRITE;
+{{ range .Batches }}
+INSERT INTO {{ quoteIdentifier $t.Name }} VALUES {{ . }};
+{{ end }}
 UNLOCK TABLES;
 {{ end }}
+{{- with .Checksum }}
+-- Checksum for table {{ $t.Name }}: rows={{ $t.Rows }} crc64={{ . }}
+{{ end }}
 {{- end }}
-
+{{ range .Routines }}
+-- Routine structure for {{ .Type }} {{ .Name }}
+{{ .SQL }};
+{{ end }}
+{{ range .Views }}
+-- View structure for view {{ .Name }}
+{{ .SQL }};
+{{ end }}
 SET FOREIGN_KEY_CHECKS=1;
-
 {{ range .Triggers }}
 -- Trigger structure for trigger {{ .Name }}
 {{ .SQL }};
 {{ end }}
+{{ if .Accounts }}
+-- Users, roles and grants
+{{ range .Accounts }}{{ .CreateSQL }};
+{{ end }}
+{{- range .Accounts }}{{ range .Grants }}{{ . }};
+{{ end }}{{ end }}{{ end }}
+{{- if .Session }}
+SET SQL_MODE=@OLD_SQL_MODE;
+SET TIME_ZONE=@OLD_TIME_ZONE;
+SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT;
+SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS;
+SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION;
+{{ end }}
+{{- if .CompleteTime }}
+-- Dump completed at {{ .CompleteTime }}
+{{ end }}`
 
+// DefaultPostgresTemplate is the template used for PostgreSQL when no template is set. It is restored with psql.
+const DefaultPostgresTemplate = `{{ if .ServerVersion }}
+-- Server version	{{ .ServerVersion }}
+{{ end }}
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+SET check_function_bodies = false;
+SET TIME ZONE 'UTC';
+
+BEGIN;
+{{ range .Objects }}
+-- Structure for {{ .Type }} {{ .Name }}
+{{ .SQL }};
+{{ end }}
+{{- range .Routines }}{{ if not .UsesTables }}
+-- Routine structure for {{ .Type }} {{ .Name }}
+{{ .SQL }};
+{{ end }}{{ end }}
+{{- range $t := .Tables }}
+-- Table structure for table {{ .Name }}
+{{ .SQL }};
+{{ if .Batches }}
+-- Data dump for table {{ .Name }}
+{{ range .Batches }}INSERT INTO {{ quoteIdentifier $t.Name }} ({{ range $i, $c := $t.Columns }}{{ if $i }}, {{ end }}{{ quoteIdentifier $c }}{{ end }}) OVERRIDING SYSTEM VALUE VALUES {{ . }};
+{{ end }}{{ end }}
+{{- with .Checksum }}
+-- Checksum for table {{ $t.Name }}: rows={{ $t.Rows }} crc64={{ . }}
+{{ end }}
+{{- end }}
+{{ range .Tables }}{{ if .ConstraintsSQL }}
+-- Constraints for table {{ .Name }}
+{{ .ConstraintsSQL }};
+{{ end }}{{ end }}
+{{- range .Views }}
+-- View structure for view {{ .Name }}
+{{ .SQL }};
+{{ end }}
+{{- range .Routines }}{{ if .UsesTables }}
+-- Routine structure for {{ .Type }} {{ .Name }}
+{{ .SQL }};
+{{ end }}{{ end }}
+{{- range .Triggers }}
+-- Trigger structure for trigger {{ .Name }}
+{{ .SQL }};
+{{ end }}
+COMMIT;
+{{ if .CompleteTime }}
+-- Dump completed at {{ .CompleteTime }}
+{{ end }}`
+
+// DefaultSQLiteTemplate is the template used for SQLite when no template is set. It is restored with the sqlite3 shell.
+const DefaultSQLiteTemplate = `PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+{{ range $t := .Tables }}
+-- Table structure for table {{ .Name }}
+{{ .SQL }};
+{{ if .Batches }}
+-- Data dump for table {{ .Name }}
+{{ range .Batches }}INSERT INTO {{ quoteIdentifier $t.Name }} VALUES {{ . }};
+{{ end }}{{ end }}
+{{- with .Checksum }}
+-- Checksum for table {{ $t.Name }}: rows={{ $t.Rows }} crc64={{ . }}
+{{ end }}
+{{- end }}
+{{ range .Tables }}{{ if .ConstraintsSQL }}
+-- Indexes for table {{ .Name }}
+{{ .ConstraintsSQL }};
+{{ end }}{{ end }}
+{{- range .Views }}
+-- View structure for view {{ .Name }}
+{{ .SQL }};
+{{ end }}
+{{- range .Triggers }}
+-- Trigger structure for trigger {{ .Name }}
+{{ .SQL }};
+{{ end }}
+COMMIT;
+{{ if .CompleteTime }}
 -- Dump completed at {{ .CompleteTime }}
-`
+{{ end }}`
@@ -0,0 +1,88 @@
+package dumpster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Default(t *testing.T) {
+	data := &TemplateData{
+		Database: "my-db",
+		Tables: []*Table{
+			{Name: "order", SQL: "CREATE TABLE `order` (`id` int)", stream: staticStream(t, "(1),(2)")},
+		},
+	}
+
+	got, err := renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+	require.Contains(t, got, "CREATE DATABASE IF NOT EXISTS `my-db`;\nUSE `my-db`;\n")
+	require.Contains(t, got, "LOCK TABLES `order` WRITE;\n\nINSERT INTO `order` VALUES (1),(2);\n")
+
+	// Views can call stored functions, so routines come first.
+	data.Tables[0].stream = nil
+	data.Views = []*View{{Name: "totals", SQL: "CREATE VIEW `totals` AS select `total`() AS `n`"}}
+	data.Routines = []*Routine{{Name: "total", Type: "FUNCTION", SQL: "CREATE FUNCTION `total`() RETURNS int RETURN 1"}}
+
+	got, err = renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+	require.Less(t, strings.Index(got, "CREATE FUNCTION `total`"), strings.Index(got, "CREATE VIEW `totals`"))
+}
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "quote identifier",
+			text: "{{ range .Tables }}TRUNCATE {{ quoteIdentifier .Name }};{{ end }}",
+			want: "TRUNCATE `we``ird`;",
+		},
+		{
+			name: "quote and escape string",
+			text: "{{ quoteString .Database }} {{ escapeString .Database }}",
+			want: `'it''s\\' it''s\\`,
+		},
+		{
+			name: "join",
+			text: `{{ range .Accounts }}{{ join .Grants ";" }}{{ end }}`,
+			want: "GRANT A;GRANT B",
+		},
+		{
+			name: "options",
+			text: "{{ if .Options.Data }}dump{{ else }}ddl{{ end }}",
+			want: "dump",
+		},
+		{
+			name:    "invalid",
+			text:    "{{ range .Tables }}",
+			wantErr: true,
+		},
+	}
+
+	data := &TemplateData{
+		Database: `it's\`,
+		Tables:   []*Table{{Name: "we`ird"}},
+		Accounts: []*Account{{Grants: []string{"GRANT A", "GRANT B"}}},
+		Options:  TemplateOptions{Data: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.text)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := renderTemplate(tmpl, data)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
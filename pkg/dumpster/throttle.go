@@ -0,0 +1,224 @@
+package dumpster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultProbeInterval is how often the load of the server is probed if the throttle does not set an interval.
+const defaultProbeInterval = time.Second
+
+// Probe returns a measure of the load of the database server, such as the number of running threads.
+type Probe func(ctx context.Context) (float64, error)
+
+// Throttle limits how fast the rows of a dump are read, to protect a production database server.
+type Throttle struct {
+	// RowsPerSecond is the maximum number of rows read per second. Zero is unlimited.
+	RowsPerSecond int
+
+	// BytesPerSecond is the maximum number of bytes of values read per second. Zero is unlimited.
+	BytesPerSecond int
+
+	// Probe measures the load of the server. Reading pauses while the load is above the threshold. The load is not
+	// probed if nil.
+	Probe Probe
+
+	// Threshold is the load above which reading pauses.
+	Threshold float64
+
+	// Interval is how often the load is probed, between chunks and while paused. One second is used if zero.
+	Interval time.Duration
+}
+
+// WithThrottle limits how fast the rows of each dump are read.
+func WithThrottle(t Throttle) Option {
+	return func(d *Dumpster) error {
+		if t.RowsPerSecond < 0 || t.BytesPerSecond < 0 {
+			return errors.New("throttle rates must not be negative")
+		}
+
+		if t.Interval < 0 {
+			return errors.New("throttle interval must not be negative")
+		}
+
+		d.throttle = t
+		return nil
+	}
+}
+
+// ThreadsRunningProbe returns a probe of the number of threads running on a MySQL server, from the Threads_running
+// status variable.
+func ThreadsRunningProbe(db Queryer) Probe {
+	return func(ctx context.Context) (float64, error) {
+		var name, value string
+		if err := db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &value); err != nil {
+			return 0, fmt.Errorf("error getting Threads_running: %w", err)
+		}
+
+		threads, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing Threads_running: %w", err)
+		}
+
+		return threads, nil
+	}
+}
+
+// SQLProbe returns a probe that runs the query, which must return a single number.
+func SQLProbe(db Queryer, query string) Probe {
+	return func(ctx context.Context) (float64, error) {
+		var load float64
+		if err := db.GetContext(ctx, &load, query); err != nil {
+			return 0, fmt.Errorf("error running probe: %w", err)
+		}
+
+		return load, nil
+	}
+}
+
+// throttler applies the throttle and the replica lag limits of a dump. A nil throttler does not limit reading.
+type throttler struct {
+	rows      *rate.Limiter
+	bytes     *rate.Limiter
+	probe     Probe
+	threshold float64
+	interval  time.Duration
+	lastProbe time.Time
+	lag       *lagMonitor
+}
+
+// newThrottler returns the throttler of a dump, or nil if the dump is not throttled.
+func (d *Dumpster) newThrottler() *throttler {
+	t := d.throttle
+	if t.RowsPerSecond == 0 && t.BytesPerSecond == 0 && t.Probe == nil && d.replicaLag.Pause == 0 {
+		return nil
+	}
+
+	th := &throttler{
+		rows:      newLimiter(t.RowsPerSecond),
+		bytes:     newLimiter(t.BytesPerSecond),
+		probe:     t.Probe,
+		threshold: t.Threshold,
+		interval:  t.Interval,
+	}
+
+	if th.interval == 0 {
+		th.interval = defaultProbeInterval
+	}
+
+	if d.replicaLag.Pause > 0 {
+		th.lag = &lagMonitor{
+			limits: d.replicaLag,
+			status: d.getReplicaStatus,
+		}
+
+		if th.lag.limits.Interval == 0 {
+			th.lag.limits.Interval = defaultProbeInterval
+		}
+	}
+
+	return th
+}
+
+// start checks the state of replication before the first table is read.
+func (t *throttler) start(ctx context.Context) error {
+	if t == nil || t.lag == nil {
+		return nil
+	}
+
+	return t.lag.start(ctx)
+}
+
+// newLimiter returns a limiter of the rate per second, or nil if the rate is unlimited. A tenth of a second of the rate
+// can be used at once, so reading is spread evenly over each second.
+func newLimiter(perSecond int) *rate.Limiter {
+	if perSecond == 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(perSecond), max(perSecond/10, 1))
+}
+
+// wait blocks until a row of the given size can be read.
+func (t *throttler) wait(ctx context.Context, size int) error {
+	if t == nil {
+		return nil
+	}
+
+	if err := t.checkLoad(ctx); err != nil {
+		return err
+	}
+
+	if t.rows != nil {
+		if err := t.rows.Wait(ctx); err != nil {
+			return fmt.Errorf("error throttling rows: %w", err)
+		}
+	}
+
+	// Rows larger than the burst of the limiter are waited for in parts.
+	for t.bytes != nil && size > 0 {
+		n := min(size, t.bytes.Burst())
+		if err := t.bytes.WaitN(ctx, n); err != nil {
+			return fmt.Errorf("error throttling bytes: %w", err)
+		}
+
+		size -= n
+	}
+
+	return nil
+}
+
+// checkLoad probes the load of the server and the replication lag if their intervals have passed since they were last
+// checked, and blocks while either is above its limit.
+func (t *throttler) checkLoad(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	if t.lag != nil {
+		if err := t.lag.check(ctx); err != nil {
+			return err
+		}
+	}
+
+	if t.probe == nil || time.Since(t.lastProbe) < t.interval {
+		return nil
+	}
+
+	paused := false
+	for {
+		load, err := t.probe(ctx)
+		if err != nil {
+			return fmt.Errorf("error probing server load: %w", err)
+		}
+
+		t.lastProbe = time.Now()
+		if load <= t.threshold {
+			if paused {
+				slog.Info("Server load is below the threshold, resuming the dump", slog.Float64("load", load))
+			}
+
+			return nil
+		}
+
+		if !paused {
+			slog.Info("Server load is above the threshold, pausing the dump",
+				slog.Float64("load", load),
+				slog.Float64("threshold", t.threshold),
+			)
+			paused = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.interval):
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	src := newSQLiteDB(t, filepath.Join(t.TempDir(), "app.db"))
+	_, err := src.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	t.Run("rates", func(t *testing.T) {
+		th := (&Dumpster{throttle: Throttle{RowsPerSecond: 100, BytesPerSecond: 1000}}).newThrottler()
+
+		// A tenth of a second of rows is read at once, the rest at the rate.
+		start := time.Now()
+		for i := 0; i < 30; i++ {
+			require.NoError(t, th.wait(context.Background(), 1))
+		}
+		require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+		// Rows larger than the burst are read in parts.
+		require.NoError(t, th.wait(context.Background(), 250))
+	})
+
+	t.Run("probe", func(t *testing.T) {
+		loads := []float64{10, 10, 2}
+		calls := 0
+		d, err := NewDumpster(src, WithThrottle(Throttle{
+			Probe: func(context.Context) (float64, error) {
+				load := loads[min(calls, len(loads)-1)]
+				calls++
+				return load, nil
+			},
+			Threshold: 5,
+			Interval:  time.Millisecond,
+		}))
+		require.NoError(t, err)
+
+		_, err = d.Dump(context.Background())
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, calls, len(loads))
+
+		probeErr := errors.New("probe failed")
+		d, err = NewDumpster(src, WithThrottle(Throttle{
+			Probe: func(context.Context) (float64, error) { return 0, probeErr },
+		}))
+		require.NoError(t, err)
+
+		_, err = d.Dump(context.Background())
+		require.ErrorIs(t, err, probeErr)
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		db := newSQLiteDB(t, filepath.Join(t.TempDir(), "chunks.db"))
+		_, err := db.Exec(`CREATE TABLE pairs (a TEXT, b INTEGER, PRIMARY KEY (a, b));
+INSERT INTO pairs VALUES ('y', 2), ('x', 10), ('y', 1), ('x', 9), ('z', 1), ('x', 2), ('y', 3);
+CREATE TABLE notes (body TEXT);
+INSERT INTO notes VALUES ('b'), ('a');`)
+		require.NoError(t, err)
+
+		d, err := NewDumpster(db, WithChunkSize(3))
+		require.NoError(t, err)
+
+		// Rows are read in key order across the chunks, and no query is open while they are handled.
+		got := make([]string, 0)
+		columns, err := d.readTableRows(context.Background(), "pairs", func(_ []string, values []sql.NullString) error {
+			require.Zero(t, db.Stats().InUse)
+			got = append(got, values[0].String+values[1].String)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, columns)
+		require.Equal(t, []string{"'x'2", "'x'9", "'x'10", "'y'1", "'y'2", "'y'3", "'z'1"}, got)
+
+		// Tables without a primary key are read in a single query.
+		got = got[:0]
+		_, err = d.readTableRows(context.Background(), "notes", func(_ []string, values []sql.NullString) error {
+			require.Zero(t, db.Stats().InUse)
+			got = append(got, values[0].String)
+			return nil
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"'a'", "'b'"}, got)
+	})
+
+	t.Run("sql probe", func(t *testing.T) {
+		load, err := SQLProbe(src, "SELECT count(*) FROM users")(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, float64(2), load)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewDumpster(src, WithThrottle(Throttle{RowsPerSecond: -1}))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,207 @@
+package dumpster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/logging"
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+var (
+	// createDatabaseRegex matches the CREATE DATABASE line written at the top of a dump.
+	createDatabaseRegex = regexp.MustCompile(`(?m)^(CREATE DATABASE IF NOT EXISTS )\S+((?: .*)?;)$`)
+
+	// useDatabaseRegex matches the USE line written at the top of a dump.
+	useDatabaseRegex = regexp.MustCompile(`(?m)^USE \S+;$`)
+
+	// useStatementRegex matches any line that starts a USE statement, including those of custom templates that
+	// useDatabaseRegex does not match.
+	useStatementRegex = regexp.MustCompile(`(?im)^\s*USE\b`)
+)
+
+// TableStats is the row count and checksum of a table.
+type TableStats struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+
+	// Rows is the number of rows in the table.
+	Rows int64 `json:"rows"`
+
+	// Checksum is the result of CHECKSUM TABLE for the table.
+	Checksum int64 `json:"checksum"`
+}
+
+// TableResult is the comparison of a single table between the source and the restored schema.
+type TableResult struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+
+	// Source is the stats of the table in the source schema. This is nil if the table is missing from the source.
+	Source *TableStats `json:"source"`
+
+	// Restored is the stats of the table in the restored schema. This is nil if the table was not restored.
+	Restored *TableStats `json:"restored"`
+
+	// Match is true when the row count and checksum are the same in both schemas.
+	Match bool `json:"match"`
+}
+
+// VerifyReport is the result of comparing a restored dump against its source.
+type VerifyReport struct {
+	// Tables is the result for each table.
+	Tables []*TableResult `json:"tables"`
+
+	// OK is true when every table matches.
+	OK bool `json:"ok"`
+}
+
+// RestoreInto executes the given dump against the database, restoring it into the given schema instead of the schema
+// the dump was taken from.
+func (d *Dumpster) RestoreInto(ctx context.Context, dump string, schema string) error {
+	dump, err := intoSchema(dump, schema)
+	if err != nil {
+		return err
+	}
+
+	return d.Restore(ctx, dump)
+}
+
+// intoSchema rewrites the CREATE DATABASE and USE lines of the dump to the schema. A dump from a custom template
+// without a USE line would otherwise be restored into the database of the connection, so the schema is created and
+// selected before the rest of the dump. An error is returned if the dump has a USE statement that cannot be
+// rewritten.
+func intoSchema(dump, schema string) (string, error) {
+	if len(useStatementRegex.FindAllStringIndex(dump, -1)) != len(useDatabaseRegex.FindAllStringIndex(dump, -1)) {
+		return "", errors.New("the dump selects a database with a USE statement that cannot be rewritten, USE statements must be on a line of their own")
+	}
+
+	name := sqlparse.QuoteIdentifier(schema)
+	use := "USE " + name + ";"
+
+	dump = createDatabaseRegex.ReplaceAllString(dump, "${1}"+strings.ReplaceAll(name, "$", "$$")+"${2}")
+	if useDatabaseRegex.MatchString(dump) {
+		return useDatabaseRegex.ReplaceAllLiteralString(dump, use), nil
+	}
+
+	// Select the schema once the dump has created it.
+	if loc := createDatabaseRegex.FindStringIndex(dump); loc != nil {
+		return dump[:loc[1]] + "\n" + use + dump[loc[1]:], nil
+	}
+
+	return "CREATE DATABASE IF NOT EXISTS " + name + ";\n" + use + "\n" + dump, nil
+}
+
+// DropSchema drops the given schema if it exists.
+func (d *Dumpster) DropSchema(ctx context.Context, schema string) error {
+	if _, err := d.db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+sqlparse.QuoteIdentifier(schema)); err != nil {
+		return fmt.Errorf("error dropping schema: %w", err)
+	}
+
+	return nil
+}
+
+// GetTableStats returns the row count and checksum of every table in the given schema.
+func (d *Dumpster) GetTableStats(ctx context.Context, schema string) ([]*TableStats, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'", schema)
+	if err != nil {
+		return nil, fmt.Errorf("error executing statement: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", slog.String(logging.KeyError, err.Error()))
+		}
+	}(rows)
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+
+		tables = append(tables, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading tables: %w", err)
+	}
+
+	stats := make([]*TableStats, 0, len(tables))
+	for _, t := range tables {
+		name := sqlparse.QuoteIdentifier(schema) + "." + sqlparse.QuoteIdentifier(t)
+
+		s := &TableStats{
+			Name: t,
+		}
+
+		if err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+name).Scan(&s.Rows); err != nil {
+			return nil, fmt.Errorf("error counting rows for table %s: %w", t, err)
+		}
+
+		var checksumTable string
+		var checksum sql.NullInt64
+		if err := d.db.QueryRowContext(ctx, "CHECKSUM TABLE "+name).Scan(&checksumTable, &checksum); err != nil {
+			return nil, fmt.Errorf("error getting checksum for table %s: %w", t, err)
+		}
+
+		s.Checksum = checksum.Int64
+
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// CompareTableStats compares the stats of the source schema against the restored schema.
+func CompareTableStats(source, restored []*TableStats) *VerifyReport {
+	results := make(map[string]*TableResult)
+	for _, s := range source {
+		results[s.Name] = &TableResult{
+			Name:   s.Name,
+			Source: s,
+		}
+	}
+
+	for _, r := range restored {
+		res, ok := results[r.Name]
+		if !ok {
+			res = &TableResult{
+				Name: r.Name,
+			}
+			results[r.Name] = res
+		}
+
+		res.Restored = r
+	}
+
+	report := &VerifyReport{
+		Tables: make([]*TableResult, 0, len(results)),
+		OK:     true,
+	}
+
+	for _, res := range results {
+		res.Match = res.Source != nil && res.Restored != nil &&
+			res.Source.Rows == res.Restored.Rows &&
+			res.Source.Checksum == res.Restored.Checksum
+
+		if !res.Match {
+			report.OK = false
+		}
+
+		report.Tables = append(report.Tables, res)
+	}
+
+	sort.Slice(report.Tables, func(i, j int) bool {
+		return report.Tables[i].Name < report.Tables[j].Name
+	})
+
+	return report
+}
@@ -0,0 +1,136 @@
+package dumpster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareTableStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   []*TableStats
+		restored []*TableStats
+		wantOK   bool
+		want     map[string]bool
+	}{
+		{
+			name: "all match",
+			source: []*TableStats{
+				{Name: "users", Rows: 10, Checksum: 123},
+				{Name: "orders", Rows: 5, Checksum: 456},
+			},
+			restored: []*TableStats{
+				{Name: "orders", Rows: 5, Checksum: 456},
+				{Name: "users", Rows: 10, Checksum: 123},
+			},
+			wantOK: true,
+			want:   map[string]bool{"orders": true, "users": true},
+		},
+		{
+			name: "row count mismatch",
+			source: []*TableStats{
+				{Name: "users", Rows: 10, Checksum: 123},
+			},
+			restored: []*TableStats{
+				{Name: "users", Rows: 9, Checksum: 123},
+			},
+			wantOK: false,
+			want:   map[string]bool{"users": false},
+		},
+		{
+			name: "checksum mismatch",
+			source: []*TableStats{
+				{Name: "users", Rows: 10, Checksum: 123},
+			},
+			restored: []*TableStats{
+				{Name: "users", Rows: 10, Checksum: 321},
+			},
+			wantOK: false,
+			want:   map[string]bool{"users": false},
+		},
+		{
+			name: "missing and extra tables",
+			source: []*TableStats{
+				{Name: "users", Rows: 10, Checksum: 123},
+			},
+			restored: []*TableStats{
+				{Name: "orders", Rows: 5, Checksum: 456},
+			},
+			wantOK: false,
+			want:   map[string]bool{"orders": false, "users": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareTableStats(tt.source, tt.restored)
+			require.Equal(t, tt.wantOK, got.OK)
+			require.Len(t, got.Tables, len(tt.want))
+			for _, res := range got.Tables {
+				require.Equal(t, tt.want[res.Name], res.Match, "table %s", res.Name)
+			}
+		})
+	}
+}
+
+func TestRestoreIntoRewrite(t *testing.T) {
+	data := &TemplateData{
+		Database: "live",
+		Session: &Session{
+			CharacterSet: "utf8mb4",
+			Collation:    "utf8mb4_0900_ai_ci",
+			SQLMode:      "STRICT_TRANS_TABLES",
+		},
+	}
+
+	dump, err := renderTemplate(defaultTemplate, data)
+	require.NoError(t, err)
+
+	got, err := intoSchema(dump, "scratch")
+	require.NoError(t, err)
+	require.Contains(t, got, "CREATE DATABASE IF NOT EXISTS `scratch` DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci;\nUSE `scratch`;\n")
+	require.NotContains(t, got, "live")
+
+	got, err = intoSchema(dump, "scratch$1")
+	require.NoError(t, err)
+	require.Contains(t, got, "CREATE DATABASE IF NOT EXISTS `scratch$1` DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci;\nUSE `scratch$1`;\n")
+}
+
+func TestIntoSchema_CustomTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no database",
+			dump: "CREATE TABLE `users` (`id` int);\n",
+			want: "CREATE DATABASE IF NOT EXISTS `scratch`;\nUSE `scratch`;\nCREATE TABLE `users` (`id` int);\n",
+		},
+		{
+			name: "create without use",
+			dump: "CREATE DATABASE IF NOT EXISTS `live`;\nCREATE TABLE `users` (`id` int);\n",
+			want: "CREATE DATABASE IF NOT EXISTS `scratch`;\nUSE `scratch`;\nCREATE TABLE `users` (`id` int);\n",
+		},
+		{
+			name:    "use that cannot be rewritten",
+			dump:    "use `live` ;\nCREATE TABLE `users` (`id` int);\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intoSchema(tt.dump, "scratch")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
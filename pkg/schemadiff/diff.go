@@ -0,0 +1,268 @@
+package schemadiff
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+// ChangeType is the type of change made to an object.
+type ChangeType string
+
+const (
+	// Added is an object that only exists in the target schema.
+	Added ChangeType = "added"
+
+	// Removed is an object that only exists in the source schema.
+	Removed ChangeType = "removed"
+
+	// Changed is an object that exists in both schemas with a different definition.
+	Changed ChangeType = "changed"
+)
+
+const (
+	// ElementColumn is a column of a table.
+	ElementColumn = "column"
+
+	// ElementIndex is an index of a table.
+	ElementIndex = "index"
+
+	// ElementForeignKey is a foreign key of a table.
+	ElementForeignKey = "foreign_key"
+
+	// ElementCheck is a check constraint of a table.
+	ElementCheck = "check"
+
+	// ElementOptions is the table options of a table.
+	ElementOptions = "options"
+)
+
+var (
+	// whitespaceRegex matches runs of whitespace.
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+
+	// autoIncrementRegex matches the AUTO_INCREMENT table option, which changes with the data and not the schema.
+	autoIncrementRegex = regexp.MustCompile(`(?i)\s*AUTO_INCREMENT=\d+`)
+)
+
+// Change is a change to a single element of a table.
+type Change struct {
+	// Element is the type of element that changed, such as column or index.
+	Element string `json:"element"`
+
+	// Name is the name of the element.
+	Name string `json:"name"`
+
+	// Type is the type of change.
+	Type ChangeType `json:"change"`
+
+	// From is the definition in the source schema.
+	From string `json:"from,omitempty"`
+
+	// To is the definition in the target schema.
+	To string `json:"to,omitempty"`
+}
+
+// TableDiff is the difference of a single table.
+type TableDiff struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+
+	// Type is the type of change.
+	Type ChangeType `json:"change"`
+
+	// Changes are the changes made to the table. This is only set for changed tables.
+	Changes []*Change `json:"changes,omitempty"`
+
+	// from is the table in the source schema.
+	from *sqlparse.Table
+
+	// to is the table in the target schema.
+	to *sqlparse.Table
+}
+
+// ObjectDiff is the difference of a single view or trigger.
+type ObjectDiff struct {
+	// Name is the name of the object.
+	Name string `json:"name"`
+
+	// Type is the type of change.
+	Type ChangeType `json:"change"`
+
+	// From is the definition in the source schema.
+	From string `json:"from,omitempty"`
+
+	// To is the definition in the target schema.
+	To string `json:"to,omitempty"`
+}
+
+// Result is the difference between two schemas.
+type Result struct {
+	// Tables are the tables that differ.
+	Tables []*TableDiff `json:"tables"`
+
+	// Views are the views that differ.
+	Views []*ObjectDiff `json:"views"`
+
+	// Triggers are the triggers that differ.
+	Triggers []*ObjectDiff `json:"triggers"`
+}
+
+// Empty reports whether the schemas are the same.
+func (r *Result) Empty() bool {
+	return len(r.Tables) == 0 && len(r.Views) == 0 && len(r.Triggers) == 0
+}
+
+// Diff returns the changes needed to go from the source schema to the target schema.
+func Diff(from, to *Schema) *Result {
+	r := &Result{
+		Tables:   make([]*TableDiff, 0),
+		Views:    diffObjects(from.Views, to.Views),
+		Triggers: diffObjects(from.Triggers, to.Triggers),
+	}
+
+	fromTables := make(map[string]*sqlparse.Table, len(from.Tables))
+	for _, t := range from.Tables {
+		fromTables[t.Name] = t
+	}
+
+	toTables := make(map[string]*sqlparse.Table, len(to.Tables))
+	for _, t := range to.Tables {
+		toTables[t.Name] = t
+	}
+
+	for _, name := range unionKeys(fromTables, toTables) {
+		f, t := fromTables[name], toTables[name]
+		switch {
+		case f == nil:
+			r.Tables = append(r.Tables, &TableDiff{Name: name, Type: Added, to: t})
+		case t == nil:
+			r.Tables = append(r.Tables, &TableDiff{Name: name, Type: Removed, from: f})
+		default:
+			if changes := diffTable(f, t); len(changes) > 0 {
+				r.Tables = append(r.Tables, &TableDiff{Name: name, Type: Changed, Changes: changes, from: f, to: t})
+			}
+		}
+	}
+
+	return r
+}
+
+// diffTable returns the changes between two versions of a table.
+func diffTable(from, to *sqlparse.Table) []*Change {
+	changes := make([]*Change, 0)
+
+	fromCols := make(map[string]string, len(from.Columns))
+	for _, c := range from.Columns {
+		fromCols[c.Name] = c.Definition
+	}
+	toCols := make(map[string]string, len(to.Columns))
+	for _, c := range to.Columns {
+		toCols[c.Name] = c.Definition
+	}
+	changes = append(changes, diffDefinitions(ElementColumn, fromCols, toCols)...)
+
+	fromIdx := make(map[string]string, len(from.Indexes))
+	for _, i := range from.Indexes {
+		fromIdx[i.Name] = i.Definition
+	}
+	toIdx := make(map[string]string, len(to.Indexes))
+	for _, i := range to.Indexes {
+		toIdx[i.Name] = i.Definition
+	}
+	changes = append(changes, diffDefinitions(ElementIndex, fromIdx, toIdx)...)
+
+	changes = append(changes, diffDefinitions(ElementForeignKey, constraintMap(from.ForeignKeys), constraintMap(to.ForeignKeys))...)
+	changes = append(changes, diffDefinitions(ElementCheck, constraintMap(from.Checks), constraintMap(to.Checks))...)
+
+	fromOpts := normalize(autoIncrementRegex.ReplaceAllString(from.Options, ""))
+	toOpts := normalize(autoIncrementRegex.ReplaceAllString(to.Options, ""))
+	if fromOpts != toOpts {
+		changes = append(changes, &Change{
+			Element: ElementOptions,
+			Name:    to.Name,
+			Type:    Changed,
+			From:    fromOpts,
+			To:      toOpts,
+		})
+	}
+
+	return changes
+}
+
+// diffDefinitions compares named definitions of a single element type.
+func diffDefinitions(element string, from, to map[string]string) []*Change {
+	changes := make([]*Change, 0)
+	for _, name := range unionKeys(from, to) {
+		f, inFrom := from[name]
+		t, inTo := to[name]
+		switch {
+		case !inFrom:
+			changes = append(changes, &Change{Element: element, Name: name, Type: Added, To: t})
+		case !inTo:
+			changes = append(changes, &Change{Element: element, Name: name, Type: Removed, From: f})
+		case normalize(f) != normalize(t):
+			changes = append(changes, &Change{Element: element, Name: name, Type: Changed, From: f, To: t})
+		}
+	}
+	return changes
+}
+
+// diffObjects compares views or triggers. Definers are ignored as they usually differ between environments.
+func diffObjects(from, to []*sqlparse.Object) []*ObjectDiff {
+	fromObjs := make(map[string]string, len(from))
+	for _, o := range from {
+		fromObjs[o.Name] = o.SQL
+	}
+
+	toObjs := make(map[string]string, len(to))
+	for _, o := range to {
+		toObjs[o.Name] = o.SQL
+	}
+
+	diffs := make([]*ObjectDiff, 0)
+	for _, name := range unionKeys(fromObjs, toObjs) {
+		f, inFrom := fromObjs[name]
+		t, inTo := toObjs[name]
+		switch {
+		case !inFrom:
+			diffs = append(diffs, &ObjectDiff{Name: name, Type: Added, To: t})
+		case !inTo:
+			diffs = append(diffs, &ObjectDiff{Name: name, Type: Removed, From: f})
+		case normalize(sqlparse.StripDefiner(f)) != normalize(sqlparse.StripDefiner(t)):
+			diffs = append(diffs, &ObjectDiff{Name: name, Type: Changed, From: f, To: t})
+		}
+	}
+	return diffs
+}
+
+// constraintMap returns the constraints keyed by name.
+func constraintMap(constraints []*sqlparse.Constraint) map[string]string {
+	m := make(map[string]string, len(constraints))
+	for _, c := range constraints {
+		m[c.Name] = c.Definition
+	}
+	return m
+}
+
+// normalize collapses whitespace so formatting differences are not reported.
+func normalize(s string) string {
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(s, " "))
+}
+
+// unionKeys returns the sorted union of the keys of both maps.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
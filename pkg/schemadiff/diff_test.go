@@ -0,0 +1,109 @@
+package schemadiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fromDDL = "CREATE DATABASE IF NOT EXISTS test;\nUSE test;\n" +
+	"-- Table structure for table users\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(100) NOT NULL,\n" +
+	"  `legacy` int DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_name` (`name`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=10 DEFAULT CHARSET=utf8mb4;\n" +
+	"CREATE TABLE `old` (\n  `id` int NOT NULL\n) ENGINE=InnoDB;\n" +
+	"CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v_users` AS select `users`.`id` AS `id` from `users`;\n" +
+	"CREATE DEFINER=`root`@`%` TRIGGER `trg` BEFORE INSERT ON `users` FOR EACH ROW BEGIN SET NEW.name = TRIM(NEW.name); END;\n"
+
+const toDDL = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(255) NOT NULL,\n" +
+	"  `name` varchar(255) NOT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uk_email` (`email`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=99 DEFAULT CHARSET=utf8mb4;\n" +
+	"CREATE TABLE `orders` (\n" +
+	"  `id` int NOT NULL,\n" +
+	"  `user_id` int NOT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n" +
+	") ENGINE=InnoDB;\n" +
+	"CREATE ALGORITHM=UNDEFINED DEFINER=`app`@`%` SQL SECURITY DEFINER VIEW `v_users` AS select `users`.`id` AS `id` from `users`;\n" +
+	"CREATE DEFINER=`root`@`%` TRIGGER `trg` BEFORE INSERT ON `users` FOR EACH ROW BEGIN SET NEW.name = UPPER(NEW.name); END;\n"
+
+func TestDiff(t *testing.T) {
+	from, err := Parse(fromDDL)
+	require.NoError(t, err)
+	require.Len(t, from.Tables, 2)
+	require.Len(t, from.Views, 1)
+	require.Len(t, from.Triggers, 1)
+
+	to, err := Parse(toDDL)
+	require.NoError(t, err)
+
+	got := Diff(from, to)
+	require.False(t, got.Empty())
+
+	require.Len(t, got.Tables, 3)
+	require.Equal(t, "old", got.Tables[0].Name)
+	require.Equal(t, Removed, got.Tables[0].Type)
+	require.Equal(t, "orders", got.Tables[1].Name)
+	require.Equal(t, Added, got.Tables[1].Type)
+	require.Equal(t, "users", got.Tables[2].Name)
+	require.Equal(t, Changed, got.Tables[2].Type)
+
+	changes := make(map[string]ChangeType)
+	for _, c := range got.Tables[2].Changes {
+		changes[c.Element+":"+c.Name] = c.Type
+	}
+	require.Equal(t, map[string]ChangeType{
+		"column:email":   Added,
+		"column:legacy":  Removed,
+		"column:name":    Changed,
+		"index:idx_name": Removed,
+		"index:uk_email": Added,
+	}, changes)
+
+	// The view only differs by definer so is not reported.
+	require.Empty(t, got.Views)
+
+	require.Len(t, got.Triggers, 1)
+	require.Equal(t, Changed, got.Triggers[0].Type)
+
+	_, err = json.Marshal(got)
+	require.NoError(t, err)
+}
+
+func TestDiff_NoChanges(t *testing.T) {
+	from, err := Parse(fromDDL)
+	require.NoError(t, err)
+
+	got := Diff(from, from)
+	require.True(t, got.Empty())
+	require.Equal(t, "No differences found.\n", got.Text())
+}
+
+func TestResult_SQL(t *testing.T) {
+	from, err := Parse(fromDDL)
+	require.NoError(t, err)
+
+	to, err := Parse(toDDL)
+	require.NoError(t, err)
+
+	got := Diff(from, to).SQL()
+	require.Contains(t, got, "DROP TRIGGER IF EXISTS `trg`;")
+	require.Contains(t, got, "DROP TABLE IF EXISTS `old`;")
+	require.Contains(t, got, "CREATE TABLE `orders`")
+	require.Contains(t, got, "ALTER TABLE `users`\n"+
+		"  DROP COLUMN `legacy`,\n"+
+		"  DROP INDEX `idx_name`,\n"+
+		"  ADD COLUMN `email` varchar(255) NOT NULL AFTER `id`,\n"+
+		"  MODIFY COLUMN `name` varchar(255) NOT NULL,\n"+
+		"  ADD UNIQUE KEY `uk_email` (`email`);")
+	require.Contains(t, got, "DELIMITER ;;\nCREATE DEFINER=`root`@`%` TRIGGER `trg`")
+}
@@ -0,0 +1,195 @@
+package schemadiff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+// createRegex matches the start of a CREATE statement, with an optional OR REPLACE.
+var createRegex = regexp.MustCompile(`(?i)^CREATE\s+(OR\s+REPLACE\s+)?`)
+
+// changeSymbols are the prefixes used for each change type in the text output.
+var changeSymbols = map[ChangeType]string{
+	Added:   "+",
+	Removed: "-",
+	Changed: "~",
+}
+
+// Text returns a human-readable description of the differences.
+func (r *Result) Text() string {
+	if r.Empty() {
+		return "No differences found.\n"
+	}
+
+	b := new(strings.Builder)
+	for _, t := range r.Tables {
+		fmt.Fprintf(b, "%s table %s\n", changeSymbols[t.Type], sqlparse.QuoteIdentifier(t.Name))
+		for _, c := range t.Changes {
+			switch c.Type {
+			case Added:
+				fmt.Fprintf(b, "    + %s %s: %s\n", c.Element, sqlparse.QuoteIdentifier(c.Name), normalize(c.To))
+			case Removed:
+				fmt.Fprintf(b, "    - %s %s: %s\n", c.Element, sqlparse.QuoteIdentifier(c.Name), normalize(c.From))
+			case Changed:
+				fmt.Fprintf(b, "    ~ %s %s: %s -> %s\n", c.Element, sqlparse.QuoteIdentifier(c.Name), normalize(c.From), normalize(c.To))
+			}
+		}
+	}
+
+	for _, v := range r.Views {
+		fmt.Fprintf(b, "%s view %s\n", changeSymbols[v.Type], sqlparse.QuoteIdentifier(v.Name))
+	}
+
+	for _, t := range r.Triggers {
+		fmt.Fprintf(b, "%s trigger %s\n", changeSymbols[t.Type], sqlparse.QuoteIdentifier(t.Name))
+	}
+
+	return b.String()
+}
+
+// SQL returns a migration script that changes the source schema into the target schema. The script uses DELIMITER
+// for triggers so it is intended to be applied with the mysql client.
+func (r *Result) SQL() string {
+	if r.Empty() {
+		return "-- No differences found.\n"
+	}
+
+	b := new(strings.Builder)
+	b.WriteString("SET FOREIGN_KEY_CHECKS=0;\n\n")
+
+	// Drop triggers and views first as they depend on the tables.
+	for _, t := range r.Triggers {
+		if t.Type != Added {
+			fmt.Fprintf(b, "DROP TRIGGER IF EXISTS %s;\n", sqlparse.QuoteIdentifier(t.Name))
+		}
+	}
+
+	for _, v := range r.Views {
+		if v.Type == Removed {
+			fmt.Fprintf(b, "DROP VIEW IF EXISTS %s;\n", sqlparse.QuoteIdentifier(v.Name))
+		}
+	}
+
+	// Foreign keys must be dropped before the indexes and columns they use.
+	for _, t := range r.Tables {
+		clauses := make([]string, 0)
+		for _, c := range t.Changes {
+			if c.Element == ElementForeignKey && c.Type != Added {
+				clauses = append(clauses, "DROP FOREIGN KEY "+sqlparse.QuoteIdentifier(c.Name))
+			}
+		}
+		writeAlter(b, t.Name, clauses)
+	}
+
+	for _, t := range r.Tables {
+		switch t.Type {
+		case Removed:
+			fmt.Fprintf(b, "DROP TABLE IF EXISTS %s;\n", sqlparse.QuoteIdentifier(t.Name))
+		case Added:
+			fmt.Fprintf(b, "%s;\n", t.to.SQL)
+		case Changed:
+			writeAlter(b, t.Name, t.alterClauses())
+			for _, c := range t.Changes {
+				if c.Element == ElementOptions {
+					fmt.Fprintf(b, "ALTER TABLE %s %s;\n", sqlparse.QuoteIdentifier(t.Name), c.To)
+				}
+			}
+		}
+	}
+
+	for _, t := range r.Tables {
+		clauses := make([]string, 0)
+		for _, c := range t.Changes {
+			if c.Element == ElementForeignKey && c.Type != Removed {
+				clauses = append(clauses, "ADD "+normalize(c.To))
+			}
+		}
+		writeAlter(b, t.Name, clauses)
+	}
+
+	for _, v := range r.Views {
+		if v.Type != Removed {
+			fmt.Fprintf(b, "%s;\n", createRegex.ReplaceAllString(v.To, "CREATE OR REPLACE "))
+		}
+	}
+
+	for _, t := range r.Triggers {
+		if t.Type != Removed {
+			fmt.Fprintf(b, "\nDELIMITER ;;\n%s;;\nDELIMITER ;\n", t.To)
+		}
+	}
+
+	b.WriteString("\nSET FOREIGN_KEY_CHECKS=1;\n")
+
+	return b.String()
+}
+
+// alterClauses returns the ALTER TABLE clauses for the column, index and check changes of a table. Foreign keys and
+// table options are handled separately.
+func (t *TableDiff) alterClauses() []string {
+	drops := make([]string, 0)
+	adds := make([]string, 0)
+
+	for _, c := range t.Changes {
+		switch c.Element {
+		case ElementColumn:
+			switch c.Type {
+			case Removed:
+				drops = append(drops, "DROP COLUMN "+sqlparse.QuoteIdentifier(c.Name))
+			case Added:
+				adds = append(adds, "ADD COLUMN "+sqlparse.QuoteIdentifier(c.Name)+" "+normalize(c.To)+t.columnPosition(c.Name))
+			case Changed:
+				adds = append(adds, "MODIFY COLUMN "+sqlparse.QuoteIdentifier(c.Name)+" "+normalize(c.To))
+			}
+		case ElementIndex:
+			if c.Type != Added {
+				if c.Name == "PRIMARY" {
+					drops = append(drops, "DROP PRIMARY KEY")
+				} else {
+					drops = append(drops, "DROP INDEX "+sqlparse.QuoteIdentifier(c.Name))
+				}
+			}
+			if c.Type != Removed {
+				adds = append(adds, "ADD "+normalize(c.To))
+			}
+		case ElementCheck:
+			if c.Type != Added {
+				drops = append(drops, "DROP CHECK "+sqlparse.QuoteIdentifier(c.Name))
+			}
+			if c.Type != Removed {
+				adds = append(adds, "ADD "+normalize(c.To))
+			}
+		}
+	}
+
+	return append(drops, adds...)
+}
+
+// columnPosition returns the position clause for an added column so it keeps its place in the table.
+func (t *TableDiff) columnPosition(name string) string {
+	for i, c := range t.to.Columns {
+		if c.Name != name {
+			continue
+		}
+
+		if i == 0 {
+			return " FIRST"
+		}
+
+		return " AFTER " + sqlparse.QuoteIdentifier(t.to.Columns[i-1].Name)
+	}
+
+	return ""
+}
+
+// writeAlter writes an ALTER TABLE statement with the given clauses, if there are any.
+func writeAlter(b *strings.Builder, table string, clauses []string) {
+	if len(clauses) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "ALTER TABLE %s\n  %s;\n", sqlparse.QuoteIdentifier(table), strings.Join(clauses, ",\n  "))
+}
@@ -0,0 +1,62 @@
+package schemadiff
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jacobbrewer1/dumpster/pkg/sqlparse"
+)
+
+// Schema is the set of tables, views and triggers described by DDL.
+type Schema struct {
+	// Tables are the tables of the schema.
+	Tables []*sqlparse.Table
+
+	// Views are the views of the schema.
+	Views []*sqlparse.Object
+
+	// Triggers are the triggers of the schema.
+	Triggers []*sqlparse.Object
+}
+
+// Parse parses DDL into a Schema. Any statement that does not create a table, view or trigger is ignored, so a full
+// dump can be parsed as well as a DDL snapshot.
+func Parse(ddl string) (*Schema, error) {
+	s := &Schema{
+		Tables:   make([]*sqlparse.Table, 0),
+		Views:    make([]*sqlparse.Object, 0),
+		Triggers: make([]*sqlparse.Object, 0),
+	}
+
+	for _, stmt := range sqlparse.SplitStatements(ddl) {
+		kind, name, err := sqlparse.CreateKind(stmt)
+		if errors.Is(err, sqlparse.ErrNotCreate) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing statement: %w", err)
+		}
+
+		switch kind {
+		case sqlparse.KindTable:
+			t, err := sqlparse.ParseTable(stmt)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing table %s: %w", name, err)
+			}
+
+			s.Tables = append(s.Tables, t)
+		case sqlparse.KindView, sqlparse.KindTrigger:
+			o, err := sqlparse.ParseObject(stmt)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s %s: %w", kind, name, err)
+			}
+
+			if kind == sqlparse.KindView {
+				s.Views = append(s.Views, o)
+			} else {
+				s.Triggers = append(s.Triggers, o)
+			}
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,557 @@
+package sqlparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kind is the type of object created by a CREATE statement.
+type Kind string
+
+const (
+	// KindTable is a CREATE TABLE statement.
+	KindTable Kind = "table"
+
+	// KindView is a CREATE VIEW statement.
+	KindView Kind = "view"
+
+	// KindTrigger is a CREATE TRIGGER statement.
+	KindTrigger Kind = "trigger"
+
+	// KindProcedure is a CREATE PROCEDURE statement.
+	KindProcedure Kind = "procedure"
+
+	// KindFunction is a CREATE FUNCTION statement.
+	KindFunction Kind = "function"
+)
+
+// definerRegex matches the DEFINER clause of a CREATE statement.
+var definerRegex = regexp.MustCompile("(?i)\\s*DEFINER\\s*=\\s*(?:CURRENT_USER(?:\\(\\))?|(?:`[^`]*`|'[^']*'|[^\\s@]+)@(?:`[^`]*`|'[^']*'|[^\\s]+))")
+
+// ErrNotCreate is returned when a statement is not a CREATE statement for a supported object.
+var ErrNotCreate = errors.New("statement is not a supported CREATE statement")
+
+// Table is a parsed CREATE TABLE statement.
+type Table struct {
+	// Name is the name of the table.
+	Name string
+
+	// Columns are the columns of the table, in order.
+	Columns []*Column
+
+	// Indexes are the indexes of the table, including the primary key.
+	Indexes []*Index
+
+	// ForeignKeys are the foreign key constraints of the table.
+	ForeignKeys []*Constraint
+
+	// Checks are the check constraints of the table.
+	Checks []*Constraint
+
+	// Options is the text after the closing parenthesis of the table definition (ENGINE, CHARSET, etc.).
+	Options string
+
+	// SQL is the original statement.
+	SQL string
+}
+
+// Column is a column definition of a table.
+type Column struct {
+	// Name is the name of the column.
+	Name string
+
+	// Type is the data type of the column, e.g. varchar(255).
+	Type string
+
+	// Definition is the column definition without the column name.
+	Definition string
+}
+
+// Index is an index definition of a table.
+type Index struct {
+	// Name is the name of the index. The primary key is named PRIMARY.
+	Name string
+
+	// Kind is the kind of index: PRIMARY, UNIQUE, INDEX, FULLTEXT or SPATIAL.
+	Kind string
+
+	// Columns is the column list of the index, including any prefix lengths or expressions.
+	Columns []string
+
+	// Definition is the full index definition.
+	Definition string
+}
+
+// Constraint is a named foreign key or check constraint of a table.
+type Constraint struct {
+	// Name is the name of the constraint.
+	Name string
+
+	// Definition is the full constraint definition.
+	Definition string
+}
+
+// Object is a parsed CREATE statement for a view, trigger or routine.
+type Object struct {
+	// Kind is the type of the object.
+	Kind Kind
+
+	// Name is the name of the object.
+	Name string
+
+	// Table is the table a trigger is defined on. This is empty for other objects.
+	Table string
+
+	// SQL is the original statement.
+	SQL string
+}
+
+// CreateKind returns the kind and name of the object created by the statement. ErrNotCreate is returned if the
+// statement is not a CREATE TABLE, VIEW, TRIGGER, PROCEDURE or FUNCTION statement.
+func CreateKind(stmt string) (Kind, string, error) {
+	kind, name, _, err := createHeader(stmt)
+	return kind, name, err
+}
+
+// ParseTable parses a CREATE TABLE statement.
+func ParseTable(stmt string) (*Table, error) {
+	kind, name, rest, err := createHeader(stmt)
+	if err != nil {
+		return nil, err
+	} else if kind != KindTable {
+		return nil, fmt.Errorf("expected CREATE TABLE, got CREATE %s", strings.ToUpper(string(kind)))
+	}
+
+	t := &Table{
+		Name:        name,
+		Columns:     make([]*Column, 0),
+		Indexes:     make([]*Index, 0),
+		ForeignKeys: make([]*Constraint, 0),
+		Checks:      make([]*Constraint, 0),
+		SQL:         strings.TrimSpace(stmt),
+	}
+
+	open := strings.IndexByte(rest, '(')
+	if open < 0 {
+		return nil, errors.New("table definition not found")
+	}
+
+	closing := MatchParen(rest, open)
+	if closing < 0 {
+		return nil, errors.New("table definition is not closed")
+	}
+
+	t.Options = strings.TrimSpace(rest[closing+1:])
+
+	for _, def := range SplitList(rest[open+1 : closing]) {
+		if err := t.addDefinition(def); err != nil {
+			return nil, fmt.Errorf("error parsing definition %q: %w", def, err)
+		}
+	}
+
+	return t, nil
+}
+
+// ParseObject parses a CREATE VIEW, TRIGGER, PROCEDURE or FUNCTION statement.
+func ParseObject(stmt string) (*Object, error) {
+	kind, name, rest, err := createHeader(stmt)
+	if err != nil {
+		return nil, err
+	} else if kind == KindTable {
+		return nil, errors.New("expected a view, trigger or routine, got CREATE TABLE")
+	}
+
+	o := &Object{
+		Kind: kind,
+		Name: name,
+		SQL:  strings.TrimSpace(stmt),
+	}
+
+	if kind == KindTrigger {
+		// <BEFORE|AFTER> <INSERT|UPDATE|DELETE> ON <table>
+		toks := tokenize(rest)
+		for i, tok := range toks {
+			if strings.EqualFold(tok, "ON") && i+1 < len(toks) {
+				o.Table = qualifiedName(toks[i+1:])
+				break
+			}
+		}
+	}
+
+	return o, nil
+}
+
+// StripDefiner removes the DEFINER clause from a CREATE statement.
+func StripDefiner(stmt string) string {
+	return definerRegex.ReplaceAllString(stmt, "")
+}
+
+// QuoteIdentifier quotes a MySQL identifier with backticks.
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Unquote removes identifier quotes from a name.
+func Unquote(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) >= 2 {
+		q := name[0]
+		if (q == '`' || q == '"') && name[len(name)-1] == q {
+			inner := name[1 : len(name)-1]
+			return strings.ReplaceAll(inner, string(q)+string(q), string(q))
+		}
+	}
+	return name
+}
+
+// MatchParen returns the index of the parenthesis matching the one at open, skipping quoted strings and identifiers,
+// or -1 if it is not closed.
+func MatchParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = QuoteEnd(s, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// IndexOutsideQuotes returns the index of the first occurrence of substr in s that is not inside a quoted string or
+// identifier, or -1 if there is none.
+func IndexOutsideQuotes(s, substr string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = QuoteEnd(s, i) - 1
+		default:
+			if strings.HasPrefix(s[i:], substr) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// SplitList splits a comma separated list, ignoring commas inside parentheses and quotes.
+func SplitList(s string) []string {
+	items := make([]string, 0)
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = QuoteEnd(s, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+
+	return items
+}
+
+// createHeader parses the start of a CREATE statement, returning the kind, name and the text after the name.
+func createHeader(stmt string) (Kind, string, string, error) {
+	s := strings.TrimSpace(stmt)
+	word, end := readWord(s, 0)
+	if !strings.EqualFold(word, "CREATE") {
+		return "", "", "", ErrNotCreate
+	}
+
+	pos := end
+	for {
+		pos = skipSpace(s, pos)
+		if pos >= len(s) {
+			return "", "", "", ErrNotCreate
+		}
+
+		word, end = readWord(s, pos)
+		switch strings.ToUpper(word) {
+		case "OR", "REPLACE", "TEMPORARY", "AGGREGATE", "SQL", "SECURITY", "INVOKER":
+			pos = end
+		case "DEFINER":
+			// DEFINER = user, or the DEFINER keyword of SQL SECURITY DEFINER.
+			if loc := definerRegex.FindStringIndex(s[pos:]); loc != nil && loc[0] == 0 {
+				pos += loc[1]
+			} else {
+				pos = end
+			}
+		case "ALGORITHM":
+			// ALGORITHM = <value>
+			pos = skipSpace(s, end)
+			if pos < len(s) && s[pos] == '=' {
+				pos = skipSpace(s, pos+1)
+			}
+			_, pos = readWord(s, pos)
+		case "TABLE", "VIEW", "TRIGGER", "PROCEDURE", "FUNCTION":
+			kind := Kind(strings.ToLower(word))
+			pos = skipSpace(s, end)
+
+			// Skip IF NOT EXISTS
+			if w, e := readWord(s, pos); strings.EqualFold(w, "IF") {
+				_, e = readWord(s, skipSpace(s, e))
+				_, e = readWord(s, skipSpace(s, e))
+				pos = skipSpace(s, e)
+			}
+
+			name, nameEnd := readName(s, pos)
+			if name == "" {
+				return "", "", "", fmt.Errorf("no name found for CREATE %s", strings.ToUpper(word))
+			}
+
+			return kind, name, s[nameEnd:], nil
+		default:
+			return "", "", "", ErrNotCreate
+		}
+	}
+}
+
+// readName reads a possibly schema qualified, possibly quoted name starting at i. The schema is dropped.
+func readName(s string, i int) (string, int) {
+	name := ""
+	for i < len(s) {
+		var part string
+		switch s[i] {
+		case '`', '"':
+			end := QuoteEnd(s, i)
+			part = Unquote(s[i:end])
+			i = end
+		default:
+			var end int
+			part, end = readWord(s, i)
+			if part == "" {
+				return name, i
+			}
+			i = end
+		}
+
+		name = part
+		if i < len(s) && s[i] == '.' {
+			i++
+			continue
+		}
+
+		return name, i
+	}
+
+	return name, i
+}
+
+// qualifiedName returns the unqualified name from the start of the tokens (e.g. `schema` . `table`).
+func qualifiedName(toks []string) string {
+	name := ""
+	for i := 0; i < len(toks); i++ {
+		name = Unquote(toks[i])
+		if i+1 < len(toks) && toks[i+1] == "." {
+			i++
+			continue
+		}
+		break
+	}
+	return name
+}
+
+// tokenize splits s into words, quoted identifiers, strings and punctuation.
+func tokenize(s string) []string {
+	toks := make([]string, 0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isSpace(c):
+		case c == '\'' || c == '"' || c == '`':
+			end := QuoteEnd(s, i)
+			toks = append(toks, s[i:end])
+			i = end - 1
+		case isWordChar(c):
+			_, end := readWord(s, i)
+			toks = append(toks, s[i:end])
+			i = end - 1
+		default:
+			toks = append(toks, string(c))
+		}
+	}
+	return toks
+}
+
+// addDefinition adds a single column, index or constraint definition to the table.
+func (t *Table) addDefinition(def string) error {
+	toks := tokenize(def)
+	if len(toks) == 0 {
+		return errors.New("empty definition")
+	}
+
+	first := strings.ToUpper(toks[0])
+	if strings.HasPrefix(toks[0], "`") || strings.HasPrefix(toks[0], `"`) {
+		// Quoted names are always columns.
+		first = ""
+	}
+
+	switch first {
+	case "PRIMARY":
+		t.Indexes = append(t.Indexes, &Index{
+			Name:       "PRIMARY",
+			Kind:       "PRIMARY",
+			Columns:    indexColumns(def),
+			Definition: def,
+		})
+	case "UNIQUE", "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+		kind := first
+		if kind == "KEY" {
+			kind = "INDEX"
+		}
+
+		idx := &Index{
+			Kind:       kind,
+			Columns:    indexColumns(def),
+			Definition: def,
+		}
+
+		// The name is the first token that is not a keyword and comes before the column list.
+		for _, tok := range toks[1:] {
+			if tok == "(" {
+				break
+			}
+
+			switch strings.ToUpper(tok) {
+			case "KEY", "INDEX":
+				continue
+			}
+
+			idx.Name = Unquote(tok)
+			break
+		}
+
+		if idx.Name == "" && len(idx.Columns) > 0 {
+			idx.Name = Unquote(idx.Columns[0])
+		}
+
+		t.Indexes = append(t.Indexes, idx)
+	case "CONSTRAINT", "FOREIGN", "CHECK":
+		name := ""
+		rest := toks
+		if first == "CONSTRAINT" {
+			rest = toks[1:]
+			if len(rest) > 0 {
+				switch strings.ToUpper(rest[0]) {
+				case "PRIMARY", "UNIQUE", "FOREIGN", "CHECK":
+				default:
+					name = Unquote(rest[0])
+					rest = rest[1:]
+				}
+			}
+		}
+
+		if len(rest) == 0 {
+			return errors.New("constraint has no definition")
+		}
+
+		switch strings.ToUpper(rest[0]) {
+		case "PRIMARY", "UNIQUE":
+			// CONSTRAINT name PRIMARY KEY / UNIQUE KEY, strip the constraint prefix and parse as an index.
+			idx := def[strings.Index(strings.ToUpper(def), strings.ToUpper(rest[0])):]
+			if err := t.addDefinition(idx); err != nil {
+				return err
+			}
+			if name != "" && strings.EqualFold(rest[0], "UNIQUE") {
+				t.Indexes[len(t.Indexes)-1].Name = name
+			}
+		case "FOREIGN":
+			// FOREIGN KEY [name] (columns)
+			if name == "" && len(rest) > 2 && rest[2] != "(" {
+				name = Unquote(rest[2])
+			}
+			t.ForeignKeys = append(t.ForeignKeys, &Constraint{
+				Name:       name,
+				Definition: def,
+			})
+		case "CHECK":
+			t.Checks = append(t.Checks, &Constraint{
+				Name:       name,
+				Definition: def,
+			})
+		default:
+			return fmt.Errorf("unknown constraint type %q", rest[0])
+		}
+	default:
+		name, end := readName(strings.TrimSpace(def), 0)
+		definition := strings.TrimSpace(strings.TrimSpace(def)[end:])
+		t.Columns = append(t.Columns, &Column{
+			Name:       name,
+			Type:       columnType(definition),
+			Definition: definition,
+		})
+	}
+
+	return nil
+}
+
+// Column returns the column with the given name, or nil if the table does not have it.
+func (t *Table) Column(name string) *Column {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// References returns the names of the tables this table references with foreign keys.
+func (t *Table) References() []string {
+	refs := make([]string, 0)
+	for _, fk := range t.ForeignKeys {
+		toks := tokenize(fk.Definition)
+		for i, tok := range toks {
+			if strings.EqualFold(tok, "REFERENCES") && i+1 < len(toks) {
+				refs = append(refs, qualifiedName(toks[i+1:]))
+				break
+			}
+		}
+	}
+	return refs
+}
+
+// columnType returns the data type from the start of a column definition.
+func columnType(definition string) string {
+	word, end := readWord(definition, 0)
+	if end < len(definition) && definition[end] == '(' {
+		if closing := MatchParen(definition, end); closing > 0 {
+			return definition[:closing+1]
+		}
+	}
+	return word
+}
+
+// indexColumns returns the column list of an index definition.
+func indexColumns(def string) []string {
+	open := strings.IndexByte(def, '(')
+	if open < 0 {
+		return nil
+	}
+
+	closing := MatchParen(def, open)
+	if closing < 0 {
+		return nil
+	}
+
+	return SplitList(def[open+1 : closing])
+}
@@ -0,0 +1,159 @@
+package sqlparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCreateTable = "CREATE TABLE `orders` (\n" +
+	"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` int unsigned NOT NULL,\n" +
+	"  `note` varchar(255) COLLATE utf8mb4_unicode_ci DEFAULT 'a, b',\n" +
+	"  `total` decimal(10,2) NOT NULL DEFAULT '0.00',\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uk_note` (`note`(20)),\n" +
+	"  KEY `idx_user` (`user_id`,`total`),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,\n" +
+	"  CONSTRAINT `chk_total` CHECK ((`total` >= 0))\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"
+
+func TestParseTable(t *testing.T) {
+	got, err := ParseTable(testCreateTable)
+	require.NoError(t, err)
+
+	require.Equal(t, "orders", got.Name)
+	require.Equal(t, "ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4", got.Options)
+
+	require.Len(t, got.Columns, 4)
+	require.Equal(t, "id", got.Columns[0].Name)
+	require.Equal(t, "int", got.Columns[0].Type)
+	require.Equal(t, "int unsigned NOT NULL AUTO_INCREMENT", got.Columns[0].Definition)
+	require.Equal(t, "varchar(255)", got.Columns[2].Type)
+	require.Equal(t, "decimal(10,2)", got.Columns[3].Type)
+
+	require.Len(t, got.Indexes, 3)
+	require.Equal(t, &Index{Name: "PRIMARY", Kind: "PRIMARY", Columns: []string{"`id`"}, Definition: "PRIMARY KEY (`id`)"}, got.Indexes[0])
+	require.Equal(t, "uk_note", got.Indexes[1].Name)
+	require.Equal(t, "UNIQUE", got.Indexes[1].Kind)
+	require.Equal(t, []string{"`note`(20)"}, got.Indexes[1].Columns)
+	require.Equal(t, "idx_user", got.Indexes[2].Name)
+	require.Equal(t, "INDEX", got.Indexes[2].Kind)
+	require.Equal(t, []string{"`user_id`", "`total`"}, got.Indexes[2].Columns)
+
+	require.Len(t, got.ForeignKeys, 1)
+	require.Equal(t, "fk_user", got.ForeignKeys[0].Name)
+	require.Equal(t, []string{"users"}, got.References())
+
+	require.Len(t, got.Checks, 1)
+	require.Equal(t, "chk_total", got.Checks[0].Name)
+}
+
+func TestParseTable_Unquoted(t *testing.T) {
+	got, err := ParseTable("create table if not exists app.users (id int primary key, name text, index idx_name (name(10)))")
+	require.NoError(t, err)
+
+	require.Equal(t, "users", got.Name)
+	require.Len(t, got.Columns, 2)
+	require.Equal(t, "id", got.Columns[0].Name)
+	require.Equal(t, "int primary key", got.Columns[0].Definition)
+	require.Len(t, got.Indexes, 1)
+	require.Equal(t, "idx_name", got.Indexes[0].Name)
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		name      string
+		stmt      string
+		wantKind  Kind
+		wantName  string
+		wantTable string
+	}{
+		{
+			name:     "view",
+			stmt:     "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v_users` AS select 1 AS `a`",
+			wantKind: KindView,
+			wantName: "v_users",
+		},
+		{
+			name:      "trigger",
+			stmt:      "CREATE DEFINER=`root`@`localhost` TRIGGER `trg` BEFORE INSERT ON `orders` FOR EACH ROW SET NEW.a = 1",
+			wantKind:  KindTrigger,
+			wantName:  "trg",
+			wantTable: "orders",
+		},
+		{
+			name:     "procedure",
+			stmt:     "CREATE DEFINER=CURRENT_USER PROCEDURE `do_thing`() BEGIN SELECT 1; END",
+			wantKind: KindProcedure,
+			wantName: "do_thing",
+		},
+		{
+			name:     "function",
+			stmt:     "CREATE FUNCTION `db`.`fn`(a int) RETURNS int DETERMINISTIC RETURN a",
+			wantKind: KindFunction,
+			wantName: "fn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseObject(tt.stmt)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantKind, got.Kind)
+			require.Equal(t, tt.wantName, got.Name)
+			require.Equal(t, tt.wantTable, got.Table)
+		})
+	}
+
+	_, err := ParseObject("SET FOREIGN_KEY_CHECKS=0")
+	require.ErrorIs(t, err, ErrNotCreate)
+}
+
+func TestStripDefiner(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{
+			name: "backtick definer",
+			stmt: "CREATE DEFINER=`root`@`%` TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW SET NEW.a = 1",
+			want: "CREATE TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW SET NEW.a = 1",
+		},
+		{
+			name: "view definer",
+			stmt: "CREATE ALGORITHM=UNDEFINED DEFINER=`app`@`10.0.0.%` SQL SECURITY DEFINER VIEW `v` AS select 1",
+			want: "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1",
+		},
+		{
+			name: "no definer",
+			stmt: "CREATE TABLE `t` (`a` int)",
+			want: "CREATE TABLE `t` (`a` int)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, StripDefiner(tt.stmt))
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	require.Equal(t, "`users`", QuoteIdentifier("users"))
+	require.Equal(t, "`we``ird`", QuoteIdentifier("we`ird"))
+}
+
+func TestIndexOutsideQuotes(t *testing.T) {
+	require.Equal(t, 23, IndexOutsideQuotes("`a b` DEFAULT 'c d' AND d e", " d"))
+	require.Equal(t, -1, IndexOutsideQuotes("`a b` 'c\\' b'", " b"))
+	require.Equal(t, 0, IndexOutsideQuotes(" b", " b"))
+}
+
+func TestMatchParen(t *testing.T) {
+	s := "(a, ')', `(`, (b)) c"
+	require.Equal(t, 17, MatchParen(s, 0))
+	require.Equal(t, 16, MatchParen(s, 14))
+	require.Equal(t, -1, MatchParen("(a, (b)", 0))
+}
@@ -0,0 +1,157 @@
+package sqlparse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitStatements splits SQL text into individual statements. Comments are removed, with the exception of MySQL
+// versioned comments (/*!...*/) which are kept as they are executable. Semicolons inside quotes and inside
+// BEGIN ... END blocks (as used by triggers and routines) do not end a statement.
+func SplitStatements(sql string) []string {
+	statements := make([]string, 0)
+	current := new(strings.Builder)
+	depth := 0
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		if stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := QuoteEnd(sql, i)
+			current.WriteString(sql[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(sql[i:], "--") && (i+2 == len(sql) || isSpace(sql[i+2])):
+			i = lineEnd(sql, i) - 1
+		case c == '#':
+			i = lineEnd(sql, i) - 1
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i + 4
+			}
+
+			if strings.HasPrefix(sql[i:], "/*!") {
+				current.WriteString(sql[i:end])
+			} else {
+				current.WriteByte(' ')
+			}
+			i = end - 1
+		case c == ';' && depth == 0:
+			flush()
+		case isWordStart(sql, i):
+			word, end := readWord(sql, i)
+			current.WriteString(sql[i:end])
+			i = end - 1
+
+			switch strings.ToUpper(word) {
+			case "BEGIN":
+				// A bare BEGIN (or BEGIN WORK) starts a transaction rather than a block.
+				next, _ := readWord(sql, skipSpace(sql, end))
+				if !strings.EqualFold(next, "WORK") && !strings.HasPrefix(sql[skipSpace(sql, end):], ";") {
+					depth++
+				}
+			case "CASE":
+				depth++
+			case "END":
+				nextStart := skipSpace(sql, end)
+				next, nextEnd := readWord(sql, nextStart)
+				switch strings.ToUpper(next) {
+				case "IF", "LOOP", "WHILE", "REPEAT":
+					// These blocks do not open a depth level, so their END does not close one.
+				case "CASE":
+					// END CASE closes the CASE statement, consume the CASE keyword so it does not open a new level.
+					current.WriteString(sql[end:nextEnd])
+					i = nextEnd - 1
+					depth--
+				default:
+					depth--
+				}
+
+				if depth < 0 {
+					depth = 0
+				}
+			}
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	flush()
+
+	return statements
+}
+
+// QuoteEnd returns the index after the closing quote of the quoted string starting at i, or the end of s if it is not
+// closed. Quotes are escaped by doubling them, and with a backslash in strings.
+func QuoteEnd(s string, i int) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if q != '`' {
+				j++
+			}
+		case q:
+			// A doubled quote is an escaped quote.
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// lineEnd returns the index of the end of the line containing i.
+func lineEnd(s string, i int) int {
+	end := strings.IndexByte(s[i:], '\n')
+	if end < 0 {
+		return len(s)
+	}
+	return i + end
+}
+
+// skipSpace returns the index of the next non-space character at or after i.
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// isWordStart reports whether a word starts at i.
+func isWordStart(s string, i int) bool {
+	if !isWordChar(s[i]) {
+		return false
+	}
+	return i == 0 || !isWordChar(s[i-1])
+}
+
+// readWord reads the word starting at i, returning the word and the index after it.
+func readWord(s string, i int) (string, int) {
+	end := i
+	for end < len(s) && isWordChar(s[end]) {
+		end++
+	}
+	return s[i:end], end
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,62 @@
+package sqlparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "simple statements",
+			sql:  "SET FOREIGN_KEY_CHECKS=0;\nUSE test;\n",
+			want: []string{"SET FOREIGN_KEY_CHECKS=0", "USE test"},
+		},
+		{
+			name: "comments are removed",
+			sql:  "-- Server version\n# hash comment\n/* block */ SELECT 1;\n",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "versioned comments are kept",
+			sql:  "/*!40101 SET NAMES utf8mb4 */;",
+			want: []string{"/*!40101 SET NAMES utf8mb4 */"},
+		},
+		{
+			name: "semicolons in strings",
+			sql:  "INSERT INTO t VALUES ('a;b','it''s;',\"c;\\\"d\");SELECT 2;",
+			want: []string{"INSERT INTO t VALUES ('a;b','it''s;',\"c;\\\"d\")", "SELECT 2"},
+		},
+		{
+			name: "trigger with begin end",
+			sql: "CREATE TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW BEGIN\n" +
+				"  IF NEW.a > 0 THEN SET NEW.b = 1; END IF;\n" +
+				"  SET NEW.c = CASE WHEN NEW.a = 1 THEN 2 ELSE 3 END;\n" +
+				"END;\nSELECT 1;",
+			want: []string{
+				"CREATE TRIGGER `t` BEFORE INSERT ON `x` FOR EACH ROW BEGIN\n" +
+					"  IF NEW.a > 0 THEN SET NEW.b = 1; END IF;\n" +
+					"  SET NEW.c = CASE WHEN NEW.a = 1 THEN 2 ELSE 3 END;\n" +
+					"END",
+				"SELECT 1",
+			},
+		},
+		{
+			name: "transaction begin",
+			sql:  "BEGIN;\nINSERT INTO t VALUES (1);\nCOMMIT;",
+			want: []string{"BEGIN", "INSERT INTO t VALUES (1)", "COMMIT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitStatements(tt.sql)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}